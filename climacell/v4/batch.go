@@ -0,0 +1,112 @@
+package climacell
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultBatchWorkers is the number of requests BatchGetTimelines and
+// StreamTimelines send at once when no worker count is given.
+const DefaultBatchWorkers = 4
+
+// BatchLocation is one of the locations of a batch request.
+type BatchLocation struct {
+	// Key identifies the location's result. It defaults to the
+	// LocationID, or the Key of the Location geometry.
+	Key string
+	// Location is the point or polygon to request. It is ignored if
+	// LocationID is set.
+	Location Geometry
+	// LocationID is the ID of a location saved with the Locations API.
+	LocationID string
+}
+
+func (l BatchLocation) key() string {
+	switch {
+	case l.Key != "":
+		return l.Key
+	case l.LocationID != "":
+		return l.LocationID
+	}
+	return l.Location.Key()
+}
+
+// BatchResult is the result of the request for one location of a batch.
+type BatchResult struct {
+	// Key is the Key of the location.
+	Key string
+	// Index is the index of the location in the batch.
+	Index int
+	// Timelines are the location's timelines, if the request succeeded.
+	Timelines *TimelineList
+	// Err is the error the request failed with, if any. In DecodeLenient
+	// mode, it can be DecodeWarnings, which are returned along with the
+	// Timelines that could be decoded, as by GetTimelines.
+	Err error
+}
+
+// BatchGetTimelines requests timelines for many locations, sharing every
+// option but the location, with up to workers requests in flight at once.
+// A nil options is the same as empty options.
+// Requests wait for the client's RateLimiter like any other request. The
+// results are returned in the order of the locations, and a failed request
+// only fails its own location's result.
+func (c *ClientV4) BatchGetTimelines(ctx context.Context, locations []BatchLocation, options *TimelineListOptions, workers int) []BatchResult {
+	results := make([]BatchResult, len(locations))
+	for r := range c.StreamTimelines(ctx, locations, options, workers) {
+		results[r.Index] = r
+	}
+	return results
+}
+
+// StreamTimelines is like BatchGetTimelines, but sends each location's
+// result on the returned channel as soon as its request completes. The
+// channel is closed after every location's result has been sent, and must
+// be drained. If ctx is canceled, the remaining locations' results have the
+// context's error.
+func (c *ClientV4) StreamTimelines(ctx context.Context, locations []BatchLocation, options *TimelineListOptions, workers int) <-chan BatchResult {
+	if options == nil {
+		options = &TimelineListOptions{}
+	}
+	if workers <= 0 {
+		workers = DefaultBatchWorkers
+	}
+	if workers > len(locations) {
+		workers = len(locations)
+	}
+
+	results := make(chan BatchResult)
+	jobs := make(chan int)
+	go func() {
+		for i := range locations {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				loc := locations[i]
+				r := BatchResult{Key: loc.key(), Index: i}
+				if err := ctx.Err(); err != nil {
+					r.Err = err
+				} else {
+					opts := *options
+					opts.Location = loc.Location
+					opts.LocationID = loc.LocationID
+					r.Timelines, r.Err = c.GetTimelines(ctx, &opts)
+				}
+				results <- r
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
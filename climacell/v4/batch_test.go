@@ -0,0 +1,139 @@
+package climacell
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchServer responds with a timeline whose "site" value is the requested
+// location, and records the most requests it handled at once.
+type batchServer struct {
+	mu            sync.Mutex
+	inFlight, max int
+}
+
+func (s *batchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.inFlight++
+	if s.inFlight > s.max {
+		s.max = s.inFlight
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	var opts map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&opts)
+	if opts["location"] == "bad" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code": 400001, "type": "Invalid Body Parameters", "message": "unknown location"}`))
+		return
+	}
+	site, _ := json.Marshal(fmt.Sprint(opts["location"]))
+	fmt.Fprintf(w, `{"data": {"timelines": [{"timestep": "1h", "intervals": [{"startTime": "2020-12-21T06:00:00Z", "values": {"site": %s}}]}]}}`, site)
+}
+
+func batchLocations(n int) []BatchLocation {
+	var locs []BatchLocation
+	for i := 0; i < n; i++ {
+		locs = append(locs, BatchLocation{Location: Geometry{Type: "Point", Coordinates: []float64{float64(i), 1}}})
+	}
+	locs = append(locs,
+		BatchLocation{LocationID: "bad"},
+		BatchLocation{Key: "park", Location: Geometry{Type: "Polygon", Coordinates: [][][]float64{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}}},
+	)
+	return locs
+}
+
+func TestBatchGetTimelines(t *testing.T) {
+	s := &batchServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+
+	locs := batchLocations(10)
+	results := client.BatchGetTimelines(context.Background(), locs, &TimelineListOptions{
+		Fields:    []string{"temperature"},
+		TimeSteps: []string{"1h"},
+	}, 3)
+	require.Len(t, results, 12)
+	assert.LessOrEqual(t, s.max, 3)
+
+	for i, r := range results[:10] {
+		require.NoError(t, r.Err)
+		assert.Equal(t, i, r.Index)
+		assert.Equal(t, fmt.Sprintf("1,%d", i), r.Key)
+		site, _ := r.Timelines.Timelines[0].Intervals[0].Values.String("site")
+		assert.Contains(t, site, "Point")
+	}
+
+	bad := results[10]
+	assert.Equal(t, "bad", bad.Key)
+	var errRes *ErrorResponse
+	require.ErrorAs(t, bad.Err, &errRes)
+	assert.Equal(t, 400, errRes.StatusCode)
+
+	park := results[11]
+	require.NoError(t, park.Err)
+	assert.Equal(t, "park", park.Key)
+}
+
+func TestBatchGetTimelinesLenient(t *testing.T) {
+	server := driftedServer()
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+	client.DecodeMode = DecodeLenient
+
+	// the timelines that could be decoded are kept along with the warnings
+	for _, r := range client.BatchGetTimelines(context.Background(), batchLocations(2), nil, 0) {
+		var warnings DecodeWarnings
+		require.True(t, errors.As(r.Err, &warnings))
+		require.NotNil(t, r.Timelines)
+		assert.NotEmpty(t, r.Timelines.Timelines)
+	}
+}
+
+func TestStreamTimelines(t *testing.T) {
+	server := httptest.NewServer(&batchServer{})
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+
+	seen := map[int]bool{}
+	for r := range client.StreamTimelines(context.Background(), batchLocations(5), &TimelineListOptions{}, 0) {
+		seen[r.Index] = true
+	}
+	assert.Len(t, seen, 7)
+
+	// nil options are empty options
+	for r := range client.StreamTimelines(context.Background(), batchLocations(1), nil, 0) {
+		if r.Key == "park" {
+			assert.NoError(t, r.Err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, r := range client.BatchGetTimelines(ctx, batchLocations(5), &TimelineListOptions{}, 2) {
+		assert.ErrorIs(t, r.Err, context.Canceled)
+	}
+}
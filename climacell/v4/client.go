@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/pkg/errors"
@@ -20,6 +21,33 @@ type ClientV4 struct {
 	BaseURL    string
 	apiKey     string
 	HTTPClient *http.Client
+	// RateLimiter, if set, delays requests so that they stay within its
+	// limits.
+	RateLimiter *RateLimiter
+	// Keys, if set, are the API keys requests are sent with instead of the
+	// client's own key, as described by KeyPool.
+	Keys *KeyPool
+	// DecodeMode sets how responses are decoded. In DecodeLenient mode,
+	// calls return the data that could be decoded along with
+	// DecodeWarnings.
+	DecodeMode DecodeMode
+	// Middleware are the hooks called for each request, in order.
+	Middleware []Middleware
+	// Retries is the number of times a request is retried after a 429 or
+	// 5xx response, or after failing without a response. Retries wait for
+	// the response's Retry-After header if it has one, or RetryBackoff,
+	// doubling for each retry after the first, up to MaxRetryWait. There
+	// are no retries by default.
+	Retries int
+	// RetryBackoff is the time before the first retry. It defaults to
+	// DefaultRetryBackoff.
+	RetryBackoff time.Duration
+	// MaxRetryWait is the longest a retry waits, whatever the response's
+	// Retry-After. It defaults to DefaultMaxRetryWait.
+	MaxRetryWait time.Duration
+
+	// group coalesces identical concurrent requests.
+	group flightGroup
 }
 
 func NewClient(apiKey string) *ClientV4 {
@@ -32,9 +60,18 @@ func NewClient(apiKey string) *ClientV4 {
 	}
 }
 
+// GetTimelines requests the timelines for a location. Concurrent calls for
+// the same options, whatever the order of their fields, share a single
+// request, and each returns its own copy of the response. A nil options is
+// the same as empty options.
 func (c *ClientV4) GetTimelines(ctx context.Context, options *TimelineListOptions) (*TimelineList, error) {
+	if options == nil {
+		options = &TimelineListOptions{}
+	}
+	normalized := *options
+	normalized.Fields = sortedFields(options.Fields)
 
-	jsonValue, err := json.Marshal(options)
+	jsonValue, err := json.Marshal(normalized)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to construct body for call to create a Source with the Sources API: %v.", err)
 	}
@@ -45,51 +82,127 @@ func (c *ClientV4) GetTimelines(ctx context.Context, options *TimelineListOption
 	}
 
 	res := TimelineList{}
-	if err := c.sendRequest(req, &res); err != nil {
+	if err := c.sendRequest(req, RequestInfo{Endpoint: "timelines", Options: options}, &res); err != nil {
+		if isWarnings(err) {
+			return &res, err
+		}
 		return nil, err
 	}
 
 	return &res, nil
 }
 
-func (c *ClientV4) sendRequest(req *http.Request, v interface{}) error {
+// ListLocations returns the locations saved with the ClimaCell Locations API,
+// whose IDs can be used as the LocationID of a TimelineListOptions.
+func (c *ClientV4) ListLocations(ctx context.Context) ([]SavedLocation, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/locations?apikey=%s", c.BaseURL, c.apiKey), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := struct {
+		Locations []SavedLocation `json:"locations"`
+	}{}
+	if err := c.sendRequest(req, RequestInfo{Endpoint: "locations"}, &res); err != nil {
+		if isWarnings(err) {
+			return res.Locations, err
+		}
+		return nil, err
+	}
+
+	return res.Locations, nil
+}
+
+// ListAlerts returns the alerts configured with the ClimaCell Alerts API.
+func (c *ClientV4) ListAlerts(ctx context.Context) ([]Alert, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/alerts?apikey=%s", c.BaseURL, c.apiKey), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := struct {
+		Alerts []Alert `json:"alerts"`
+	}{}
+	if err := c.sendRequest(req, RequestInfo{Endpoint: "alerts"}, &res); err != nil {
+		if isWarnings(err) {
+			return res.Alerts, err
+		}
+		return nil, err
+	}
+
+	return res.Alerts, nil
+}
+
+func (c *ClientV4) sendRequest(req *http.Request, info RequestInfo, v interface{}) error {
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 	req.Header.Set("Accept", "application/json; charset=utf-8")
 
-	res, err := c.HTTPClient.Do(req)
+	key, err := requestKey(req)
 	if err != nil {
 		return err
 	}
-
-	defer res.Body.Close()
-
-	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
-		var errRes errorResponse
-		if err = json.NewDecoder(res.Body).Decode(&errRes); err == nil {
-			return errors.New(errRes.Message)
+	res, shared, err := coalesce(req.Context(), &c.group, key, func(ctx context.Context) (*response, error) {
+		s := sender{
+			client:     c.HTTPClient,
+			limiter:    c.RateLimiter,
+			keys:       c.Keys,
+			middleware: c.Middleware,
+			retries:    c.Retries,
+			backoff:    c.RetryBackoff,
+			maxWait:    c.MaxRetryWait,
+			apiError:   apiErrorV4,
+			setKey:     setQueryKey,
 		}
-
-		return fmt.Errorf("unknown error, status code: %d", res.StatusCode)
+		return s.send(ctx, info, req)
+	})
+	if err != nil {
+		return err
+	}
+	meta := res.meta()
+	if shared {
+		onShared(req.Context(), c.Middleware, info, req, meta)
 	}
+	setResponseMeta(req.Context(), meta)
 
-	fullResponse := successResponse{
-		Data: v,
+	if res.status < http.StatusOK || res.status >= http.StatusBadRequest {
+		return apiErrorV4(res)
 	}
-	if err = json.NewDecoder(res.Body).Decode(&fullResponse); err != nil {
+
+	var fullResponse successResponse
+	if err = json.Unmarshal(res.body, &fullResponse); err != nil {
 		return err
 	}
+	if fullResponse.Data == nil {
+		return nil
+	}
 
-	return nil
+	return c.DecodeMode.unmarshal("data", fullResponse.Data, v)
+}
+
+// apiErrorV4 returns the *ErrorResponse of a v4 response with an error
+// status.
+func apiErrorV4(res *response) error {
+	var errRes errorResponse
+	if err := json.Unmarshal(res.body, &errRes); err == nil && errRes.Message != "" {
+		return &ErrorResponse{
+			StatusCode: res.status,
+			ErrorCode:  strconv.Itoa(errRes.Code),
+			Message:    errRes.Message,
+		}
+	}
+
+	return &ErrorResponse{StatusCode: res.status, Message: http.StatusText(res.status)}
 }
 
 type errorResponse struct {
 	Code    int    `json:"code"`
+	Type    string `json:"type"`
 	Message string `json:"message"`
 }
 
 type successResponse struct {
-	Code int         `json:"code"`
-	Data interface{} `json:"data"`
+	Code int             `json:"code"`
+	Data json.RawMessage `json:"data"`
 }
 
 // ClientV3 is the client for sending HTTP requests to ClimaCell's HTTP
@@ -105,6 +218,21 @@ type ClientV3 struct {
 
 	// net/http Client for contacting the ClimaCell API.
 	c *http.Client
+
+	// coalesces identical concurrent requests
+	group flightGroup
+
+	// DecodeMode sets how responses are decoded. In DecodeLenient mode,
+	// calls return the samples that could be decoded along with
+	// DecodeWarnings.
+	DecodeMode DecodeMode
+	// Keys, Middleware, Retries, RetryBackoff and MaxRetryWait are like
+	// those of a ClientV4.
+	Keys         *KeyPool
+	Middleware   []Middleware
+	Retries      int
+	RetryBackoff time.Duration
+	MaxRetryWait time.Duration
 }
 
 func newDefaultHTTPClient() *http.Client { return &http.Client{Timeout: time.Minute} }
@@ -147,8 +275,18 @@ func NewWithClient(apiKey string, c *http.Client) *ClientV3 {
 // things such as errors sending the request to the API, or unexpected errors
 // deserializing responses.
 func (c *ClientV3) Nowcast(args ForecastArgs) ([]NowCastForecast, error) {
+	return c.NowcastContext(context.Background(), args)
+}
+
+// NowcastContext is like Nowcast, but sends the request with ctx, which
+// can cancel it or, from WithResponseMeta, be filled in with its
+// ResponseMeta.
+func (c *ClientV3) NowcastContext(ctx context.Context, args ForecastArgs) ([]NowCastForecast, error) {
 	var w []NowCastForecast
-	if err := c.getWeatherSamples("weather/nowcast", args, &w); err != nil {
+	if err := c.getWeatherSamples(ctx, "weather/nowcast", args, &w); err != nil {
+		if isWarnings(err) {
+			return w, err
+		}
 		return nil, err
 	}
 	return w, nil
@@ -165,8 +303,18 @@ func (c *ClientV3) Nowcast(args ForecastArgs) ([]NowCastForecast, error) {
 // things such as errors sending the request to the API, or unexpected errors
 // deserializing responses.
 func (c *ClientV3) HourlyForecast(args ForecastArgs) ([]HourlyForecast, error) {
+	return c.HourlyForecastContext(context.Background(), args)
+}
+
+// HourlyForecastContext is like HourlyForecast, but sends the request with ctx, which
+// can cancel it or, from WithResponseMeta, be filled in with its
+// ResponseMeta.
+func (c *ClientV3) HourlyForecastContext(ctx context.Context, args ForecastArgs) ([]HourlyForecast, error) {
 	var w []HourlyForecast
-	if err := c.getWeatherSamples("weather/forecast/hourly", args, &w); err != nil {
+	if err := c.getWeatherSamples(ctx, "weather/forecast/hourly", args, &w); err != nil {
+		if isWarnings(err) {
+			return w, err
+		}
 		return nil, err
 	}
 	return w, nil
@@ -183,8 +331,18 @@ func (c *ClientV3) HourlyForecast(args ForecastArgs) ([]HourlyForecast, error) {
 // things such as errors sending the request to the API, or unexpected errors
 // deserializing responses.
 func (c *ClientV3) DailyForecast(args ForecastArgs) ([]ForecastDay, error) {
+	return c.DailyForecastContext(context.Background(), args)
+}
+
+// DailyForecastContext is like DailyForecast, but sends the request with ctx, which
+// can cancel it or, from WithResponseMeta, be filled in with its
+// ResponseMeta.
+func (c *ClientV3) DailyForecastContext(ctx context.Context, args ForecastArgs) ([]ForecastDay, error) {
 	var f []ForecastDay
-	if err := c.getWeatherSamples("weather/forecast/daily", args, &f); err != nil {
+	if err := c.getWeatherSamples(ctx, "weather/forecast/daily", args, &f); err != nil {
+		if isWarnings(err) {
+			return f, err
+		}
 		return nil, err
 	}
 	return f, nil
@@ -201,8 +359,18 @@ func (c *ClientV3) DailyForecast(args ForecastArgs) ([]ForecastDay, error) {
 // things such as errors sending the request to the API, or unexpected errors
 // deserializing responses.
 func (c *ClientV3) HistoricalStation(args ForecastArgs) ([]HistoricalStation, error) {
+	return c.HistoricalStationContext(context.Background(), args)
+}
+
+// HistoricalStationContext is like HistoricalStation, but sends the request with ctx, which
+// can cancel it or, from WithResponseMeta, be filled in with its
+// ResponseMeta.
+func (c *ClientV3) HistoricalStationContext(ctx context.Context, args ForecastArgs) ([]HistoricalStation, error) {
 	var f []HistoricalStation
-	if err := c.getWeatherSamples("weather/historical/station", args, &f); err != nil {
+	if err := c.getWeatherSamples(ctx, "weather/historical/station", args, &f); err != nil {
+		if isWarnings(err) {
+			return f, err
+		}
 		return nil, err
 	}
 	return f, nil
@@ -219,8 +387,18 @@ func (c *ClientV3) HistoricalStation(args ForecastArgs) ([]HistoricalStation, er
 // things such as errors sending the request to the API, or unexpected errors
 // deserializing responses.
 func (c *ClientV3) HistoricalClimaCell(args ForecastArgs) ([]HistoricalClimaCell, error) {
+	return c.HistoricalClimaCellContext(context.Background(), args)
+}
+
+// HistoricalClimaCellContext is like HistoricalClimaCell, but sends the request with ctx, which
+// can cancel it or, from WithResponseMeta, be filled in with its
+// ResponseMeta.
+func (c *ClientV3) HistoricalClimaCellContext(ctx context.Context, args ForecastArgs) ([]HistoricalClimaCell, error) {
 	var f []HistoricalClimaCell
-	if err := c.getWeatherSamples("weather/historical/climacell", args, &f); err != nil {
+	if err := c.getWeatherSamples(ctx, "weather/historical/climacell", args, &f); err != nil {
+		if isWarnings(err) {
+			return f, err
+		}
 		return nil, err
 	}
 	return f, nil
@@ -236,14 +414,25 @@ func (c *ClientV3) HistoricalClimaCell(args ForecastArgs) ([]HistoricalClimaCell
 // things such as errors sending the request to the API, or unexpected errors
 // deserializing responses.
 func (c *ClientV3) RealTime(args ForecastArgs) (RealTime, error) {
+	return c.RealTimeContext(context.Background(), args)
+}
+
+// RealTimeContext is like RealTime, but sends the request with ctx, which
+// can cancel it or, from WithResponseMeta, be filled in with its
+// ResponseMeta.
+func (c *ClientV3) RealTimeContext(ctx context.Context, args ForecastArgs) (RealTime, error) {
 	var f RealTime
-	if err := c.getWeatherSamples("weather/realtime", args, &f); err != nil {
+	if err := c.getWeatherSamples(ctx, "weather/realtime", args, &f); err != nil {
+		if isWarnings(err) {
+			return f, err
+		}
 		return RealTime{}, err
 	}
 	return f, nil
 }
 
 func (c *ClientV3) getWeatherSamples(
+	ctx context.Context,
 	endpt string,
 	args ForecastArgs,
 	expectedResponse interface{},
@@ -254,38 +443,73 @@ func (c *ClientV3) getWeatherSamples(
 	}
 	u = u.ResolveReference(&url.URL{Path: endpt})
 
-	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	// the fields are sorted so that requests for the same fields in a
+	// different order are coalesced
+	args.Fields = sortedFields(args.Fields)
+	u.RawQuery = args.QueryParams().Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return errors.WithMessage(err, "making HTTP request")
 	}
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("apikey", c.apiKey)
-	req.URL.RawQuery = args.QueryParams().Encode()
 
-	res, err := c.c.Do(req)
+	info := RequestInfo{Endpoint: endpt, Options: args}
+	res, shared, err := coalesce(ctx, &c.group, u.String(), func(ctx context.Context) (*response, error) {
+		s := sender{
+			client:     c.c,
+			keys:       c.Keys,
+			middleware: c.Middleware,
+			retries:    c.Retries,
+			backoff:    c.RetryBackoff,
+			maxWait:    c.MaxRetryWait,
+			apiError:   apiErrorV3,
+			setKey:     setHeaderKey,
+		}
+		res, err := s.send(ctx, info, req)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "sending weather data request to %s", endpt)
+		}
+		return res, nil
+	})
 	if err != nil {
-		return errors.WithMessagef(err, "sending weather data request to %s", endpt)
+		return err
+	}
+	meta := res.meta()
+	if shared {
+		onShared(ctx, c.Middleware, info, req, meta)
 	}
-	defer res.Body.Close()
+	setResponseMeta(ctx, meta)
 
-	switch res.StatusCode {
-	case 200:
-		if err := json.NewDecoder(res.Body).Decode(expectedResponse); err != nil {
-			return errors.WithMessage(err, "deserializing weather response data")
+	if res.status != 200 {
+		return apiErrorV3(res)
+	}
+	if err := c.DecodeMode.Unmarshal(res.body, expectedResponse); err != nil {
+		if isWarnings(err) {
+			return err
 		}
-		return nil
+		return errors.WithMessage(err, "deserializing weather response data")
+	}
+	return nil
+}
+
+// apiErrorV3 returns the error of a v3 response with an error status, which
+// is an *ErrorResponse for the statuses the API documents.
+func apiErrorV3(res *response) error {
+	switch res.status {
 	case 400, 401, 403, 404, 500:
 		var errRes ErrorResponse
-		if err := json.NewDecoder(res.Body).Decode(&errRes); err != nil {
+		if err := json.Unmarshal(res.body, &errRes); err != nil {
 			return errors.WithMessage(err, "deserializing weather error response")
 		}
 
-		if res.StatusCode == 401 || res.StatusCode == 403 {
-			errRes.StatusCode = res.StatusCode
+		if res.status == 401 || res.status == 403 {
+			errRes.StatusCode = res.status
 		}
 		return &errRes
 	default:
-		return fmt.Errorf("unexpected HTTP response status code: %d", res.StatusCode)
+		return fmt.Errorf("unexpected HTTP response status code: %d", res.status)
 	}
 }
 
@@ -1,6 +1,7 @@
 package climacell
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -268,3 +269,77 @@ func TestRealTimeEndpoint(t *testing.T) {
 		t.Errorf("Did not get expected result. Wanted %f, got: %f\n", expectedTemp, value)
 	}
 }
+
+func timelinesHandler() http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("apikey") != "test_api_key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code": 401001, "type": "Invalid Auth", "message": "The method requires authentication but it was not presented or is invalid."}`))
+			return
+		}
+
+		var opts map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil || opts["location"] == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code": 400001, "type": "Invalid Body Parameters", "message": "location is required"}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"timelines": [{
+			"timestep": "1h",
+			"startTime": "2020-12-21T06:00:00Z",
+			"endTime": "2020-12-21T07:00:00Z",
+			"intervals": [{"startTime": "2020-12-21T06:00:00Z", "values": {"temperature": 15.1}}]
+		}]}}`))
+	}
+	return http.HandlerFunc(fn)
+}
+
+func TestGetTimelines(t *testing.T) {
+	server := httptest.NewServer(timelinesHandler())
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+
+	list, err := client.GetTimelines(context.Background(), &TimelineListOptions{
+		LocationID: "5fbe7c8a4b1e2c0008a2d6b1",
+		Fields:     []string{"temperature"},
+		TimeSteps:  []string{"1h"},
+	})
+	if err != nil {
+		t.Fatalf("GetTimelines returned an unexpected error: %v", err)
+	}
+
+	if len(list.Timelines) != 1 || len(list.Timelines[0].Intervals) != 1 {
+		t.Fatalf("Did not get expected timelines, got: %+v", list)
+	}
+	value, _ := list.Timelines[0].Intervals[0].Values.Float("temperature")
+	expectedTemp := 15.10
+	if expectedTemp != value {
+		t.Errorf("Did not get expected result. Wanted %f, got: %f\n", expectedTemp, value)
+	}
+
+	// nil options are sent as empty options
+	if _, err := client.GetTimelines(context.Background(), nil); err != nil {
+		t.Fatalf("GetTimelines with nil options returned an unexpected error: %v", err)
+	}
+}
+
+func TestGetTimelinesErrorResponse(t *testing.T) {
+	server := httptest.NewServer(timelinesHandler())
+	defer server.Close()
+
+	client := NewClient("wrong_api_key")
+	client.BaseURL = server.URL
+
+	_, err := client.GetTimelines(context.Background(), &TimelineListOptions{})
+	errRes, ok := err.(*ErrorResponse)
+	if !ok {
+		t.Fatalf("Expected an *ErrorResponse, got: %v", err)
+	}
+	if errRes.StatusCode != http.StatusUnauthorized || errRes.ErrorCode != "401001" {
+		t.Errorf("Did not get expected error, got: %+v", errRes)
+	}
+}
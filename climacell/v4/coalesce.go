@@ -0,0 +1,129 @@
+package climacell
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// response is the status and body of a request, shared by every caller that
+// made the request while it was in flight. Callers decode their own copy of
+// the body, so that they never share the values they are returned.
+type response struct {
+	status  int
+	header  http.Header
+	body    []byte
+	latency time.Duration
+}
+
+// flightGroup coalesces identical concurrent requests. Its zero value is
+// ready to use.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is a request in flight, with the number of callers still waiting
+// for its response.
+type flight struct {
+	done    chan struct{}
+	res     *response
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// coalesce calls send to make the request identified by key, unless an
+// identical request is already in flight, in which case it waits for that
+// request's response instead and returns a true "shared". The request is sent
+// with a context that is not canceled with ctx, so that a caller giving up
+// doesn't fail the others waiting for the same response; each caller only
+// waits until its own ctx is done, and the request is canceled once every
+// caller waiting for it has given up.
+func coalesce(ctx context.Context, g *flightGroup, key string, send func(ctx context.Context) (*response, error)) (res *response, shared bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = map[string]*flight{}
+	}
+	f, shared := g.flights[key]
+	if !shared {
+		sctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.flights[key] = f
+		go func() {
+			f.res, f.err = send(sctx)
+			g.forget(key, f)
+			cancel()
+			close(f.done)
+		}()
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.res, shared, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// nobody is left to read the response, and later callers
+			// send their own request
+			f.cancel()
+			g.forgetLocked(key, f)
+		}
+		g.mu.Unlock()
+		return nil, false, ctx.Err()
+	}
+}
+
+// forget removes f from g, unless it was already replaced by another
+// request.
+func (g *flightGroup) forget(key string, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.forgetLocked(key, f)
+}
+
+func (g *flightGroup) forgetLocked(key string, f *flight) {
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
+
+// requestKey identifies a request by its method, URL and body, which
+// requests built with a bytes.Buffer body can read again from GetBody.
+func requestKey(req *http.Request) (string, error) {
+	key := req.Method + " " + req.URL.String()
+	if req.GetBody == nil {
+		return key, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	return key + "\n" + string(b), nil
+}
+
+// sortedFields returns a sorted copy of fields, so that requests for the same
+// fields in a different order are coalesced.
+func sortedFields(fields []string) []string {
+	if len(fields) == 0 {
+		return fields
+	}
+	sorted := append([]string(nil), fields...)
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,185 @@
+package climacell
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// heldServer counts the requests it receives, and holds each until release
+// is closed so that concurrent callers pile up behind it.
+type heldServer struct {
+	requests int32
+	release  chan struct{}
+	status   int
+	body     string
+}
+
+func (s *heldServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&s.requests, 1)
+	<-s.release
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+	}
+	w.Write([]byte(s.body))
+}
+
+// concurrently calls fn from n goroutines, releasing the server once they
+// have had time to join the same request.
+func concurrently(s *heldServer, n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			fn(i)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(s.release)
+	wg.Wait()
+}
+
+func TestGetTimelinesCoalesced(t *testing.T) {
+	s := &heldServer{
+		release: make(chan struct{}),
+		body:    `{"data": {"timelines": [{"timestep": "1h", "intervals": [{"startTime": "2020-12-21T06:00:00Z", "values": {"temperature": 15.1}}]}]}}`,
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+
+	fields := [][]string{{"temperature", "windSpeed"}, {"windSpeed", "temperature"}}
+	lists := make([]*TimelineList, 8)
+	concurrently(s, len(lists), func(i int) {
+		list, err := client.GetTimelines(context.Background(), &TimelineListOptions{
+			LocationID: "5fbe7c8a4b1e2c0008a2d6b1",
+			Fields:     fields[i%2],
+			TimeSteps:  []string{"1h"},
+		})
+		require.NoError(t, err)
+		lists[i] = list
+	})
+	assert.EqualValues(t, 1, s.requests)
+
+	// each caller decodes its own copy of the response
+	lists[0].Timelines[0].Intervals[0].Values["temperature"] = 0.0
+	for _, list := range lists[1:] {
+		temp, _ := list.Timelines[0].Intervals[0].Values.Float("temperature")
+		assert.Equal(t, 15.1, temp)
+	}
+
+	// requests made after the response arrived are not coalesced with it
+	_, err := client.GetTimelines(context.Background(), &TimelineListOptions{LocationID: "5fbe7c8a4b1e2c0008a2d6b1"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, s.requests)
+}
+
+func TestGetTimelinesCoalescedErrors(t *testing.T) {
+	s := &heldServer{
+		release: make(chan struct{}),
+		status:  http.StatusTooManyRequests,
+		body:    `{"code": 429001, "type": "Too Many Calls", "message": "rate limit exceeded"}`,
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+
+	canceled, cancel := context.WithCancel(context.Background())
+	errs := make([]error, 4)
+	concurrently(s, len(errs), func(i int) {
+		ctx := context.Background()
+		if i == 0 {
+			ctx = canceled
+			time.AfterFunc(10*time.Millisecond, cancel)
+		}
+		_, errs[i] = client.GetTimelines(ctx, &TimelineListOptions{LocationID: "home"})
+	})
+	assert.EqualValues(t, 1, s.requests)
+
+	// a caller giving up doesn't fail the others
+	assert.ErrorIs(t, errs[0], context.Canceled)
+	var first *ErrorResponse
+	require.ErrorAs(t, errs[1], &first)
+	assert.Equal(t, "429001", first.ErrorCode)
+	for _, err := range errs[2:] {
+		var errRes *ErrorResponse
+		require.ErrorAs(t, err, &errRes)
+		assert.Equal(t, first, errRes)
+		assert.NotSame(t, first, errRes)
+	}
+}
+
+func TestGetTimelinesCoalescedAbandoned(t *testing.T) {
+	s := &heldServer{release: make(chan struct{}), body: `{"data": {"timelines": []}}`}
+	close(s.release)
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+	client.RateLimiter = NewRateLimiter(1, 100*time.Millisecond)
+	require.NoError(t, client.RateLimiter.Wait(context.Background()))
+
+	var wg sync.WaitGroup
+	for _, timeout := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond} {
+		wg.Add(1)
+		go func(timeout time.Duration) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			_, err := client.GetTimelines(ctx, &TimelineListOptions{LocationID: "home"})
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+		}(timeout)
+	}
+	wg.Wait()
+
+	// once every caller gave up, the request is canceled before it is sent
+	time.Sleep(150 * time.Millisecond)
+	assert.EqualValues(t, 0, s.requests)
+
+	// and later callers send their own
+	_, err := client.GetTimelines(context.Background(), &TimelineListOptions{LocationID: "home"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, s.requests)
+}
+
+func TestRealTimeCoalesced(t *testing.T) {
+	s := &heldServer{
+		release: make(chan struct{}),
+		body:    `{"lat": 35.8, "lon": -78.6, "temperature": {"value": 15.1, "units": "C"}}`,
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	client := New("test_api_key")
+	client.baseURL = server.URL
+
+	fields := [][]string{{"temp", "humidity"}, {"humidity", "temp"}}
+	results := make([]RealTime, 4)
+	concurrently(s, len(results), func(i int) {
+		r, err := client.RealTime(ForecastArgs{
+			Location: &LatLon{Lat: 35.8, Lon: -78.6},
+			Fields:   fields[i%2],
+		})
+		require.NoError(t, err)
+		results[i] = r
+	})
+	assert.EqualValues(t, 1, s.requests)
+
+	*results[0].Temp.Value = 0
+	for _, r := range results[1:] {
+		assert.Equal(t, 15.1, *r.Temp.Value)
+	}
+}
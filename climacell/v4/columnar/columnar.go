@@ -0,0 +1,327 @@
+// Package columnar converts ClimaCell timelines and v3 weather samples to
+// Apache Arrow records and Parquet files for loading into columnar data
+// stores.
+//
+// Every record has a "location", "timestep" and "time" column followed by one
+// nullable column per requested field, typed from the field registry:
+// float fields are float64, int fields are int64, enum fields are int32 codes
+// and time fields are UTC timestamps. Each field column carries its units,
+// kind, group and enum labels as Arrow field metadata, so the files describe
+// themselves.
+package columnar
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/pkg/errors"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// Names of the columns every record starts with.
+const (
+	LocationColumn = "location"
+	TimestepColumn = "timestep"
+	TimeColumn     = "time"
+)
+
+// DefaultRowGroupSize is the number of rows a Writer buffers before writing
+// them to its Parquet file as a row group.
+const DefaultRowGroupSize = 64 * 1024
+
+var timestampType = &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}
+
+// Schema returns the Arrow schema for records holding the given fields. The
+// fields are sorted by name so that the same set of fields always produces
+// the same schema. An error is returned if a field is not in the registry.
+func Schema(fields []string) (*arrow.Schema, error) {
+	registered, err := lookupFields(fields)
+	if err != nil {
+		return nil, err
+	}
+	return schema(registered), nil
+}
+
+func lookupFields(fields []string) ([]climacell.Field, error) {
+	names := append([]string(nil), fields...)
+	sort.Strings(names)
+
+	registered := make([]climacell.Field, 0, len(names))
+	for i, name := range names {
+		if i > 0 && names[i-1] == name {
+			continue
+		}
+		f, ok := climacell.LookupField(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		registered = append(registered, f)
+	}
+	return registered, nil
+}
+
+func schema(fields []climacell.Field) *arrow.Schema {
+	cols := []arrow.Field{
+		{Name: LocationColumn, Type: arrow.BinaryTypes.String},
+		{Name: TimestepColumn, Type: arrow.BinaryTypes.String},
+		{Name: TimeColumn, Type: timestampType},
+	}
+	for _, f := range fields {
+		cols = append(cols, arrow.Field{
+			Name:     f.Name,
+			Type:     columnType(f.Kind),
+			Nullable: true,
+			Metadata: fieldMetadata(f),
+		})
+	}
+	return arrow.NewSchema(cols, nil)
+}
+
+func columnType(kind climacell.FieldKind) arrow.DataType {
+	switch kind {
+	case climacell.FieldInt:
+		return arrow.PrimitiveTypes.Int64
+	case climacell.FieldEnum:
+		return arrow.PrimitiveTypes.Int32
+	case climacell.FieldTime:
+		return timestampType
+	default:
+		return arrow.PrimitiveTypes.Float64
+	}
+}
+
+func fieldMetadata(f climacell.Field) arrow.Metadata {
+	keys := []string{"kind", "group"}
+	values := []string{f.Kind.String(), f.Group}
+	if f.Units != "" {
+		keys = append(keys, "units")
+		values = append(values, f.Units)
+	}
+	if len(f.Labels) > 0 {
+		labels := make(map[string]string, len(f.Labels))
+		for code, label := range f.Labels {
+			labels[strconv.Itoa(code)] = label
+		}
+		b, _ := json.Marshal(labels)
+		keys = append(keys, "labels")
+		values = append(values, string(b))
+	}
+	return arrow.NewMetadata(keys, values)
+}
+
+// Builder accumulates timeline intervals and v3 weather samples into Arrow
+// records. Rows from any number of locations, timesteps and requests can be
+// appended before calling NewRecord.
+type Builder struct {
+	fields []climacell.Field
+	schema *arrow.Schema
+	b      *array.RecordBuilder
+	rows   int
+}
+
+// NewBuilder returns a Builder for records holding the given fields, using
+// the schema returned by Schema.
+func NewBuilder(fields []string) (*Builder, error) {
+	registered, err := lookupFields(fields)
+	if err != nil {
+		return nil, err
+	}
+	s := schema(registered)
+	return &Builder{
+		fields: registered,
+		schema: s,
+		b:      array.NewRecordBuilder(memory.DefaultAllocator, s),
+	}, nil
+}
+
+// Schema returns the schema of the records this Builder produces.
+func (b *Builder) Schema() *arrow.Schema { return b.schema }
+
+// Len returns the number of rows appended since the last call to NewRecord.
+func (b *Builder) Len() int { return b.rows }
+
+// AppendTimeline appends a row for each interval of a timeline requested for
+// a location.
+func (b *Builder) AppendTimeline(location string, t climacell.Timeline) {
+	for _, iv := range t.Intervals {
+		b.AppendInterval(location, t.Timestep, iv)
+	}
+}
+
+// AppendSample appends a row for a weather sample returned from one of
+// ClientV3's endpoints, like a HourlyForecast or RealTime. Since v3 samples
+// don't carry their timestep, it is passed in, for example "1h" for hourly
+// forecasts.
+func (b *Builder) AppendSample(location, timestep string, sample interface{}) error {
+	iv, err := climacell.SampleInterval(sample)
+	if err != nil {
+		return err
+	}
+	b.AppendInterval(location, timestep, iv)
+	return nil
+}
+
+// AppendInterval appends a row for a single interval. Fields absent from the
+// interval's values are appended as nulls.
+func (b *Builder) AppendInterval(location, timestep string, iv climacell.Interval) {
+	b.b.Field(0).(*array.StringBuilder).Append(location)
+	b.b.Field(1).(*array.StringBuilder).Append(timestep)
+	appendTime(b.b.Field(2), iv.StartTime, true)
+
+	for i, f := range b.fields {
+		col := b.b.Field(i + 3)
+		switch f.Kind {
+		case climacell.FieldInt:
+			v, ok := iv.Values.Int(f.Name)
+			if ok {
+				col.(*array.Int64Builder).Append(int64(v))
+			} else {
+				col.AppendNull()
+			}
+		case climacell.FieldEnum:
+			v, ok := iv.Values.Int(f.Name)
+			if ok {
+				col.(*array.Int32Builder).Append(int32(v))
+			} else {
+				col.AppendNull()
+			}
+		case climacell.FieldTime:
+			v, ok := iv.Values.Time(f.Name)
+			appendTime(col, v, ok)
+		default:
+			v, ok := iv.Values.Float(f.Name)
+			if ok {
+				col.(*array.Float64Builder).Append(v)
+			} else {
+				col.AppendNull()
+			}
+		}
+	}
+	b.rows++
+}
+
+func appendTime(col array.Builder, t time.Time, ok bool) {
+	if !ok {
+		col.AppendNull()
+		return
+	}
+	col.(*array.TimestampBuilder).Append(arrow.Timestamp(t.UnixNano() / int64(time.Millisecond)))
+}
+
+// NewRecord returns a record holding the rows appended so far and resets the
+// Builder. The caller is responsible for calling Release on the record.
+func (b *Builder) NewRecord() arrow.Record {
+	b.rows = 0
+	return b.b.NewRecord()
+}
+
+// Release releases the memory held by the Builder.
+func (b *Builder) Release() { b.b.Release() }
+
+// Writer writes timeline intervals and v3 weather samples to a Parquet file.
+// Rows are buffered and written as a row group every RowGroupSize rows, so a
+// single file can be appended to across many locations and pagination
+// windows before it is closed.
+type Writer struct {
+	// RowGroupSize is the number of rows buffered before they are written
+	// as a row group. It defaults to DefaultRowGroupSize.
+	RowGroupSize int
+
+	b  *Builder
+	fw *pqarrow.FileWriter
+}
+
+// NewWriter returns a Writer that writes Parquet data for the given fields to
+// w, compressed with Snappy. The Arrow schema is stored in the file's
+// metadata so that readers get back the same column types and field
+// metadata.
+func NewWriter(w io.Writer, fields []string) (*Writer, error) {
+	b, err := NewBuilder(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
+	fw, err := pqarrow.NewFileWriter(b.Schema(), w, props, pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema()))
+	if err != nil {
+		b.Release()
+		return nil, errors.WithMessage(err, "creating parquet writer")
+	}
+	return &Writer{RowGroupSize: DefaultRowGroupSize, b: b, fw: fw}, nil
+}
+
+// Schema returns the Arrow schema of the file this Writer writes.
+func (w *Writer) Schema() *arrow.Schema { return w.b.Schema() }
+
+// WriteTimeline writes a row for each interval of a timeline requested for a
+// location.
+func (w *Writer) WriteTimeline(location string, t climacell.Timeline) error {
+	for _, iv := range t.Intervals {
+		if err := w.WriteInterval(location, t.Timestep, iv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteSample writes a row for a weather sample returned from one of
+// ClientV3's endpoints.
+func (w *Writer) WriteSample(location, timestep string, sample interface{}) error {
+	if err := w.b.AppendSample(location, timestep, sample); err != nil {
+		return err
+	}
+	return w.flushIfFull()
+}
+
+// WriteInterval writes a row for a single interval.
+func (w *Writer) WriteInterval(location, timestep string, iv climacell.Interval) error {
+	w.b.AppendInterval(location, timestep, iv)
+	return w.flushIfFull()
+}
+
+func (w *Writer) flushIfFull() error {
+	size := w.RowGroupSize
+	if size <= 0 {
+		size = DefaultRowGroupSize
+	}
+	if w.b.Len() < size {
+		return nil
+	}
+	return w.Flush()
+}
+
+// Flush writes the buffered rows to the file as a row group.
+func (w *Writer) Flush() error {
+	if w.b.Len() == 0 {
+		return nil
+	}
+	rec := w.b.NewRecord()
+	defer rec.Release()
+	if err := w.fw.Write(rec); err != nil {
+		return errors.WithMessage(err, "writing parquet row group")
+	}
+	return nil
+}
+
+// Close flushes any buffered rows and writes the Parquet file footer. If the
+// underlying io.Writer is also an io.Closer, it is closed as well, even if
+// flushing fails. The first error is returned.
+func (w *Writer) Close() error {
+	defer w.b.Release()
+	err := w.Flush()
+	if cerr := w.fw.Close(); cerr != nil && err == nil {
+		err = errors.WithMessage(cerr, "closing parquet writer")
+	}
+	return err
+}
@@ -0,0 +1,172 @@
+package columnar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+func TestSchemaIsStable(t *testing.T) {
+	a, err := Schema([]string{"weatherCode", "temperature", "sunriseTime", "epaIndex"})
+	require.NoError(t, err)
+	b, err := Schema([]string{"epaIndex", "sunriseTime", "temperature", "weatherCode", "temperature"})
+	require.NoError(t, err)
+	assert.True(t, a.Equal(b))
+
+	names := make([]string, 0, a.NumFields())
+	for _, f := range a.Fields() {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"location", "timestep", "time", "epaIndex", "sunriseTime", "temperature", "weatherCode"}, names)
+
+	temp, _ := a.FieldsByName("temperature")
+	assert.Equal(t, arrow.PrimitiveTypes.Float64, temp[0].Type)
+	assert.True(t, temp[0].Nullable)
+	units, ok := temp[0].Metadata.GetValue("units")
+	assert.True(t, ok)
+	assert.Equal(t, "C", units)
+
+	code, _ := a.FieldsByName("weatherCode")
+	assert.Equal(t, arrow.PrimitiveTypes.Int32, code[0].Type)
+	labels, ok := code[0].Metadata.GetValue("labels")
+	assert.True(t, ok)
+	assert.Contains(t, labels, `"1000":"Clear"`)
+
+	_, err = Schema([]string{"notAField"})
+	assert.Error(t, err)
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	start := time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC)
+	timeline := climacell.Timeline{
+		Timestep: "1h",
+		Intervals: []climacell.Interval{
+			{StartTime: start, Values: climacell.Values{"temperature": 10.5, "weatherCode": 1000.0}},
+			{StartTime: start.Add(time.Hour), Values: climacell.Values{"weatherCode": 4001.0}},
+		},
+	}
+	temp := 12.25
+	sample := climacell.HourlyForecast{
+		BaseResponseType: climacell.BaseResponseType{
+			ObservationTime: climacell.DateValue{Value: start},
+		},
+		WeatherType: climacell.WeatherType{Temp: &climacell.FloatValue{Value: &temp}},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, []string{"temperature", "weatherCode"})
+	require.NoError(t, err)
+	w.RowGroupSize = 2
+	require.NoError(t, w.WriteTimeline("35.8,-78.6", timeline))
+	require.NoError(t, w.WriteSample("42.4,-71.1", "1h", sample))
+	require.NoError(t, w.Close())
+
+	tbl, err := pqarrow.ReadTable(context.Background(), bytes.NewReader(buf.Bytes()), nil, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	require.NoError(t, err)
+	defer tbl.Release()
+
+	assert.EqualValues(t, 3, tbl.NumRows())
+	require.Equal(t, w.Schema().NumFields(), tbl.Schema().NumFields())
+	for i, f := range w.Schema().Fields() {
+		got := tbl.Schema().Field(i)
+		assert.Equal(t, f.Name, got.Name)
+		assert.True(t, arrow.TypeEqual(f.Type, got.Type), "type of column %s", f.Name)
+	}
+	units, ok := tbl.Schema().Field(3).Metadata.GetValue("units")
+	assert.True(t, ok)
+	assert.Equal(t, "C", units)
+
+	tr := array.NewTableReader(tbl, 3)
+	defer tr.Release()
+	require.True(t, tr.Next())
+	rec := tr.Record()
+
+	locations := rec.Column(0).(*array.String)
+	assert.Equal(t, "35.8,-78.6", locations.Value(0))
+	assert.Equal(t, "42.4,-71.1", locations.Value(2))
+
+	temps := rec.Column(3).(*array.Float64)
+	assert.Equal(t, 10.5, temps.Value(0))
+	assert.True(t, temps.IsNull(1))
+	assert.Equal(t, 12.25, temps.Value(2))
+
+	codes := rec.Column(4).(*array.Int32)
+	assert.EqualValues(t, 4001, codes.Value(1))
+	assert.True(t, codes.IsNull(2))
+
+	times := rec.Column(2).(*array.Timestamp)
+	assert.Equal(t, start.Add(time.Hour), times.Value(1).ToTime(arrow.Millisecond))
+}
+
+func TestAppendV3Samples(t *testing.T) {
+	day := time.Date(2020, 12, 21, 0, 0, 0, 0, time.UTC)
+	low, high := -1.5, 8.0
+	code, phase := "rain_light", "waning_gibbous"
+	forecast := climacell.ForecastDay{
+		ObservationTime: climacell.DateValue{Value: day},
+		Temp: &climacell.ForecastMinAndMax{
+			{ObservationTime: day.Add(6 * time.Hour), Min: &climacell.FloatValue{Value: &low}},
+			{ObservationTime: day.Add(15 * time.Hour), Max: &climacell.FloatValue{Value: &high}},
+		},
+		WeatherCode: &climacell.StringValue{Value: &code},
+		MoonPhase:   &climacell.StringValue{Value: &phase},
+	}
+
+	b, err := NewBuilder([]string{"temperatureMin", "temperatureMax", "weatherCode", "moonPhase"})
+	require.NoError(t, err)
+	defer b.Release()
+	require.NoError(t, b.AppendSample("42.4,-71.1", "1d", forecast))
+	rec := b.NewRecord()
+	defer rec.Release()
+
+	names := make(map[string]int)
+	for i, f := range rec.Schema().Fields() {
+		names[f.Name] = i
+	}
+	for name := range names {
+		assert.False(t, rec.Column(names[name]).IsNull(0), "column %s is null", name)
+	}
+	assert.Equal(t, -1.5, rec.Column(names["temperatureMin"]).(*array.Float64).Value(0))
+	assert.Equal(t, 8.0, rec.Column(names["temperatureMax"]).(*array.Float64).Value(0))
+	assert.EqualValues(t, 4200, rec.Column(names["weatherCode"]).(*array.Int32).Value(0))
+	assert.EqualValues(t, 5, rec.Column(names["moonPhase"]).(*array.Int32).Value(0))
+}
+
+// brokenFile fails writes once broken is set, and records being closed.
+type brokenFile struct {
+	broken, closed bool
+}
+
+func (f *brokenFile) Write(p []byte) (int, error) {
+	if f.broken {
+		return 0, errors.New("disk full")
+	}
+	return len(p), nil
+}
+
+func (f *brokenFile) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestWriterCloseAfterFailedFlush(t *testing.T) {
+	f := &brokenFile{}
+	w, err := NewWriter(f, []string{"temperature"})
+	require.NoError(t, err)
+	require.NoError(t, w.WriteInterval("42.4,-71.1", "1h", climacell.Interval{Values: climacell.Values{"temperature": 1.0}}))
+
+	f.broken = true
+	assert.Error(t, w.Close())
+	assert.True(t, f.closed)
+}
@@ -0,0 +1,211 @@
+package climacell
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultConditionFields are the fields requested by Current, Hourly, Daily
+// and Minutely when no fields are given.
+var DefaultConditionFields = []string{
+	"temperature", "temperatureApparent", "dewPoint", "humidity",
+	"windSpeed", "windDirection", "windGust",
+	"pressureSurfaceLevel", "pressureSeaLevel",
+	"precipitationIntensity", "precipitationProbability", "precipitationType",
+	"visibility", "cloudCover", "cloudBase", "cloudCeiling", "weatherCode",
+}
+
+// Conditions are the weather conditions of an interval of a timeline, with
+// a typed field for each of the DefaultConditionFields. Like the fields of
+// the v3 weather samples, fields that weren't requested or returned are nil,
+// and their GetValue methods return a false "ok".
+type Conditions struct {
+	// Time is the start time of the interval.
+	Time time.Time
+
+	Temperature              *FloatValue
+	TemperatureApparent      *FloatValue
+	DewPoint                 *FloatValue
+	Humidity                 *FloatValue
+	WindSpeed                *FloatValue
+	WindDirection            *FloatValue
+	WindGust                 *FloatValue
+	PressureSurfaceLevel     *FloatValue
+	PressureSeaLevel         *FloatValue
+	PrecipitationIntensity   *FloatValue
+	PrecipitationProbability *FloatValue
+	PrecipitationType        *IntValue
+	Visibility               *FloatValue
+	CloudCover               *FloatValue
+	CloudBase                *FloatValue
+	CloudCeiling             *FloatValue
+	WeatherCode              *IntValue
+
+	// Values are all of the interval's values, including the fields
+	// without a typed field.
+	Values Values
+}
+
+// NewConditions returns the typed conditions of an interval, whose values
+// are in the given unit system, either "metric" or "imperial", for the
+// fields' Units. The unit system defaults to metric.
+func NewConditions(iv Interval, system string) Conditions {
+	c := Conditions{Time: iv.StartTime, Values: iv.Values}
+	for name, field := range c.floats() {
+		if v, ok := iv.Values.Float(name); ok {
+			*field = &FloatValue{Value: &v, Units: unitsIn(name, system)}
+		}
+	}
+	for name, field := range c.ints() {
+		if v, ok := iv.Values.Int(name); ok {
+			*field = &IntValue{Value: &v}
+		}
+	}
+	return c
+}
+
+func (c *Conditions) floats() map[string]**FloatValue {
+	return map[string]**FloatValue{
+		"temperature":              &c.Temperature,
+		"temperatureApparent":      &c.TemperatureApparent,
+		"dewPoint":                 &c.DewPoint,
+		"humidity":                 &c.Humidity,
+		"windSpeed":                &c.WindSpeed,
+		"windDirection":            &c.WindDirection,
+		"windGust":                 &c.WindGust,
+		"pressureSurfaceLevel":     &c.PressureSurfaceLevel,
+		"pressureSeaLevel":         &c.PressureSeaLevel,
+		"precipitationIntensity":   &c.PrecipitationIntensity,
+		"precipitationProbability": &c.PrecipitationProbability,
+		"visibility":               &c.Visibility,
+		"cloudCover":               &c.CloudCover,
+		"cloudBase":                &c.CloudBase,
+		"cloudCeiling":             &c.CloudCeiling,
+	}
+}
+
+func (c *Conditions) ints() map[string]**IntValue {
+	return map[string]**IntValue{
+		"precipitationType": &c.PrecipitationType,
+		"weatherCode":       &c.WeatherCode,
+	}
+}
+
+func unitsIn(name, system string) string {
+	f, ok := LookupField(name)
+	if !ok {
+		return ""
+	}
+	return f.UnitsIn(system)
+}
+
+// CurrentConditions are the conditions at a location now.
+type CurrentConditions struct{ Conditions }
+
+// MinutelyConditions are the conditions of a minute of a forecast.
+type MinutelyConditions struct{ Conditions }
+
+// HourlyConditions are the conditions of an hour of a forecast.
+type HourlyConditions struct{ Conditions }
+
+// DailyConditions are the conditions of a day of a forecast.
+type DailyConditions struct{ Conditions }
+
+// Current returns the current conditions at a location, which is a LatLon or
+// a LocationID. The DefaultConditionFields are requested if no fields are
+// given.
+func (c *ClientV4) Current(ctx context.Context, location Location, fields ...string) (CurrentConditions, error) {
+	conditions, err := c.conditions(ctx, location, "current", fields)
+	if err != nil && !isWarnings(err) {
+		return CurrentConditions{}, err
+	}
+	if len(conditions) == 0 {
+		return CurrentConditions{}, fmt.Errorf("no current conditions returned")
+	}
+	return CurrentConditions{conditions[0]}, err
+}
+
+// Minutely returns the minute-by-minute forecast for a location, like
+// Current.
+func (c *ClientV4) Minutely(ctx context.Context, location Location, fields ...string) ([]MinutelyConditions, error) {
+	conditions, err := c.conditions(ctx, location, "1m", fields)
+	if err != nil && !isWarnings(err) {
+		return nil, err
+	}
+	res := make([]MinutelyConditions, len(conditions))
+	for i, cond := range conditions {
+		res[i] = MinutelyConditions{cond}
+	}
+	return res, err
+}
+
+// Hourly returns the hourly forecast for a location, like Current.
+func (c *ClientV4) Hourly(ctx context.Context, location Location, fields ...string) ([]HourlyConditions, error) {
+	conditions, err := c.conditions(ctx, location, "1h", fields)
+	if err != nil && !isWarnings(err) {
+		return nil, err
+	}
+	res := make([]HourlyConditions, len(conditions))
+	for i, cond := range conditions {
+		res[i] = HourlyConditions{cond}
+	}
+	return res, err
+}
+
+// Daily returns the daily forecast for a location, like Current.
+func (c *ClientV4) Daily(ctx context.Context, location Location, fields ...string) ([]DailyConditions, error) {
+	conditions, err := c.conditions(ctx, location, "1d", fields)
+	if err != nil && !isWarnings(err) {
+		return nil, err
+	}
+	res := make([]DailyConditions, len(conditions))
+	for i, cond := range conditions {
+		res[i] = DailyConditions{cond}
+	}
+	return res, err
+}
+
+// conditions requests a location's timeline for a timestep, and returns the
+// conditions of its intervals.
+func (c *ClientV4) conditions(ctx context.Context, location Location, timestep string, fields []string) ([]Conditions, error) {
+	if len(fields) == 0 {
+		fields = DefaultConditionFields
+	}
+	intervals, err := c.intervals(ctx, location, timestep, fields)
+	if err != nil && !isWarnings(err) {
+		return nil, err
+	}
+	conditions := make([]Conditions, len(intervals))
+	for i, iv := range intervals {
+		conditions[i] = NewConditions(iv, "")
+	}
+	return conditions, err
+}
+
+// intervals requests a location's timeline for a timestep, and returns its
+// intervals.
+func (c *ClientV4) intervals(ctx context.Context, location Location, timestep string, fields []string) ([]Interval, error) {
+	options := &TimelineListOptions{Fields: fields, TimeSteps: []string{timestep}}
+	switch l := location.(type) {
+	case LatLon:
+		options.Location = Geometry{Type: "Point", Coordinates: []float64{l.Lon, l.Lat}}
+	case *LatLon:
+		options.Location = Geometry{Type: "Point", Coordinates: []float64{l.Lon, l.Lat}}
+	case LocationID:
+		options.LocationID = string(l)
+	default:
+		return nil, fmt.Errorf("unsupported location type %T", location)
+	}
+
+	list, err := c.GetTimelines(ctx, options)
+	if err != nil && !isWarnings(err) {
+		return nil, err
+	}
+	for _, t := range list.Timelines {
+		if t.Timestep == timestep {
+			return t.Intervals, err
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,99 @@
+package climacell
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// conditionsHandler responds with two intervals of the requested timestep,
+// recording the options of the last request.
+func conditionsHandler(last *map[string]interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var opts map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&opts)
+		*last = opts
+		timestep := opts["timesteps"].([]interface{})[0]
+		fmt.Fprintf(w, `{"data": {"timelines": [{"timestep": %q, "intervals": [
+			{"startTime": "2020-12-21T06:00:00Z", "values": {"temperature": 15.1, "weatherCode": 1001, "treeOak": 2}},
+			{"startTime": "2020-12-21T07:00:00Z", "values": {"temperature": 14.2}}
+		]}]}}`, timestep)
+	})
+}
+
+func TestCurrent(t *testing.T) {
+	var last map[string]interface{}
+	server := httptest.NewServer(conditionsHandler(&last))
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+
+	c, err := client.Current(context.Background(), LatLon{Lat: 35.8, Lon: -78.6})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"current"}, last["timesteps"])
+	assert.Equal(t, []interface{}{-78.6, 35.8}, last["location"].(map[string]interface{})["coordinates"])
+	assert.Len(t, last["fields"], len(DefaultConditionFields))
+
+	assert.Equal(t, time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC), c.Time)
+	temp, ok := c.Temperature.GetValue()
+	assert.True(t, ok)
+	assert.Equal(t, 15.1, temp)
+	assert.Equal(t, "C", c.Temperature.Units)
+	code, ok := c.WeatherCode.GetValue()
+	assert.True(t, ok)
+	assert.Equal(t, 1001, code)
+	_, ok = c.Humidity.GetValue()
+	assert.False(t, ok)
+	// fields without a typed field are still in the values
+	oak, _ := c.Values.Int("treeOak")
+	assert.Equal(t, 2, oak)
+
+	_, err = client.Current(context.Background(), LocationID("5fbe7c8a4b1e2c0008a2d6b1"), "temperature")
+	require.NoError(t, err)
+	assert.Equal(t, "5fbe7c8a4b1e2c0008a2d6b1", last["location"])
+	assert.Equal(t, []interface{}{"temperature"}, last["fields"])
+
+	_, err = client.Current(context.Background(), nil)
+	assert.EqualError(t, err, "unsupported location type <nil>")
+}
+
+func TestForecastHelpers(t *testing.T) {
+	var last map[string]interface{}
+	server := httptest.NewServer(conditionsHandler(&last))
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+	loc := &LatLon{Lat: 35.8, Lon: -78.6}
+
+	hourly, err := client.Hourly(context.Background(), loc)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"1h"}, last["timesteps"])
+	require.Len(t, hourly, 2)
+	temp, _ := hourly[1].Temperature.GetValue()
+	assert.Equal(t, 14.2, temp)
+	assert.Nil(t, hourly[1].WeatherCode)
+
+	daily, err := client.Daily(context.Background(), loc)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"1d"}, last["timesteps"])
+	assert.Len(t, daily, 2)
+
+	minutely, err := client.Minutely(context.Background(), loc)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"1m"}, last["timesteps"])
+	assert.Len(t, minutely, 2)
+}
+
+func TestNewConditionsImperial(t *testing.T) {
+	c := NewConditions(Interval{Values: Values{"windSpeed": 10.0}}, "imperial")
+	assert.Equal(t, "mph", c.WindSpeed.Units)
+}
@@ -0,0 +1,235 @@
+package climacell
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultDailyFields are the fields requested by ClientV4.DailyForecast when
+// no fields are given: the daily highs and lows of the fields of a v3
+// ForecastDay, with the sunrise, sunset and moon phase.
+var DefaultDailyFields = []string{
+	"temperatureMax", "temperatureMaxTime", "temperatureMin", "temperatureMinTime",
+	"temperatureApparentMax", "temperatureApparentMaxTime", "temperatureApparentMin", "temperatureApparentMinTime",
+	"humidityMax", "humidityMaxTime", "humidityMin", "humidityMinTime",
+	"windSpeedMax", "windSpeedMaxTime", "windSpeedMin", "windSpeedMinTime",
+	"windDirectionAvg",
+	"pressureSurfaceLevelMax", "pressureSurfaceLevelMaxTime", "pressureSurfaceLevelMin", "pressureSurfaceLevelMinTime",
+	"precipitationIntensityMax", "precipitationIntensityMaxTime", "precipitationIntensityMin", "precipitationIntensityMinTime",
+	"precipitationProbabilityMax",
+	"visibilityMax", "visibilityMaxTime", "visibilityMin", "visibilityMinTime",
+	"sunriseTime", "sunsetTime", "moonPhase", "weatherCode",
+}
+
+// DailyForecast is a day of a location's daily forecast from the v4 1d
+// timestep, the v4 equivalent of a v3 ForecastDay.
+//
+// The daily aggregates of each field, like "temperatureMax" or
+// "temperatureMinTime", are decoded into the field's struct, which is nil if
+// none of its aggregates were requested or returned. Because the structs
+// can't tell a missing value from a zero one, the Max, Min and Avg methods
+// are the way to check for a value, returning results like those of a v3
+// ForecastMinAndMax:
+//
+//	highTemp, ok := d.Max("temperature").GetValue()
+//	if !ok {
+//		/* handle the case where the max temp value is absent */
+//	}
+type DailyForecast struct {
+	// Time is the start time of the day.
+	Time time.Time
+
+	Temperature              *Temperature
+	TemperatureApparent      *TemperatureApparent
+	DewPoint                 *DewPoint
+	Humidity                 *Humidity
+	WindSpeed                *WindSpeed
+	WindDirection            *WindDirection
+	WindGust                 *WindGust
+	PressureSurfaceLevel     *PressureSurfaceLevel
+	PressureSeaLevel         *PressureSeaLevel
+	PrecipitationIntensity   *PrecipitationIntensity
+	PrecipitationProbability *PrecipitationProbability
+	PrecipitationType        *PrecipitationType
+	SolarGHI                 *SolarGHI
+	Visibility               *Visibility
+	CloudCover               *CloudCover
+	CloudBase                *CloudBase
+	CloudCeiling             *CloudCeiling
+
+	// Sunrise and Sunset are the times of the sunrise and sunset.
+	Sunrise *TimeValue
+	Sunset  *TimeValue
+	// MoonPhase is the phase of the moon, whose label is given by the
+	// "moonPhase" field's Labels.
+	MoonPhase *IntValue
+	// WeatherCode is the code of the day's weather, whose label is given
+	// by the "weatherCode" field's Labels.
+	WeatherCode *IntValue
+
+	// Values are all of the day's values, including the fields without a
+	// typed field.
+	Values Values
+
+	// the unit system of the values
+	units string
+}
+
+// NewDailyForecast returns the daily forecast of an interval of a 1d
+// timeline, whose values are in the given unit system, either "metric" or
+// "imperial", for the units of the values. The unit system defaults to
+// metric. An error is returned if an aggregate's value has the wrong type.
+func NewDailyForecast(iv Interval, system string) (DailyForecast, error) {
+	d := DailyForecast{Time: iv.StartTime, Values: iv.Values, units: system}
+	raw, err := json.Marshal(iv.Values)
+	if err != nil {
+		return DailyForecast{}, errors.WithMessage(err, "encoding daily values")
+	}
+
+	var decodeErr error
+	decode := func(base string, v interface{}) bool {
+		if decodeErr != nil || !hasAggregate(iv.Values, base) {
+			return false
+		}
+		if err := json.Unmarshal(raw, v); err != nil {
+			decodeErr = errors.WithMessagef(err, "decoding daily %s", base)
+			return false
+		}
+		return true
+	}
+	d.Temperature = decodeAggregate[Temperature](decode, "temperature")
+	d.TemperatureApparent = decodeAggregate[TemperatureApparent](decode, "temperatureApparent")
+	d.DewPoint = decodeAggregate[DewPoint](decode, "dewPoint")
+	d.Humidity = decodeAggregate[Humidity](decode, "humidity")
+	d.WindSpeed = decodeAggregate[WindSpeed](decode, "windSpeed")
+	d.WindDirection = decodeAggregate[WindDirection](decode, "windDirection")
+	d.WindGust = decodeAggregate[WindGust](decode, "windGust")
+	d.PressureSurfaceLevel = decodeAggregate[PressureSurfaceLevel](decode, "pressureSurfaceLevel")
+	d.PressureSeaLevel = decodeAggregate[PressureSeaLevel](decode, "pressureSeaLevel")
+	d.PrecipitationIntensity = decodeAggregate[PrecipitationIntensity](decode, "precipitationIntensity")
+	d.PrecipitationProbability = decodeAggregate[PrecipitationProbability](decode, "precipitationProbability")
+	d.PrecipitationType = decodeAggregate[PrecipitationType](decode, "precipitationType")
+	d.SolarGHI = decodeAggregate[SolarGHI](decode, "solarGHI")
+	d.Visibility = decodeAggregate[Visibility](decode, "visibility")
+	d.CloudCover = decodeAggregate[CloudCover](decode, "cloudCover")
+	d.CloudBase = decodeAggregate[CloudBase](decode, "cloudBase")
+	d.CloudCeiling = decodeAggregate[CloudCeiling](decode, "cloudCeiling")
+	if decodeErr != nil {
+		return DailyForecast{}, decodeErr
+	}
+
+	var celestial struct {
+		SunriseTime
+		SunsetTime
+	}
+	if err := json.Unmarshal(raw, &celestial); err != nil {
+		return DailyForecast{}, errors.WithMessage(err, "decoding daily sunrise and sunset")
+	}
+	if _, ok := iv.Values["sunriseTime"]; ok {
+		d.Sunrise = &TimeValue{Value: &celestial.SunriseTime.SunriseTime}
+	}
+	if _, ok := iv.Values["sunsetTime"]; ok {
+		d.Sunset = &TimeValue{Value: &celestial.SunsetTime.SunsetTime}
+	}
+	if v, ok := iv.Values.Int("moonPhase"); ok {
+		d.MoonPhase = &IntValue{Value: &v}
+	}
+	if v, ok := iv.Values.Int("weatherCode"); ok {
+		d.WeatherCode = &IntValue{Value: &v}
+	}
+	return d, nil
+}
+
+// decodeAggregate decodes the aggregates of a field into a new T, or returns
+// nil if there are none.
+func decodeAggregate[T any](decode func(base string, v interface{}) bool, base string) *T {
+	v := new(T)
+	if !decode(base, v) {
+		return nil
+	}
+	return v
+}
+
+func hasAggregate(values Values, base string) bool {
+	for _, suffix := range []string{"Max", "Min", "Avg", "MaxTime", "MinTime"} {
+		if _, ok := values[base+suffix]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Max returns the daily maximum of a field like "temperature", with the time
+// it is reached if "<field>MaxTime" was requested, or nil if the maximum
+// wasn't requested or returned.
+func (d *DailyForecast) Max(field string) *FloatAtTimeValue {
+	return d.extreme(field, "Max")
+}
+
+// Min returns the daily minimum of a field, like Max.
+func (d *DailyForecast) Min(field string) *FloatAtTimeValue {
+	return d.extreme(field, "Min")
+}
+
+// Avg returns the daily average of a field, or nil if the average wasn't
+// requested or returned.
+func (d *DailyForecast) Avg(field string) *FloatValue {
+	v, ok := d.Values.Float(field + "Avg")
+	if !ok {
+		return nil
+	}
+	return &FloatValue{Value: &v, Units: unitsIn(field, d.units)}
+}
+
+// MinAndMax returns the daily minimum and maximum of a field in the form of
+// a v3 ForecastMinAndMax, to ease moving from v3 daily forecasts:
+//
+//	highTemp, ok := f.Temp.Max().GetValue()                    // v3
+//	highTemp, ok := d.MinAndMax("temperature").Max().GetValue() // v4
+func (d *DailyForecast) MinAndMax(field string) ForecastMinAndMax {
+	var res ForecastMinAndMax
+	if min := d.Min(field); min != nil {
+		res = append(res, ForecastJSONMinMax{ObservationTime: min.ObservationTime, Min: min.Value})
+	}
+	if max := d.Max(field); max != nil {
+		res = append(res, ForecastJSONMinMax{ObservationTime: max.ObservationTime, Max: max.Value})
+	}
+	return res
+}
+
+func (d *DailyForecast) extreme(field, suffix string) *FloatAtTimeValue {
+	v, ok := d.Values.Float(field + suffix)
+	if !ok {
+		return nil
+	}
+	t, _ := d.Values.Time(field + suffix + "Time")
+	return &FloatAtTimeValue{
+		ObservationTime: t,
+		Value:           &FloatValue{Value: &v, Units: unitsIn(field, d.units)},
+	}
+}
+
+// DailyForecast returns the daily forecast for a location, which is a LatLon
+// or a LocationID. The DefaultDailyFields are requested if no fields are
+// given.
+func (c *ClientV4) DailyForecast(ctx context.Context, location Location, fields ...string) ([]DailyForecast, error) {
+	if len(fields) == 0 {
+		fields = DefaultDailyFields
+	}
+	intervals, err := c.intervals(ctx, location, "1d", fields)
+	if err != nil && !isWarnings(err) {
+		return nil, err
+	}
+	days := make([]DailyForecast, len(intervals))
+	for i, iv := range intervals {
+		day, dayErr := NewDailyForecast(iv, "")
+		if dayErr != nil {
+			return nil, errors.WithMessagef(dayErr, "day %s", iv.StartTime.Format("2006-01-02"))
+		}
+		days[i] = day
+	}
+	return days, err
+}
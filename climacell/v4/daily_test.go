@@ -0,0 +1,92 @@
+package climacell
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var dailyTimelines = `{"data": {"timelines": [{"timestep": "1d", "intervals": [
+	{"startTime": "2020-12-21T11:00:00Z", "values": {
+		"temperatureMax": 12.5, "temperatureMaxTime": "2020-12-21T19:00:00Z",
+		"temperatureMin": 0, "temperatureMinTime": "2020-12-22T10:00:00Z",
+		"windDirectionAvg": 270,
+		"sunriseTime": "2020-12-21T12:22:00Z", "sunsetTime": "2020-12-21T22:10:00Z",
+		"moonPhase": 1, "weatherCode": 1001, "treeOak": 0
+	}},
+	{"startTime": "2020-12-22T11:00:00Z", "values": {"temperatureMax": 9.1}}
+]}]}}`
+
+func TestDailyForecast(t *testing.T) {
+	var fields []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var opts TimelineListOptions
+		json.NewDecoder(r.Body).Decode(&opts)
+		fields = opts.Fields
+		w.Write([]byte(dailyTimelines))
+	}))
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+
+	days, err := client.DailyForecast(context.Background(), LatLon{Lat: 35.8, Lon: -78.6})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, DefaultDailyFields, fields)
+	require.Len(t, days, 2)
+
+	d := days[0]
+	assert.Equal(t, time.Date(2020, 12, 21, 11, 0, 0, 0, time.UTC), d.Time)
+	require.NotNil(t, d.Temperature)
+	assert.Equal(t, 12.5, d.Temperature.TemperatureMax)
+	assert.Equal(t, time.Date(2020, 12, 22, 10, 0, 0, 0, time.UTC), d.Temperature.TemperatureMinTime)
+	assert.Nil(t, d.Humidity)
+
+	high, ok := d.Max("temperature").GetValue()
+	assert.True(t, ok)
+	assert.Equal(t, 12.5, high)
+	units, _ := d.Max("temperature").GetUnits()
+	assert.Equal(t, "C", units)
+	assert.Equal(t, time.Date(2020, 12, 21, 19, 0, 0, 0, time.UTC), d.Max("temperature").ObservationTime)
+	// a low of zero is still a value
+	low, ok := d.MinAndMax("temperature").Min().GetValue()
+	assert.True(t, ok)
+	assert.Zero(t, low)
+	_, ok = d.Max("humidity").GetValue()
+	assert.False(t, ok)
+	dir, ok := d.Avg("windDirection").GetValue()
+	assert.True(t, ok)
+	assert.Equal(t, 270.0, dir)
+	assert.Nil(t, d.Avg("temperature"))
+
+	sunrise, ok := d.Sunrise.GetValue()
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2020, 12, 21, 12, 22, 0, 0, time.UTC), sunrise)
+	phase, _ := d.MoonPhase.GetValue()
+	assert.Equal(t, 1, phase)
+	code, _ := d.WeatherCode.GetValue()
+	assert.Equal(t, 1001, code)
+
+	next := days[1]
+	_, ok = next.Sunset.GetValue()
+	assert.False(t, ok)
+	assert.Nil(t, next.MinAndMax("temperature").Min())
+	high, _ = next.MinAndMax("temperature").Max().GetValue()
+	assert.Equal(t, 9.1, high)
+}
+
+func TestNewDailyForecast(t *testing.T) {
+	d, err := NewDailyForecast(Interval{Values: Values{"windSpeedMax": 10.0}}, "imperial")
+	require.NoError(t, err)
+	units, _ := d.Max("windSpeed").GetUnits()
+	assert.Equal(t, "mph", units)
+
+	_, err = NewDailyForecast(Interval{Values: Values{"temperatureMax": "hot"}}, "")
+	assert.ErrorContains(t, err, "decoding daily temperature")
+}
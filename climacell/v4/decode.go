@@ -0,0 +1,413 @@
+package climacell
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DecodeMode sets how the responses of the ClimaCell API are decoded.
+type DecodeMode int
+
+const (
+	// DecodeDefault decodes responses like encoding/json: fields that
+	// aren't in the types they are decoded to are ignored, and a value of
+	// the wrong type fails the whole response.
+	DecodeDefault DecodeMode = iota
+	// DecodeStrict fails a response with a *DecodeError if it has a field
+	// that isn't in the type it is decoded to, or a value of the wrong type,
+	// so that changes to the API's schema are detected instead of losing
+	// fields. The fields kept in an Interval's Extra aren't unknown.
+	DecodeStrict
+	// DecodeLenient decodes as much of a response as it can, leaving the
+	// values that couldn't be decoded as their zero values, and returns the
+	// problems with the response as DecodeWarnings along with the data.
+	DecodeLenient
+)
+
+// ErrUnknownField is the error of a DecodeError for a field that isn't in the
+// type it is decoded to.
+var ErrUnknownField = errors.New("unknown field")
+
+// DecodeError is a value of a response that couldn't be decoded.
+type DecodeError struct {
+	// Path is the path to the value in the response, like
+	// "data.timelines[0].intervals[3].startTime".
+	Path string
+	// Err is why the value couldn't be decoded, which is ErrUnknownField
+	// for a field that isn't in the type it is decoded to.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error of a DecodeError.
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// DecodeWarnings are the values of a response that couldn't be decoded in
+// DecodeLenient mode. They are returned as the error of a call along with the
+// data that could be decoded, and can be told apart from other errors with
+// errors.As:
+//
+//	timelines, err := c.GetTimelines(ctx, options)
+//	var warnings climacell.DecodeWarnings
+//	if errors.As(err, &warnings) {
+//		/* log the warnings, and work with the timelines */
+//	} else if err != nil {
+//		/* handle the error */
+//	}
+type DecodeWarnings []*DecodeError
+
+func (w DecodeWarnings) Error() string {
+	msgs := make([]string, len(w))
+	for i, e := range w {
+		msgs[i] = e.Error()
+	}
+	if len(w) == 1 {
+		return "1 decode warning: " + msgs[0]
+	}
+	return fmt.Sprintf("%d decode warnings: %s", len(w), strings.Join(msgs, "; "))
+}
+
+// isWarnings returns whether err is only DecodeWarnings, in which case the
+// data of a call is still returned.
+func isWarnings(err error) bool {
+	_, ok := err.(DecodeWarnings)
+	return ok
+}
+
+// Unmarshal decodes JSON data into v in this mode. In DecodeLenient mode,
+// the error is DecodeWarnings if any values couldn't be decoded, and v holds
+// the rest of the data.
+func (m DecodeMode) Unmarshal(data []byte, v interface{}) error {
+	return m.unmarshal("", data, v)
+}
+
+// unmarshal decodes data into v like Unmarshal, with the paths of errors
+// starting at root.
+func (m DecodeMode) unmarshal(root string, data []byte, v interface{}) error {
+	if m == DecodeDefault || !json.Valid(data) {
+		// invalid JSON fails with encoding/json's syntax error in any
+		// mode
+		return json.Unmarshal(data, v)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &json.InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+	d := decoder{mode: m}
+	if err := d.decode(root, bytes.TrimSpace(data), rv.Elem()); err != nil {
+		return err
+	}
+	if len(d.warnings) > 0 {
+		return d.warnings
+	}
+	return nil
+}
+
+// decoder walks JSON data alongside the value it is decoded to, so that the
+// path to each problem is known.
+type decoder struct {
+	mode     DecodeMode
+	warnings DecodeWarnings
+}
+
+var (
+	jsonUnmarshaler  = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler  = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	extraFielderType = reflect.TypeOf((*extraFielder)(nil)).Elem()
+)
+
+// extraFielder is implemented by structs like Interval that keep the fields
+// they don't model in a map. decode walks into them like other structs
+// instead of calling their UnmarshalJSON, and keeps their unmodelled fields
+// in the map rather than reporting them as unknown.
+type extraFielder interface {
+	extraFields() *map[string]json.RawMessage
+}
+
+// fail handles a value that couldn't be decoded, failing the decoding in
+// strict mode, or adding a warning in lenient mode.
+func (d *decoder) fail(path string, err error) error {
+	if path == "" {
+		path = "."
+	}
+	e := &DecodeError{Path: path, Err: err}
+	if d.mode == DecodeStrict {
+		return e
+	}
+	d.warnings = append(d.warnings, e)
+	return nil
+}
+
+func (d *decoder) decode(path string, data []byte, v reflect.Value) error {
+	if string(data) == "null" {
+		// null leaves values as they are, or sets pointers, maps, slices
+		// and interfaces to nil, the way encoding/json does
+		return json.Unmarshal(data, v.Addr().Interface())
+	}
+
+	t := v.Type()
+	if !walked(t) && t.Kind() != reflect.Ptr {
+		return d.leaf(path, data, v)
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(t.Elem())
+		if !v.IsNil() {
+			elem = v
+		}
+		n := len(d.warnings)
+		if err := d.decode(path, data, elem.Elem()); err != nil {
+			return err
+		}
+		if len(d.warnings) > n && !walked(t.Elem()) {
+			// a pointer to a value that couldn't be decoded is left nil,
+			// so that the value is absent rather than zero
+			return nil
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Struct:
+		return d.decodeStruct(path, data, v)
+	case reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return d.fail(path, typeError(data, t))
+		}
+		s := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			if err := d.decode(path+"["+strconv.Itoa(i)+"]", item, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	case reflect.Map:
+		var items map[string]json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return d.fail(path, typeError(data, t))
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(t, len(items)))
+		}
+		for _, key := range sortedKeys(items) {
+			elem := reflect.New(t.Elem()).Elem()
+			if err := d.decode(join(path, key), items[key], elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+		}
+		return nil
+	}
+	return nil
+}
+
+// walked returns whether values of a type are walked into by decode, rather
+// than decoded as a whole by leaf.
+func walked(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(extraFielderType) {
+		return true
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshaler) || reflect.PtrTo(t).Implements(textUnmarshaler) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return walked(t.Elem())
+	case reflect.Struct, reflect.Slice:
+		return true
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String
+	}
+	return false
+}
+
+// leaf decodes a value that isn't walked into, like a number, a string or a
+// type with its own UnmarshalJSON, with encoding/json.
+func (d *decoder) leaf(path string, data []byte, v reflect.Value) error {
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			err = typeError(data, v.Type())
+		}
+		return d.fail(path, err)
+	}
+	v.Set(ptr.Elem())
+	return nil
+}
+
+func (d *decoder) decodeStruct(path string, data []byte, v reflect.Value) error {
+	var items map[string]json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return d.fail(path, typeError(data, v.Type()))
+	}
+
+	fields := structFields(v.Type())
+	extra, _ := v.Addr().Interface().(extraFielder)
+	for _, key := range sortedKeys(items) {
+		f, ok := lookupField(fields, key)
+		if !ok && extra != nil {
+			m := extra.extraFields()
+			if *m == nil {
+				*m = map[string]json.RawMessage{}
+			}
+			(*m)[key] = items[key]
+			continue
+		}
+		if !ok {
+			if err := d.fail(join(path, key), ErrUnknownField); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decode(join(path, key), items[key], fieldByIndex(v, f.index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type structField struct {
+	index []int
+	depth int
+}
+
+// structFields returns the fields of a struct type by their JSON names,
+// including the fields of embedded structs. Like encoding/json, a field of an
+// embedded struct is hidden by a field of the same name at a shallower depth,
+// and fields of the same name at the same depth hide each other.
+func structFields(t reflect.Type) map[string]structField {
+	fields := map[string]structField{}
+	conflicts := map[string]int{}
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name := strings.Split(tag, ",")[0]
+			idx := append(append([]int{}, index...), i)
+
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+				walk(ft, idx)
+				continue
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+
+			depth := len(idx)
+			if d, ok := conflicts[name]; ok && d <= depth {
+				continue
+			}
+			if f, ok := fields[name]; ok {
+				if f.depth < depth {
+					continue
+				}
+				if f.depth == depth {
+					delete(fields, name)
+					conflicts[name] = depth
+					continue
+				}
+			}
+			fields[name] = structField{index: idx, depth: depth}
+		}
+	}
+	walk(t, nil)
+	return fields
+}
+
+// lookupField returns the field of a struct for a JSON key, matching field
+// names case-insensitively like encoding/json.
+func lookupField(fields map[string]structField, key string) (structField, bool) {
+	if f, ok := fields[key]; ok {
+		return f, true
+	}
+	for name, f := range fields {
+		if strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return structField{}, false
+}
+
+// fieldByIndex returns the field of a struct at an index, allocating the
+// embedded struct pointers on the way to it.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// typeError describes a JSON value that doesn't match the type it is decoded
+// to.
+func typeError(data []byte, t reflect.Type) error {
+	var kind string
+	switch data[0] {
+	case '{':
+		kind = "object"
+	case '[':
+		kind = "array"
+	case '"':
+		kind = "string"
+	case 't', 'f':
+		kind = "bool"
+	default:
+		kind = "number"
+	}
+	return errors.Errorf("cannot decode %s %s into %s", kind, truncate(data), t)
+}
+
+func truncate(data []byte) string {
+	const max = 40
+	if len(data) > max {
+		return string(data[:max]) + "..."
+	}
+	return string(data)
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,125 @@
+package climacell
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var driftedTimelines = `{"data": {"timelines": [{"timestep": "1h", "source": "new", "intervals": [
+	{"startTime": "2020-12-21T06:00:00Z", "values": {"temperature": 15.1}},
+	{"startTime": "yesterday", "values": {"temperature": 14.2}},
+	{"startTime": "2020-12-21T08:00:00Z", "values": "none"}
+]}]}}`
+
+func driftedServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(driftedTimelines))
+	}))
+}
+
+func TestDecodeStrict(t *testing.T) {
+	server := driftedServer()
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+	client.DecodeMode = DecodeStrict
+
+	_, err := client.GetTimelines(context.Background(), &TimelineListOptions{})
+	var decodeErr *DecodeError
+	require.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, "data.timelines[0].intervals[1].startTime", decodeErr.Path)
+
+	var tl Timeline
+	err = DecodeStrict.Unmarshal([]byte(`{"timestep": "1h", "source": "new"}`), &tl)
+	assert.EqualError(t, err, "source: unknown field")
+	assert.True(t, errors.Is(err, ErrUnknownField))
+
+	require.NoError(t, DecodeStrict.Unmarshal([]byte(`{"Timestep": "1h", "intervals": null}`), &tl))
+	assert.Equal(t, "1h", tl.Timestep)
+}
+
+func TestDecodeLenient(t *testing.T) {
+	server := driftedServer()
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+	client.DecodeMode = DecodeLenient
+
+	list, err := client.GetTimelines(context.Background(), &TimelineListOptions{})
+	var warnings DecodeWarnings
+	require.True(t, errors.As(err, &warnings))
+	require.Len(t, warnings, 3)
+	assert.Equal(t, "data.timelines[0].intervals[1].startTime", warnings[0].Path)
+	assert.Equal(t, "data.timelines[0].intervals[2].values", warnings[1].Path)
+	assert.EqualError(t, warnings[1].Err, `cannot decode string "none" into climacell.Values`)
+	assert.Equal(t, "data.timelines[0].source", warnings[2].Path)
+
+	// the rest of the response is kept
+	require.NotNil(t, list)
+	intervals := list.Timelines[0].Intervals
+	require.Len(t, intervals, 3)
+	assert.Equal(t, time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC), intervals[0].StartTime)
+	assert.True(t, intervals[1].StartTime.IsZero())
+	temp, _ := intervals[1].Values.Float("temperature")
+	assert.Equal(t, 14.2, temp)
+	assert.Nil(t, intervals[2].Values)
+
+	hourly, err := client.Hourly(context.Background(), LatLon{Lat: 35.8, Lon: -78.6})
+	assert.True(t, errors.As(err, &warnings))
+	assert.Len(t, hourly, 3)
+}
+
+func TestDecodeLenientV3(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+			{"observation_time": {"value": "2020-12-21T06:00:00Z"}, "temperature": {"value": 15.1, "units": "C"}},
+			{"observation_time": {"value": "12/21/2020"}, "temperature": {"value": "warm", "units": "C"}}
+		]`))
+	}))
+	defer server.Close()
+
+	client := New("test_api_key")
+	client.baseURL = server.URL
+	client.DecodeMode = DecodeLenient
+
+	samples, err := client.HourlyForecast(ForecastArgs{})
+	var warnings DecodeWarnings
+	require.True(t, errors.As(err, &warnings))
+	require.Len(t, warnings, 2)
+	assert.EqualError(t, warnings[0], `[1].observation_time: "12/21/2020" is neither an RFC3339 timestamp nor a YYYY-MM-DD date`)
+	assert.Equal(t, "[1].temperature.value", warnings[1].Path)
+
+	require.Len(t, samples, 2)
+	temp, ok := samples[0].Temp.GetValue()
+	assert.True(t, ok)
+	assert.Equal(t, 15.1, temp)
+	// a value that couldn't be decoded is absent rather than zero
+	_, ok = samples[1].Temp.GetValue()
+	assert.False(t, ok)
+	assert.Equal(t, "C", samples[1].Temp.Units)
+
+	client.DecodeMode = DecodeStrict
+	_, err = client.HourlyForecast(ForecastArgs{})
+	assert.EqualError(t, err, `deserializing weather response data: [1].observation_time: "12/21/2020" is neither an RFC3339 timestamp nor a YYYY-MM-DD date`)
+}
+
+func TestDecodeEmbedded(t *testing.T) {
+	var sample HourlyForecast
+	err := DecodeStrict.Unmarshal([]byte(`{"lat": 35.8, "lon": -78.6, "location_id": "home", "humidity": {"value": 80, "units": "%"}}`), &sample)
+	require.NoError(t, err)
+	assert.Equal(t, 35.8, sample.Lat)
+	assert.Equal(t, LocationID("home"), sample.LocationId)
+	assert.Equal(t, "80%", sample.Humidity.String())
+
+	assert.EqualError(t, DecodeStrict.Unmarshal([]byte(`[1]`), &sample), ".: cannot decode array [1] into climacell.HourlyForecast")
+	assert.Error(t, DecodeLenient.Unmarshal([]byte(`{`), &sample))
+}
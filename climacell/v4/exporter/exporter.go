@@ -0,0 +1,308 @@
+// Package exporter exposes current ClimaCell conditions as Prometheus
+// metrics.
+//
+// An Exporter periodically polls ClientV4.GetTimelines for the "current"
+// timestep at each of a list of sites, and reports every float and int field
+// of the field registry as a gauge named after the field, such as
+// climacell_temperature or climacell_wind_speed, labelled with the site's
+// name and the field's units. Enum fields, whose codes can't be averaged or
+// compared, are reported as info metrics instead, like
+// climacell_weather_code_info{site="hq",code="1000",label="Clear"} 1.
+// It also reports metrics about the API client itself: the number of
+// requests and errors, the latency of requests, and the request quota
+// remaining as reported by the API's rate limit headers.
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// Namespace is the prefix of every metric an Exporter reports.
+const Namespace = "climacell"
+
+// DefaultInterval is the time between polls of an Exporter's sites.
+const DefaultInterval = 5 * time.Minute
+
+// Site is a location whose current conditions are exported.
+type Site struct {
+	// Name is the value of the "site" label of the site's metrics.
+	Name string
+	// Location is the geometry of the site. It is ignored if LocationID
+	// is set.
+	Location climacell.Geometry
+	// LocationID is the ID of a location saved with the Locations API.
+	LocationID string
+}
+
+// Exporter polls the current conditions at a list of sites, and is a
+// prometheus.Collector reporting them along with the client's metrics.
+//
+// Since the set of weather metrics depends on the fields the API returns,
+// an Exporter is an unchecked collector: it describes no metrics up front.
+type Exporter struct {
+	// Fields are the fields requested for each site.
+	Fields []string
+	// Units, if set, is the unit system to request data in, either
+	// "metric" or "imperial". It determines the "unit" label of the
+	// weather metrics.
+	Units string
+	// Interval is the time between polls in Run. It defaults to
+	// DefaultInterval.
+	Interval time.Duration
+
+	client *climacell.ClientV4
+	sites  []Site
+
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	quota    *prometheus.GaugeVec
+	success  *prometheus.GaugeVec
+
+	mu      sync.Mutex
+	current map[string]climacell.Values
+}
+
+// New returns an Exporter that polls the given fields at each site using c.
+// The transport of c's HTTPClient is wrapped to record the request, latency
+// and quota metrics, so c should not be shared with requests that shouldn't
+// be counted.
+func New(c *climacell.ClientV4, sites []Site, fields []string) *Exporter {
+	e := &Exporter{
+		Fields:   fields,
+		Interval: DefaultInterval,
+		client:   c,
+		sites:    sites,
+		current:  map[string]climacell.Values{},
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "api_requests_total",
+			Help:      "Number of requests sent to the ClimaCell API, by HTTP status code.",
+		}, []string{"code"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "api_errors_total",
+			Help:      "Number of failed polls, by ClimaCell API error code.",
+		}, []string{"code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "api_request_duration_seconds",
+			Help:      "Latency of requests to the ClimaCell API.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"code"}),
+		quota: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "api_quota_remaining",
+			Help:      "Requests remaining in each rate limit window, as last reported by the API.",
+		}, []string{"window"}),
+		success: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Unix time of the last successful poll of a site.",
+		}, []string{"site"}),
+	}
+
+	hc := http.Client{}
+	if c.HTTPClient != nil {
+		hc = *c.HTTPClient
+	}
+	next := hc.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	hc.Transport = &instrumentedTransport{next: next, e: e}
+	c.HTTPClient = &hc
+	return e
+}
+
+// Run polls every site immediately and then once every Interval, until ctx
+// is canceled. Failed polls are counted in the errors metric and retried at
+// the next interval.
+func (e *Exporter) Run(ctx context.Context) error {
+	interval := e.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		e.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll fetches the current conditions at every site, returning the first
+// error encountered. Sites that fail keep reporting the conditions from
+// their last successful poll.
+func (e *Exporter) Poll(ctx context.Context) error {
+	var first error
+	for _, site := range e.sites {
+		if err := e.poll(ctx, site); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			e.errors.WithLabelValues(errorCode(err)).Inc()
+			if first == nil {
+				first = errors.WithMessagef(err, "polling %s", site.Name)
+			}
+		}
+	}
+	return first
+}
+
+func (e *Exporter) poll(ctx context.Context, site Site) error {
+	opts := &climacell.TimelineListOptions{
+		Location:   site.Location,
+		LocationID: site.LocationID,
+		Fields:     e.Fields,
+		TimeSteps:  []string{"current"},
+		Units:      e.Units,
+	}
+	list, err := e.client.GetTimelines(ctx, opts)
+	if err != nil {
+		return err
+	}
+	for _, t := range list.Timelines {
+		if t.Timestep != "current" || len(t.Intervals) == 0 {
+			continue
+		}
+		e.mu.Lock()
+		e.current[site.Name] = t.Intervals[0].Values
+		e.mu.Unlock()
+		e.success.WithLabelValues(site.Name).SetToCurrentTime()
+		return nil
+	}
+	return errors.New("response has no current timeline")
+}
+
+// errorCode returns the label of the errors metric for err: the ClimaCell
+// API's error code, or the HTTP status code if the API didn't return one,
+// or "network" if the request didn't get a response.
+func errorCode(err error) string {
+	var errRes *climacell.ErrorResponse
+	if !errors.As(err, &errRes) {
+		return "network"
+	}
+	if errRes.ErrorCode != "" && errRes.ErrorCode != "0" {
+		return errRes.ErrorCode
+	}
+	return strconv.Itoa(errRes.StatusCode)
+}
+
+// Describe implements prometheus.Collector. It sends no descriptors, making
+// the Exporter an unchecked collector.
+func (e *Exporter) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.requests.Collect(ch)
+	e.errors.Collect(ch)
+	e.latency.Collect(ch)
+	e.quota.Collect(ch)
+	e.success.Collect(ch)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sites := make([]string, 0, len(e.current))
+	for site := range e.current {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+
+	descs := map[string]*prometheus.Desc{}
+	for _, site := range sites {
+		values := e.current[site]
+		for field := range values {
+			f, ok := climacell.LookupField(field)
+			if !ok {
+				continue
+			}
+			switch f.Kind {
+			case climacell.FieldFloat, climacell.FieldInt:
+				v, ok := values.Float(field)
+				if !ok {
+					continue
+				}
+				desc, ok := descs[field]
+				if !ok {
+					desc = prometheus.NewDesc(MetricName(field), "Current value of the ClimaCell "+field+" field.", []string{"site", "unit"}, nil)
+					descs[field] = desc
+				}
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, site, f.UnitsIn(e.Units))
+			case climacell.FieldEnum:
+				code, ok := values.Int(field)
+				if !ok {
+					continue
+				}
+				desc, ok := descs[field]
+				if !ok {
+					desc = prometheus.NewDesc(MetricName(field)+"_info", "Current code and label of the ClimaCell "+field+" field.", []string{"site", "code", "label"}, nil)
+					descs[field] = desc
+				}
+				label, _ := f.Label(code)
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, site, strconv.Itoa(code), label)
+			}
+		}
+	}
+}
+
+// MetricName returns the name of the gauge a field is reported as, like
+// "climacell_precipitation_intensity" for "precipitationIntensity" or
+// "climacell_pollutant_no2" for "pollutantNO2".
+func MetricName(field string) string {
+	var b strings.Builder
+	b.WriteString(Namespace)
+	b.WriteByte('_')
+	prev := rune(0)
+	for _, r := range field {
+		if unicode.IsUpper(r) {
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			b.WriteRune(r)
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+// instrumentedTransport records the request, latency and quota metrics of
+// every request sent through it.
+type instrumentedTransport struct {
+	next http.RoundTripper
+	e    *Exporter
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(res.StatusCode)
+		for window, n := range climacell.QuotaRemaining(res.Header) {
+			t.e.quota.WithLabelValues(window).Set(float64(n))
+		}
+	}
+	t.e.requests.WithLabelValues(code).Inc()
+	t.e.latency.WithLabelValues(code).Observe(time.Since(start).Seconds())
+	return res, err
+}
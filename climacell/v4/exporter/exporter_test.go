@@ -0,0 +1,100 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+func currentHandler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var opts map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&opts))
+		if opts["location"] == "bad" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"code": 403003, "type": "Forbidden", "message": "location not allowed"}`))
+			return
+		}
+
+		w.Header().Set("X-RateLimit-Remaining-Hour", "24")
+		w.Header().Set("X-RateLimit-Remaining-Day", "499")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"timelines": [{
+			"timestep": "current",
+			"startTime": "2020-12-21T06:00:00Z",
+			"endTime": "2020-12-21T06:00:00Z",
+			"intervals": [{"startTime": "2020-12-21T06:00:00Z", "values": {
+				"temperature": 15.1,
+				"windSpeed": 3.5,
+				"weatherCode": 1000,
+				"sunriseTime": "2020-12-21T12:10:00Z"
+			}}]
+		}]}}`))
+	})
+}
+
+func TestExporter(t *testing.T) {
+	server := httptest.NewServer(currentHandler(t))
+	defer server.Close()
+
+	c := climacell.NewClient("test_api_key")
+	c.BaseURL = server.URL
+	e := New(c, []Site{
+		{Name: "hq", Location: climacell.Geometry{Type: "Point", Coordinates: []float64{-78.6, 35.8}}},
+		{Name: "lab", LocationID: "bad"},
+	}, []string{"temperature", "windSpeed", "weatherCode", "sunriseTime"})
+	e.Units = "imperial"
+
+	err := e.Poll(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "polling lab")
+
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(e))
+
+	expected := `
+# HELP climacell_temperature Current value of the ClimaCell temperature field.
+# TYPE climacell_temperature gauge
+climacell_temperature{site="hq",unit="F"} 15.1
+# HELP climacell_wind_speed Current value of the ClimaCell windSpeed field.
+# TYPE climacell_wind_speed gauge
+climacell_wind_speed{site="hq",unit="mph"} 3.5
+# HELP climacell_weather_code_info Current code and label of the ClimaCell weatherCode field.
+# TYPE climacell_weather_code_info gauge
+climacell_weather_code_info{code="1000",label="Clear",site="hq"} 1
+# HELP climacell_api_errors_total Number of failed polls, by ClimaCell API error code.
+# TYPE climacell_api_errors_total counter
+climacell_api_errors_total{code="403003"} 1
+# HELP climacell_api_quota_remaining Requests remaining in each rate limit window, as last reported by the API.
+# TYPE climacell_api_quota_remaining gauge
+climacell_api_quota_remaining{window="day"} 499
+climacell_api_quota_remaining{window="hour"} 24
+# HELP climacell_api_requests_total Number of requests sent to the ClimaCell API, by HTTP status code.
+# TYPE climacell_api_requests_total counter
+climacell_api_requests_total{code="200"} 1
+climacell_api_requests_total{code="403"} 1
+`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"climacell_temperature", "climacell_wind_speed", "climacell_weather_code", "climacell_weather_code_info",
+		"climacell_api_errors_total", "climacell_api_quota_remaining", "climacell_api_requests_total"))
+
+	assert.Equal(t, 2, testutil.CollectAndCount(e.latency))
+	assert.Equal(t, 1, testutil.CollectAndCount(e.success))
+}
+
+func TestMetricName(t *testing.T) {
+	assert.Equal(t, "climacell_precipitation_intensity", MetricName("precipitationIntensity"))
+	assert.Equal(t, "climacell_temperature_max", MetricName("temperatureMax"))
+	assert.Equal(t, "climacell_pollutant_no2", MetricName("pollutantNO2"))
+	assert.Equal(t, "climacell_solar_ghi", MetricName("solarGHI"))
+}
@@ -0,0 +1,274 @@
+package climacell
+
+import (
+	"sort"
+	"strings"
+)
+
+// FieldKind describes the type of the values the ClimaCell API returns for a
+// field.
+type FieldKind int
+
+const (
+	// FieldFloat fields are floating-point measurements, like temperature.
+	FieldFloat FieldKind = iota
+	// FieldInt fields are integer measurements, like an air quality index.
+	FieldInt
+	// FieldEnum fields are integer codes with a label for each code, like
+	// weatherCode or moonPhase.
+	FieldEnum
+	// FieldTime fields are timestamps, like sunriseTime.
+	FieldTime
+)
+
+// String returns the name of this FieldKind.
+func (k FieldKind) String() string {
+	switch k {
+	case FieldFloat:
+		return "float"
+	case FieldInt:
+		return "int"
+	case FieldEnum:
+		return "enum"
+	case FieldTime:
+		return "time"
+	}
+	return "unknown"
+}
+
+// Field groups, used to categorize the fields in the registry.
+const (
+	GroupWeather   = "weather"
+	GroupAir       = "airQuality"
+	GroupPollen    = "pollen"
+	GroupFire      = "fire"
+	GroupSolar     = "solar"
+	GroupCelestial = "celestial"
+)
+
+// Field describes a single data layer that can be requested from the
+// ClimaCell v4 API, such as "temperature" or "weatherCode".
+type Field struct {
+	// Name is the name of the field, as it appears in the Fields of a
+	// TimelineListOptions and in the keys of an interval's Values.
+	Name string
+	// Kind is the type of the values for this field.
+	Kind FieldKind
+	// Units is the unit of measure for this field's values in the metric
+	// unit system. It is empty for enum and time fields.
+	Units string
+	// Group is the category this field belongs to, like GroupWeather or
+	// GroupPollen.
+	Group string
+	// Labels contains the description for each code of an enum field.
+	Labels map[int]string
+}
+
+// Label returns the description for an enum field's code, and a true "ok" if
+// the code is known for this field.
+func (f Field) Label(code int) (label string, ok bool) {
+	label, ok = f.Labels[code]
+	return label, ok
+}
+
+// Numeric returns whether this field's values are numbers that can be
+// aggregated, compared or converted between units.
+func (f Field) Numeric() bool { return f.Kind == FieldFloat || f.Kind == FieldInt }
+
+// imperialUnits maps metric units to the units the API returns them in when
+// data is requested in the imperial unit system.
+var imperialUnits = map[string]string{
+	"C":     "F",
+	"m/s":   "mph",
+	"hPa":   "inHg",
+	"mm/hr": "in/hr",
+	"km":    "mi",
+}
+
+// UnitsIn returns the unit of measure for this field's values when data is
+// requested in the given unit system, "metric" or "imperial". An empty unit
+// system is metric.
+func (f Field) UnitsIn(system string) string {
+	if system == "imperial" {
+		if units, ok := imperialUnits[f.Units]; ok {
+			return units
+		}
+	}
+	return f.Units
+}
+
+var pollenLabels = map[int]string{
+	0: "None",
+	1: "Very Low",
+	2: "Low",
+	3: "Medium",
+	4: "High",
+	5: "Very High",
+}
+
+var healthConcernLabels = map[int]string{
+	0: "Good",
+	1: "Moderate",
+	2: "Unhealthy for Sensitive Groups",
+	3: "Unhealthy",
+	4: "Very Unhealthy",
+	5: "Hazardous",
+}
+
+var primaryPollutantLabels = map[int]string{
+	0: "PM2.5",
+	1: "PM10",
+	2: "O3",
+	3: "NO2",
+	4: "CO",
+	5: "SO2",
+}
+
+// WeatherCodeLabels contains the description for each weatherCode value.
+var WeatherCodeLabels = map[int]string{
+	0:    "Unknown",
+	1000: "Clear",
+	1001: "Cloudy",
+	1100: "Mostly Clear",
+	1101: "Partly Cloudy",
+	1102: "Mostly Cloudy",
+	2000: "Fog",
+	2100: "Light Fog",
+	3000: "Light Wind",
+	3001: "Wind",
+	3002: "Strong Wind",
+	4000: "Drizzle",
+	4001: "Rain",
+	4200: "Light Rain",
+	4201: "Heavy Rain",
+	5000: "Snow",
+	5001: "Flurries",
+	5100: "Light Snow",
+	5101: "Heavy Snow",
+	6000: "Freezing Drizzle",
+	6001: "Freezing Rain",
+	6200: "Light Freezing Rain",
+	6201: "Heavy Freezing Rain",
+	7000: "Ice Pellets",
+	7101: "Heavy Ice Pellets",
+	7102: "Light Ice Pellets",
+	8000: "Thunderstorm",
+}
+
+var registry = map[string]Field{}
+
+func register(fields ...Field) {
+	for _, f := range fields {
+		registry[f.Name] = f
+	}
+}
+
+func init() {
+	register(
+		Field{Name: "temperature", Kind: FieldFloat, Units: "C", Group: GroupWeather},
+		Field{Name: "temperatureApparent", Kind: FieldFloat, Units: "C", Group: GroupWeather},
+		Field{Name: "dewPoint", Kind: FieldFloat, Units: "C", Group: GroupWeather},
+		Field{Name: "humidity", Kind: FieldFloat, Units: "%", Group: GroupWeather},
+		Field{Name: "windSpeed", Kind: FieldFloat, Units: "m/s", Group: GroupWeather},
+		Field{Name: "windDirection", Kind: FieldFloat, Units: "degrees", Group: GroupWeather},
+		Field{Name: "windGust", Kind: FieldFloat, Units: "m/s", Group: GroupWeather},
+		Field{Name: "pressureSurfaceLevel", Kind: FieldFloat, Units: "hPa", Group: GroupWeather},
+		Field{Name: "pressureSeaLevel", Kind: FieldFloat, Units: "hPa", Group: GroupWeather},
+		Field{Name: "precipitationIntensity", Kind: FieldFloat, Units: "mm/hr", Group: GroupWeather},
+		Field{Name: "precipitationProbability", Kind: FieldFloat, Units: "%", Group: GroupWeather},
+		Field{Name: "precipitationType", Kind: FieldEnum, Group: GroupWeather, Labels: map[int]string{
+			0: "N/A",
+			1: "Rain",
+			2: "Snow",
+			3: "Freezing Rain",
+			4: "Ice Pellets",
+		}},
+		Field{Name: "visibility", Kind: FieldFloat, Units: "km", Group: GroupWeather},
+		Field{Name: "cloudCover", Kind: FieldFloat, Units: "%", Group: GroupWeather},
+		Field{Name: "cloudBase", Kind: FieldFloat, Units: "km", Group: GroupWeather},
+		Field{Name: "cloudCeiling", Kind: FieldFloat, Units: "km", Group: GroupWeather},
+		Field{Name: "hailBinary", Kind: FieldInt, Group: GroupWeather},
+		Field{Name: "weatherCode", Kind: FieldEnum, Group: GroupWeather, Labels: WeatherCodeLabels},
+
+		Field{Name: "particulateMatter25", Kind: FieldFloat, Units: "μg/m^3", Group: GroupAir},
+		Field{Name: "particulateMatter10", Kind: FieldFloat, Units: "μg/m^3", Group: GroupAir},
+		Field{Name: "pollutantO3", Kind: FieldFloat, Units: "ppb", Group: GroupAir},
+		Field{Name: "pollutantNO2", Kind: FieldFloat, Units: "ppb", Group: GroupAir},
+		Field{Name: "pollutantCO", Kind: FieldFloat, Units: "ppb", Group: GroupAir},
+		Field{Name: "pollutantSO2", Kind: FieldFloat, Units: "ppb", Group: GroupAir},
+		Field{Name: "epaIndex", Kind: FieldInt, Group: GroupAir},
+		Field{Name: "epaPrimaryPollutant", Kind: FieldEnum, Group: GroupAir, Labels: primaryPollutantLabels},
+		Field{Name: "epaHealthConcern", Kind: FieldEnum, Group: GroupAir, Labels: healthConcernLabels},
+		Field{Name: "mepIndex", Kind: FieldInt, Group: GroupAir},
+		Field{Name: "mepPrimaryPollutant", Kind: FieldEnum, Group: GroupAir, Labels: primaryPollutantLabels},
+		Field{Name: "mepHealthConcern", Kind: FieldEnum, Group: GroupAir, Labels: healthConcernLabels},
+
+		Field{Name: "fireIndex", Kind: FieldFloat, Units: "FWI", Group: GroupFire},
+
+		Field{Name: "solarGHI", Kind: FieldFloat, Units: "W/m^2", Group: GroupSolar},
+		Field{Name: "solarDIF", Kind: FieldFloat, Units: "W/m^2", Group: GroupSolar},
+		Field{Name: "solarDIR", Kind: FieldFloat, Units: "W/m^2", Group: GroupSolar},
+
+		Field{Name: "sunriseTime", Kind: FieldTime, Group: GroupCelestial},
+		Field{Name: "sunsetTime", Kind: FieldTime, Group: GroupCelestial},
+		Field{Name: "moonPhase", Kind: FieldEnum, Group: GroupCelestial, Labels: map[int]string{
+			0: "New",
+			1: "Waxing Crescent",
+			2: "First Quarter",
+			3: "Waxing Gibbous",
+			4: "Full",
+			5: "Waning Gibbous",
+			6: "Third Quarter",
+			7: "Waning Crescent",
+		}},
+	)
+
+	for _, name := range []string{
+		"grassIndex", "grassGrassIndex", "treeIndex", "weedIndex",
+		"weedGrassweedIndex", "treeAcacia", "treeAsh", "treeBeech",
+		"treeBirch", "treeCedar", "treeCottonwood", "treeCypress",
+		"treeElder", "treeElm", "treeHemlock", "treeHickory",
+		"treeJuniper", "treeMahagony", "treeMaple", "treeMulberry",
+		"treeOak", "treePine", "treeSpruce", "treeSycamore",
+		"treeWalnut", "treeWillow",
+	} {
+		register(Field{Name: name, Kind: FieldEnum, Group: GroupPollen, Labels: pollenLabels})
+	}
+}
+
+// LookupField returns the registry entry for a field name, and a true "ok" if
+// the field is known.
+//
+// Names of daily aggregates, such as "temperatureMax", "temperatureAvg" or
+// "temperatureMaxTime", resolve to their base field, keeping its units for
+// the Max, Min and Avg suffixes and becoming a FieldTime for the MaxTime and
+// MinTime suffixes.
+func LookupField(name string) (f Field, ok bool) {
+	if f, ok = registry[name]; ok {
+		return f, true
+	}
+
+	for _, suffix := range []string{"MaxTime", "MinTime"} {
+		if base, ok := registry[strings.TrimSuffix(name, suffix)]; ok && strings.HasSuffix(name, suffix) {
+			return Field{Name: name, Kind: FieldTime, Group: base.Group}, true
+		}
+	}
+	for _, suffix := range []string{"Max", "Min", "Avg"} {
+		if base, ok := registry[strings.TrimSuffix(name, suffix)]; ok && strings.HasSuffix(name, suffix) {
+			base.Name = name
+			return base, true
+		}
+	}
+	return Field{}, false
+}
+
+// FieldNames returns the names of every field in the registry, sorted
+// alphabetically.
+func FieldNames() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
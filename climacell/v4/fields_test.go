@@ -0,0 +1,176 @@
+package climacell
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupField(t *testing.T) {
+	f, ok := LookupField("windGust")
+	require.True(t, ok)
+	assert.Equal(t, FieldFloat, f.Kind)
+	assert.Equal(t, "m/s", f.Units)
+
+	f, ok = LookupField("temperatureMax")
+	require.True(t, ok)
+	assert.Equal(t, "temperatureMax", f.Name)
+	assert.Equal(t, "C", f.Units)
+
+	f, ok = LookupField("temperatureMaxTime")
+	require.True(t, ok)
+	assert.Equal(t, FieldTime, f.Kind)
+
+	f, ok = LookupField("weatherCode")
+	require.True(t, ok)
+	label, ok := f.Label(4201)
+	assert.True(t, ok)
+	assert.Equal(t, "Heavy Rain", label)
+
+	_, ok = LookupField("notAField")
+	assert.False(t, ok)
+}
+
+func TestSampleInterval(t *testing.T) {
+	obs := time.Date(2020, 4, 12, 12, 0, 0, 0, time.UTC)
+	temp := 10.5
+	code := "4001"
+	iv, err := SampleInterval(HourlyForecast{
+		BaseResponseType: BaseResponseType{
+			LatLon:          LatLon{Lat: 42.4, Lon: -71.1},
+			ObservationTime: DateValue{Value: obs},
+		},
+		WeatherType: WeatherType{
+			Temp:        &FloatValue{Value: &temp, Units: "C"},
+			WeatherCode: &StringValue{Value: &code},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, obs, iv.StartTime)
+	if v, ok := iv.Values.Float("temperature"); assert.True(t, ok) {
+		assert.Equal(t, 10.5, v)
+	}
+	if v, ok := iv.Values.String("weatherCode"); assert.True(t, ok) {
+		assert.Equal(t, "4001", v)
+	}
+	_, ok := iv.Values.Float("humidity")
+	assert.False(t, ok)
+	_, ok = iv.Values["lat"]
+	assert.False(t, ok)
+}
+
+func TestSampleIntervalV3Names(t *testing.T) {
+	day := time.Date(2020, 12, 21, 0, 0, 0, 0, time.UTC)
+	high, pm25 := 8.0, 12.5
+	code, concern, pollutant := "tstorm", "Unhealthy for Sensitive Groups", "pm25"
+	iv, err := SampleInterval(ForecastDay{
+		ObservationTime: DateValue{Value: day},
+		Temp:            &ForecastMinAndMax{{ObservationTime: day, Max: &FloatValue{Value: &high}}},
+		WeatherCode:     &StringValue{Value: &code},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Values{"temperatureMax": 8.0, "weatherCode": 8000}, iv.Values)
+	_, ok := LookupField("temperatureMax")
+	assert.True(t, ok)
+
+	iv, err = SampleInterval(RealTime{AirQualityType: AirQualityType{
+		PMTwoPointFive:      &FloatValue{Value: &pm25},
+		EPAHealthConcern:    &StringValue{Value: &concern},
+		EPAPrimaryPollutant: &StringValue{Value: &pollutant},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, Values{"particulateMatter25": 12.5, "epaHealthConcern": 2, "epaPrimaryPollutant": 0}, iv.Values)
+
+	// IntervalSample converts the interval back
+	var rt RealTime
+	require.NoError(t, IntervalSample(iv, &rt))
+	assert.Equal(t, pm25, *rt.PMTwoPointFive.Value)
+	assert.Equal(t, concern, *rt.EPAHealthConcern.Value)
+	assert.Equal(t, pollutant, *rt.EPAPrimaryPollutant.Value)
+
+	var f ForecastDay
+	require.NoError(t, IntervalSample(Interval{StartTime: day, Values: Values{"temperatureMax": 8.0, "weatherCode": 8000}}, &f))
+	assert.Equal(t, day, f.ObservationTime.Value)
+	if v, ok := f.Temp.Max().GetValue(); assert.True(t, ok) {
+		assert.Equal(t, 8.0, v)
+	}
+	assert.Equal(t, code, *f.WeatherCode.Value)
+}
+
+func TestIntervalSampleDaily(t *testing.T) {
+	day := time.Date(2020, 12, 21, 0, 0, 0, 0, time.UTC)
+	iv := Interval{StartTime: day, Values: Values{
+		"temperature":            5.0,
+		"temperatureMax":         8.0,
+		"temperatureMin":         -1.0,
+		"precipitationIntensity": 0.5,
+		"weatherCode":            4001,
+	}}
+
+	// a ForecastDay takes the daily minimums and maximums
+	var f ForecastDay
+	require.NoError(t, IntervalSample(iv, &f))
+	if v, ok := f.Temp.Max().GetValue(); assert.True(t, ok) {
+		assert.Equal(t, 8.0, v)
+	}
+	if v, ok := f.Temp.Min().GetValue(); assert.True(t, ok) {
+		assert.Equal(t, -1.0, v)
+	}
+	assert.Nil(t, f.Precipitation)
+	assert.Equal(t, "rain", *f.WeatherCode.Value)
+
+	// and an HourlyForecast the values themselves
+	var h HourlyForecast
+	require.NoError(t, IntervalSample(iv, &h))
+	if v, ok := h.Temp.GetValue(); assert.True(t, ok) {
+		assert.Equal(t, 5.0, v)
+	}
+	if v, ok := h.Precipitation.GetValue(); assert.True(t, ok) {
+		assert.Equal(t, 0.5, v)
+	}
+	assert.Equal(t, "rain", *h.WeatherCode.Value)
+
+	assert.Error(t, IntervalSample(iv, h))
+}
+
+func TestFieldUnitsIn(t *testing.T) {
+	f, ok := LookupField("windGust")
+	require.True(t, ok)
+	assert.Equal(t, "m/s", f.UnitsIn("metric"))
+	assert.Equal(t, "m/s", f.UnitsIn(""))
+	assert.Equal(t, "mph", f.UnitsIn("imperial"))
+
+	f, ok = LookupField("humidity")
+	require.True(t, ok)
+	assert.Equal(t, "%", f.UnitsIn("imperial"))
+}
+
+func TestConvertUnits(t *testing.T) {
+	v, err := ConvertUnits(20, "C", "F")
+	require.NoError(t, err)
+	assert.InDelta(t, 68, v, 1e-9)
+
+	v, err = ConvertUnits(32, "F", "C")
+	require.NoError(t, err)
+	assert.InDelta(t, 0, v, 1e-9)
+
+	v, err = ConvertUnits(10, "m/s", "mph")
+	require.NoError(t, err)
+	assert.InDelta(t, 22.369, v, 1e-3)
+
+	v, err = ConvertUnits(1, "in/hr", "mm/hr")
+	require.NoError(t, err)
+	assert.InDelta(t, 25.4, v, 1e-9)
+
+	v, err = ConvertUnits(55, "%", "%")
+	require.NoError(t, err)
+	assert.Equal(t, 55.0, v)
+
+	_, err = ConvertUnits(1, "m/s", "hPa")
+	assert.Error(t, err)
+	_, err = ConvertUnits(1, "furlongs", "km")
+	assert.Error(t, err)
+}
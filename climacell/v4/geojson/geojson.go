@@ -0,0 +1,295 @@
+// Package geojson converts ClimaCell timelines to GeoJSON FeatureCollections
+// for GIS tools, and parses them back.
+//
+// Each location's timelines become features with the location's geometry.
+// With the PerInterval layout, there is a feature for each interval, whose
+// properties are the "location", "timestep", "time" and the interval's
+// values:
+//
+//	{"type": "Feature", "geometry": {"type": "Point", "coordinates": [-78.6, 35.8]},
+//	 "properties": {"location": "home", "timestep": "1h", "time": "2020-12-21T06:00:00Z", "temperature": 15.1}}
+//
+// With the TimeSeries layout, there is a feature for each timeline, whose
+// properties hold the intervals' "times" and an array of each field's values
+// in the same order, with null where an interval has no value:
+//
+//	{"type": "Feature", "geometry": {"type": "Point", "coordinates": [-78.6, 35.8]},
+//	 "properties": {"location": "home", "timestep": "1h", "times": ["2020-12-21T06:00:00Z"], "temperature": [15.1]}}
+//
+// The collection describes the fields in a "fields" member, with their units
+// and the labels of enum fields' codes.
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// Names of the properties every feature has.
+const (
+	LocationProperty = "location"
+	TimestepProperty = "timestep"
+	TimeProperty     = "time"
+	TimesProperty    = "times"
+)
+
+// Layout is how timelines are laid out as features.
+type Layout int
+
+const (
+	// PerInterval has a feature for each interval of a timeline.
+	PerInterval Layout = iota
+	// TimeSeries has a feature for each timeline, with arrays of values.
+	TimeSeries
+)
+
+// FeatureCollection is a GeoJSON FeatureCollection.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+	// Fields describes the fields of the features' values. It is a
+	// foreign member, which GeoJSON readers ignore if they don't know it.
+	Fields map[string]FieldMetadata `json:"fields,omitempty"`
+}
+
+// Feature is a GeoJSON Feature.
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   climacell.Geometry     `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// FieldMetadata describes a field of the values.
+type FieldMetadata struct {
+	// Units is the unit of measure of the field's values.
+	Units string `json:"units,omitempty"`
+	// Labels contains the description for each code of an enum field.
+	Labels map[int]string `json:"labels,omitempty"`
+}
+
+// Location is the timelines of a location.
+type Location struct {
+	// ID identifies the location in the features' "location" property.
+	// It defaults to the geometry's Key.
+	ID string
+	// Geometry is the location's point or polygon.
+	Geometry climacell.Geometry
+	// Timelines are the location's timelines.
+	Timelines []climacell.Timeline
+}
+
+func (l Location) id() string {
+	if l.ID != "" {
+		return l.ID
+	}
+	return l.Geometry.Key()
+}
+
+// Options configure the conversion of timelines to features.
+type Options struct {
+	// Layout is how the timelines are laid out as features.
+	Layout Layout
+	// Units is the unit system the values were requested in, either
+	// "metric" or "imperial", for the fields' units. It defaults to
+	// metric.
+	Units string
+}
+
+// NewFeatureCollection converts the timelines of locations to a
+// FeatureCollection. opts may be nil for the defaults.
+func NewFeatureCollection(locations []Location, opts *Options) *FeatureCollection {
+	if opts == nil {
+		opts = &Options{}
+	}
+	fc := &FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	fields := map[string]bool{}
+	for _, l := range locations {
+		for _, t := range l.Timelines {
+			if opts.Layout == TimeSeries {
+				fc.Features = append(fc.Features, timeSeriesFeature(l, t, fields))
+				continue
+			}
+			for _, iv := range t.Intervals {
+				props := map[string]interface{}{
+					LocationProperty: l.id(),
+					TimestepProperty: t.Timestep,
+					TimeProperty:     iv.StartTime.Format(time.RFC3339),
+				}
+				for name, v := range iv.Values {
+					props[name] = v
+					fields[name] = true
+				}
+				fc.Features = append(fc.Features, Feature{Type: "Feature", Geometry: l.Geometry, Properties: props})
+			}
+		}
+	}
+
+	for name := range fields {
+		f, ok := climacell.LookupField(name)
+		if !ok {
+			continue
+		}
+		if fc.Fields == nil {
+			fc.Fields = map[string]FieldMetadata{}
+		}
+		fc.Fields[name] = FieldMetadata{Units: f.UnitsIn(opts.Units), Labels: f.Labels}
+	}
+	return fc
+}
+
+func timeSeriesFeature(l Location, t climacell.Timeline, fields map[string]bool) Feature {
+	times := make([]string, len(t.Intervals))
+	series := map[string][]interface{}{}
+	for i, iv := range t.Intervals {
+		times[i] = iv.StartTime.Format(time.RFC3339)
+		for name, v := range iv.Values {
+			if series[name] == nil {
+				series[name] = make([]interface{}, len(t.Intervals))
+				fields[name] = true
+			}
+			series[name][i] = v
+		}
+	}
+
+	props := map[string]interface{}{
+		LocationProperty: l.id(),
+		TimestepProperty: t.Timestep,
+		TimesProperty:    times,
+	}
+	for name, values := range series {
+		props[name] = values
+	}
+	return Feature{Type: "Feature", Geometry: l.Geometry, Properties: props}
+}
+
+// Write writes the FeatureCollection of the timelines of locations to w.
+func Write(w io.Writer, locations []Location, opts *Options) error {
+	return json.NewEncoder(w).Encode(NewFeatureCollection(locations, opts))
+}
+
+// Parse parses a FeatureCollection written with either layout back into the
+// timelines of its locations, in the order the locations first appear. The
+// intervals of each timeline are sorted by time.
+func Parse(r io.Reader) ([]Location, error) {
+	var fc FeatureCollection
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON: %v", err)
+	}
+	if fc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("invalid GeoJSON: type %q is not a FeatureCollection", fc.Type)
+	}
+
+	var (
+		locations []Location
+		index     = map[string]int{}
+		timelines = map[string]map[string]*climacell.Timeline{}
+	)
+	for i, f := range fc.Features {
+		id, _ := f.Properties[LocationProperty].(string)
+		if id == "" {
+			id = f.Geometry.Key()
+		}
+		if _, ok := index[id]; !ok {
+			index[id] = len(locations)
+			locations = append(locations, Location{ID: id, Geometry: f.Geometry})
+			timelines[id] = map[string]*climacell.Timeline{}
+		}
+		timestep, _ := f.Properties[TimestepProperty].(string)
+		t := timelines[id][timestep]
+		if t == nil {
+			t = &climacell.Timeline{Timestep: timestep}
+			timelines[id][timestep] = t
+		}
+
+		intervals, err := parseIntervals(f.Properties)
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %v", i, err)
+		}
+		t.Intervals = append(t.Intervals, intervals...)
+	}
+
+	for i, l := range locations {
+		timesteps := make([]string, 0, len(timelines[l.id()]))
+		for timestep := range timelines[l.id()] {
+			timesteps = append(timesteps, timestep)
+		}
+		sort.Strings(timesteps)
+		for _, timestep := range timesteps {
+			t := timelines[l.id()][timestep]
+			sort.SliceStable(t.Intervals, func(i, j int) bool { return t.Intervals[i].StartTime.Before(t.Intervals[j].StartTime) })
+			if n := len(t.Intervals); n > 0 {
+				t.StartTime = t.Intervals[0].StartTime
+				t.EndTime = t.Intervals[n-1].StartTime
+			}
+			locations[i].Timelines = append(locations[i].Timelines, *t)
+		}
+	}
+	return locations, nil
+}
+
+// parseIntervals returns the intervals of a feature's properties, which hold
+// either one interval's "time" or the "times" of a series.
+func parseIntervals(props map[string]interface{}) ([]climacell.Interval, error) {
+	isProperty := func(name string) bool {
+		switch name {
+		case LocationProperty, TimestepProperty, TimeProperty, TimesProperty:
+			return true
+		}
+		return false
+	}
+
+	if times, ok := props[TimesProperty].([]interface{}); ok {
+		intervals := make([]climacell.Interval, len(times))
+		for i, t := range times {
+			start, err := parseTime(t)
+			if err != nil {
+				return nil, err
+			}
+			intervals[i] = climacell.Interval{StartTime: start, Values: climacell.Values{}}
+		}
+		for name, v := range props {
+			if isProperty(name) {
+				continue
+			}
+			series, ok := v.([]interface{})
+			if !ok || len(series) != len(times) {
+				return nil, fmt.Errorf("property %q is not an array of %d values", name, len(times))
+			}
+			for i, v := range series {
+				if v != nil {
+					intervals[i].Values[name] = v
+				}
+			}
+		}
+		return intervals, nil
+	}
+
+	start, err := parseTime(props[TimeProperty])
+	if err != nil {
+		return nil, err
+	}
+	values := climacell.Values{}
+	for name, v := range props {
+		if !isProperty(name) {
+			values[name] = v
+		}
+	}
+	return []climacell.Interval{{StartTime: start, Values: values}}, nil
+}
+
+func parseTime(v interface{}) (time.Time, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("missing or invalid time %v", v)
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,112 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+var start = time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC)
+
+func testLocations() []Location {
+	return []Location{
+		{
+			ID:       "home",
+			Geometry: climacell.Geometry{Type: "Point", Coordinates: []float64{-78.6, 35.8}},
+			Timelines: []climacell.Timeline{{
+				Timestep:  "1h",
+				StartTime: start,
+				EndTime:   start.Add(time.Hour),
+				Intervals: []climacell.Interval{
+					{StartTime: start, Values: climacell.Values{"temperature": 15.1, "weatherCode": 1000.0}},
+					{StartTime: start.Add(time.Hour), Values: climacell.Values{"temperature": 14.2}},
+				},
+			}},
+		},
+		{
+			Geometry: climacell.Geometry{Type: "Point", Coordinates: []float64{-71.1, 42.4}},
+			Timelines: []climacell.Timeline{{
+				Timestep:  "1h",
+				StartTime: start,
+				EndTime:   start,
+				Intervals: []climacell.Interval{
+					{StartTime: start, Values: climacell.Values{"temperature": 2.5, "note": "snow"}},
+				},
+			}},
+		},
+	}
+}
+
+func TestPerInterval(t *testing.T) {
+	fc := NewFeatureCollection(testLocations(), &Options{Units: "imperial"})
+	assert.Equal(t, "FeatureCollection", fc.Type)
+	require.Len(t, fc.Features, 3)
+
+	f := fc.Features[1]
+	assert.Equal(t, "Point", f.Geometry.Type)
+	assert.Equal(t, map[string]interface{}{
+		"location":    "home",
+		"timestep":    "1h",
+		"time":        "2020-12-21T07:00:00Z",
+		"temperature": 14.2,
+	}, f.Properties)
+	assert.Equal(t, "42.4,-71.1", fc.Features[2].Properties["location"])
+
+	assert.Equal(t, "F", fc.Fields["temperature"].Units)
+	assert.Equal(t, "Clear", fc.Fields["weatherCode"].Labels[1000])
+	assert.NotContains(t, fc.Fields, "note")
+}
+
+func TestTimeSeries(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, testLocations(), &Options{Layout: TimeSeries}))
+
+	var fc map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &fc))
+	features := fc["features"].([]interface{})
+	require.Len(t, features, 2)
+	props := features[0].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"2020-12-21T06:00:00Z", "2020-12-21T07:00:00Z"}, props["times"])
+	assert.Equal(t, []interface{}{15.1, 14.2}, props["temperature"])
+	assert.Equal(t, []interface{}{1000.0, nil}, props["weatherCode"])
+	assert.Equal(t, "C", fc["fields"].(map[string]interface{})["temperature"].(map[string]interface{})["units"])
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	want := testLocations()
+	want[1].ID = "42.4,-71.1"
+
+	for _, layout := range []Layout{PerInterval, TimeSeries} {
+		var buf bytes.Buffer
+		require.NoError(t, Write(&buf, testLocations(), &Options{Layout: layout}))
+		got, err := Parse(&buf)
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		for i := range want {
+			assert.Equal(t, want[i].ID, got[i].ID)
+			assert.Equal(t, want[i].Geometry.Type, got[i].Geometry.Type)
+			assert.Equal(t, want[i].Timelines, got[i].Timelines, "layout %d", layout)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for body, msg := range map[string]string{
+		`{"type": "Feature"}`: "not a FeatureCollection",
+		`[`:                   "invalid GeoJSON",
+		`{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "Point", "coordinates": [0, 0]}, "properties": {"time": "yesterday"}}]}`:                                 `feature 0: invalid time "yesterday"`,
+		`{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "Point", "coordinates": [0, 0]}, "properties": {"times": ["2020-12-21T06:00:00Z"], "temperature": 1}}]}`: `property "temperature" is not an array`,
+	} {
+		_, err := Parse(strings.NewReader(body))
+		if assert.Error(t, err, body) {
+			assert.Contains(t, err.Error(), msg)
+		}
+	}
+}
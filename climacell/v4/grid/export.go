@@ -0,0 +1,93 @@
+package grid
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+	"github.com/maskarb/climacell-go/climacell/v4/geojson"
+)
+
+// FeatureCollection returns the grid as a GeoJSON FeatureCollection with a
+// feature for each sampled point and time, laid out like
+// geojson.PerInterval, with the "lat,lon" of the point as its location.
+func (g *Grid) FeatureCollection() *geojson.FeatureCollection {
+	var locations []geojson.Location
+	for i, lat := range g.Lats {
+		for j, lon := range g.Lons {
+			if !g.Inside(i, j) {
+				continue
+			}
+			t := climacell.Timeline{Timestep: g.Timestep, Intervals: make([]climacell.Interval, len(g.Times))}
+			for k, start := range g.Times {
+				values := climacell.Values{}
+				for f, name := range g.Fields {
+					if v, ok := g.Value(i, j, k, f); ok {
+						values[name] = v
+					}
+				}
+				t.Intervals[k] = climacell.Interval{StartTime: start, Values: values}
+			}
+			locations = append(locations, geojson.Location{
+				Geometry:  climacell.Geometry{Type: "Point", Coordinates: []float64{lon, lat}},
+				Timelines: []climacell.Timeline{t},
+			})
+		}
+	}
+	return geojson.NewFeatureCollection(locations, nil)
+}
+
+// WriteGeoJSON writes the grid's FeatureCollection to w.
+func (g *Grid) WriteGeoJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(g.FeatureCollection())
+}
+
+// WriteCSV writes the grid to w as CSV with a header row, then a row for
+// each sampled point and time with its "lat", "lon", "time" and a column per
+// field, which is empty where the value is missing.
+func (g *Grid) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"lat", "lon", "time"}, g.Fields...)); err != nil {
+		return err
+	}
+	row := make([]string, 3+len(g.Fields))
+	var err error
+	g.each(func(lat, lon, t int) {
+		if err != nil {
+			return
+		}
+		row[0] = strconv.FormatFloat(g.Lats[lat], 'f', -1, 64)
+		row[1] = strconv.FormatFloat(g.Lons[lon], 'f', -1, 64)
+		row[2] = g.Times[t].Format(time.RFC3339)
+		for f := range g.Fields {
+			row[3+f] = ""
+			if v, ok := g.Value(lat, lon, t, f); ok {
+				row[3+f] = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		err = cw.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// each calls fn with the indices of every sampled point at every time,
+// ordered by latitude, longitude and time.
+func (g *Grid) each(fn func(lat, lon, t int)) {
+	for i := range g.Lats {
+		for j := range g.Lons {
+			if !g.Inside(i, j) {
+				continue
+			}
+			for t := range g.Times {
+				fn(i, j, t)
+			}
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// Package grid samples ClimaCell timelines at a regular grid of points over
+// an area, for drawing regional heatmaps.
+//
+// Sample generates the points of the grid inside a bounding box or polygon,
+// requests the timeline of each with ClientV4.BatchGetTimelines, and returns
+// a Grid of the values indexed by latitude, longitude, time and field:
+//
+//	area := grid.BBox{MinLon: -79, MinLat: 35, MaxLon: -78, MaxLat: 36}.Polygon()
+//	g, err := grid.Sample(ctx, client, area, 0.1, &climacell.TimelineListOptions{
+//		Fields:    []string{"temperature"},
+//		TimeSteps: []string{"1h"},
+//	}, 0)
+package grid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// MaxPoints is the most points Sample requests, to keep a too fine
+// resolution from using up a day's quota.
+const MaxPoints = 10000
+
+// BBox is a bounding box, in degrees.
+type BBox struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+}
+
+// Polygon returns the bounding box as a Polygon geometry.
+func (b BBox) Polygon() climacell.Geometry {
+	return climacell.Geometry{
+		Type: "Polygon",
+		Coordinates: [][][]float64{{
+			{b.MinLon, b.MinLat},
+			{b.MaxLon, b.MinLat},
+			{b.MaxLon, b.MaxLat},
+			{b.MinLon, b.MaxLat},
+			{b.MinLon, b.MinLat},
+		}},
+	}
+}
+
+// polygon is the rings of a Polygon geometry: its exterior ring followed by
+// its holes.
+type polygon [][][]float64
+
+// polygonOf returns the rings of a Polygon geometry, whose coordinates may
+// have been built in Go or decoded from JSON.
+func polygonOf(g climacell.Geometry) (polygon, error) {
+	if g.Type != "Polygon" {
+		return nil, fmt.Errorf("unsupported geometry type %q, expected a Polygon", g.Type)
+	}
+	var p polygon
+	if rings, ok := g.Coordinates.([][][]float64); ok {
+		p = rings
+	} else {
+		b, err := json.Marshal(g.Coordinates)
+		if err != nil {
+			return nil, errors.WithMessage(err, "reading polygon coordinates")
+		}
+		if err := json.Unmarshal(b, &p); err != nil {
+			return nil, errors.WithMessage(err, "reading polygon coordinates")
+		}
+	}
+	if len(p) == 0 || len(p[0]) < 3 {
+		return nil, fmt.Errorf("polygon has no exterior ring")
+	}
+	for _, ring := range p {
+		for _, c := range ring {
+			if len(c) < 2 {
+				return nil, fmt.Errorf("invalid polygon coordinate %v", c)
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p polygon) bbox() BBox {
+	b := BBox{MinLon: math.Inf(1), MinLat: math.Inf(1), MaxLon: math.Inf(-1), MaxLat: math.Inf(-1)}
+	for _, c := range p[0] {
+		b.MinLon = math.Min(b.MinLon, c[0])
+		b.MaxLon = math.Max(b.MaxLon, c[0])
+		b.MinLat = math.Min(b.MinLat, c[1])
+		b.MaxLat = math.Max(b.MaxLat, c[1])
+	}
+	return b
+}
+
+// contains reports whether a point is inside or on the polygon's exterior
+// ring and outside its holes, so that sampling a bounding box includes the
+// points on its edges.
+func (p polygon) contains(lon, lat float64) bool {
+	if !inRing(p[0], lon, lat) && !onRing(p[0], lon, lat) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if inRing(hole, lon, lat) {
+			return false
+		}
+	}
+	return true
+}
+
+// inRing tests a point against a ring by casting a ray east from it and
+// counting the edges it crosses.
+func inRing(ring [][]float64, lon, lat float64) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > lat) != (b[1] > lat) && lon < (b[0]-a[0])*(lat-a[1])/(b[1]-a[1])+a[0] {
+			in = !in
+		}
+	}
+	return in
+}
+
+// onRing reports whether a point is on one of a ring's edges.
+func onRing(ring [][]float64, lon, lat float64) bool {
+	const epsilon = 1e-9
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		cross := (b[0]-a[0])*(lat-a[1]) - (b[1]-a[1])*(lon-a[0])
+		if math.Abs(cross) > epsilon {
+			continue
+		}
+		if lon >= math.Min(a[0], b[0])-epsilon && lon <= math.Max(a[0], b[0])+epsilon &&
+			lat >= math.Min(a[1], b[1])-epsilon && lat <= math.Max(a[1], b[1])+epsilon {
+			return true
+		}
+	}
+	return false
+}
+
+// axis returns the coordinates from min to max, inclusive, at steps of
+// resolution.
+func axis(min, max, resolution float64) []float64 {
+	// allow for the rounding errors of adding up the steps
+	n := int(math.Floor((max-min)/resolution+1e-9)) + 1
+	a := make([]float64, n)
+	for i := range a {
+		// round away the noise of multiplying, so that coordinates
+		// format as they would be written
+		a[i] = math.Round((min+float64(i)*resolution)*1e9) / 1e9
+	}
+	return a
+}
+
+// Grid holds timeline values at a grid of points. Values are indexed by
+// latitude, longitude, time and field, along the Lats, Lons, Times and
+// Fields axes; a value is missing if the point is outside the sampled
+// polygon, its request failed, or the field has no numeric value at the
+// time.
+type Grid struct {
+	// Lats and Lons are the latitudes and longitudes of the grid's points,
+	// in ascending order.
+	Lats, Lons []float64
+	// Times are the start times of the intervals, in ascending order.
+	Times []time.Time
+	// Fields are the fields of the values.
+	Fields []string
+	// Timestep is the timestep of the sampled timelines.
+	Timestep string
+
+	// values are the values, with NaN for missing values
+	values []float64
+	// inside tells which points were sampled
+	inside []bool
+}
+
+func newGrid(lats, lons []float64, fields []string) *Grid {
+	return &Grid{
+		Lats:   lats,
+		Lons:   lons,
+		Fields: fields,
+		inside: make([]bool, len(lats)*len(lons)),
+	}
+}
+
+func (g *Grid) index(lat, lon, t, f int) int {
+	return ((lat*len(g.Lons)+lon)*len(g.Times)+t)*len(g.Fields) + f
+}
+
+// Value returns the value at the point at indices lat and lon of the Lats
+// and Lons, at index t of the Times, for index f of the Fields, and whether
+// it is present.
+func (g *Grid) Value(lat, lon, t, f int) (float64, bool) {
+	v := g.values[g.index(lat, lon, t, f)]
+	return v, !math.IsNaN(v)
+}
+
+// Inside reports whether the point at indices lat and lon of the Lats and
+// Lons is inside the sampled area.
+func (g *Grid) Inside(lat, lon int) bool {
+	return g.inside[lat*len(g.Lons)+lon]
+}
+
+// Field returns the index of a field in the Fields, or -1 if the grid
+// doesn't hold it.
+func (g *Grid) Field(name string) int {
+	for i, f := range g.Fields {
+		if f == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// fill sets the grid's times to those of the timelines, and copies their
+// values into the grid.
+func (g *Grid) fill(points [][2]int, timelines []*climacell.Timeline) {
+	seen := map[int64]time.Time{}
+	for _, t := range timelines {
+		if t == nil {
+			continue
+		}
+		if g.Timestep == "" {
+			g.Timestep = t.Timestep
+		}
+		for _, iv := range t.Intervals {
+			seen[iv.StartTime.UnixNano()] = iv.StartTime
+		}
+	}
+	for _, t := range seen {
+		g.Times = append(g.Times, t)
+	}
+	sort.Slice(g.Times, func(i, j int) bool { return g.Times[i].Before(g.Times[j]) })
+	times := make(map[int64]int, len(g.Times))
+	for i, t := range g.Times {
+		times[t.UnixNano()] = i
+	}
+
+	g.values = make([]float64, len(g.Lats)*len(g.Lons)*len(g.Times)*len(g.Fields))
+	for i := range g.values {
+		g.values[i] = math.NaN()
+	}
+	for i, p := range points {
+		if timelines[i] == nil {
+			continue
+		}
+		for _, iv := range timelines[i].Intervals {
+			for f, name := range g.Fields {
+				if v, ok := iv.Values.Float(name); ok {
+					g.values[g.index(p[0], p[1], times[iv.StartTime.UnixNano()], f)] = v
+				}
+			}
+		}
+	}
+}
+
+// Sample samples the timelines at the points of a grid over area, a Polygon
+// geometry such as a BBox's, spaced resolution degrees apart starting from
+// the south-west corner of its bounding box. Only the points inside the
+// polygon are requested, with up to workers requests in flight at once like
+// ClientV4.BatchGetTimelines. The options' location is ignored; if they
+// request several timesteps, the grid holds the first returned.
+//
+// If some of the points' requests fail, Sample returns the grid without
+// their values along with the first of their errors. Otherwise, if the
+// client's DecodeMode is DecodeLenient, the values that could be decoded are
+// kept and the error is the DecodeWarnings of every point, if any.
+func Sample(ctx context.Context, c *climacell.ClientV4, area climacell.Geometry, resolution float64, options *climacell.TimelineListOptions, workers int) (*Grid, error) {
+	if resolution <= 0 {
+		return nil, fmt.Errorf("invalid resolution %v", resolution)
+	}
+	p, err := polygonOf(area)
+	if err != nil {
+		return nil, err
+	}
+	b := p.bbox()
+	g := newGrid(axis(b.MinLat, b.MaxLat, resolution), axis(b.MinLon, b.MaxLon, resolution), options.Fields)
+	if n := len(g.Lats) * len(g.Lons); n > MaxPoints {
+		return nil, fmt.Errorf("%d points at a resolution of %v exceed the maximum of %d", n, resolution, MaxPoints)
+	}
+
+	var (
+		points    [][2]int
+		locations []climacell.BatchLocation
+	)
+	for i, lat := range g.Lats {
+		for j, lon := range g.Lons {
+			if !p.contains(lon, lat) {
+				continue
+			}
+			g.inside[i*len(g.Lons)+j] = true
+			points = append(points, [2]int{i, j})
+			locations = append(locations, climacell.BatchLocation{
+				Location: climacell.Geometry{Type: "Point", Coordinates: []float64{lon, lat}},
+			})
+		}
+	}
+
+	timelines := make([]*climacell.Timeline, len(points))
+	var (
+		firstErr error
+		warnings climacell.DecodeWarnings
+	)
+	for _, r := range c.BatchGetTimelines(ctx, locations, options, workers) {
+		var w climacell.DecodeWarnings
+		if errors.As(r.Err, &w) {
+			warnings = append(warnings, w...)
+		} else if r.Err != nil {
+			if firstErr == nil {
+				firstErr = errors.WithMessagef(r.Err, "sampling %s", r.Key)
+			}
+			continue
+		}
+		if len(r.Timelines.Timelines) > 0 {
+			timelines[r.Index] = &r.Timelines.Timelines[0]
+		}
+	}
+	g.fill(points, timelines)
+	if firstErr == nil && warnings != nil {
+		return g, warnings
+	}
+	return g, firstErr
+}
@@ -0,0 +1,164 @@
+package grid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// gridServer responds with two hourly intervals whose temperature is
+// 10*lat+lon, failing the requests for the point at 5,5.
+func gridServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var opts struct {
+			Location struct {
+				Coordinates []float64 `json:"coordinates"`
+			} `json:"location"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&opts))
+		lon, lat := opts.Location.Coordinates[0], opts.Location.Coordinates[1]
+		if lat == 5 && lon == 5 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code": 400001, "type": "Invalid Body Parameters", "message": "no data"}`))
+			return
+		}
+		fmt.Fprintf(w, `{"data": {"timelines": [{"timestep": "1h", "intervals": [
+			{"startTime": "2020-12-21T06:00:00Z", "values": {"temperature": %[1]v}},
+			{"startTime": "2020-12-21T07:00:00Z", "values": {"temperature": %[1]v, "weatherCode": 1000}}
+		]}]}}`, 10*lat+lon)
+	}))
+}
+
+func newClient(url string) *climacell.ClientV4 {
+	c := climacell.NewClient("test_api_key")
+	c.BaseURL = url
+	return c
+}
+
+func TestSampleBBox(t *testing.T) {
+	server := gridServer(t)
+	defer server.Close()
+
+	options := &climacell.TimelineListOptions{Fields: []string{"temperature", "weatherCode"}, TimeSteps: []string{"1h"}}
+	g, err := Sample(context.Background(), newClient(server.URL), BBox{MinLon: 0, MinLat: 0, MaxLon: 0.3, MaxLat: 0.2}.Polygon(), 0.1, options, 2)
+	require.NoError(t, err)
+
+	assert.Equal(t, []float64{0, 0.1, 0.2}, g.Lats)
+	assert.Equal(t, []float64{0, 0.1, 0.2, 0.3}, g.Lons)
+	assert.Len(t, g.Times, 2)
+	assert.Equal(t, "1h", g.Timestep)
+
+	temp := g.Field("temperature")
+	v, ok := g.Value(2, 3, 1, temp)
+	assert.True(t, ok)
+	assert.InDelta(t, 2.3, v, 1e-9)
+	assert.True(t, g.Inside(2, 3))
+
+	// weatherCode is only in the second interval
+	_, ok = g.Value(0, 0, 0, g.Field("weatherCode"))
+	assert.False(t, ok)
+	v, ok = g.Value(0, 0, 1, g.Field("weatherCode"))
+	assert.True(t, ok)
+	assert.Equal(t, 1000.0, v)
+	assert.Equal(t, -1, g.Field("humidity"))
+}
+
+func TestSamplePolygon(t *testing.T) {
+	server := gridServer(t)
+	defer server.Close()
+
+	// a triangle with a hole decoded from JSON, over the points 0..2
+	var area climacell.Geometry
+	require.NoError(t, json.Unmarshal([]byte(`{"type": "Polygon", "coordinates": [
+		[[0, 0], [2, 0], [0, 2], [0, 0]],
+		[[0.9, 0.4], [1.1, 0.4], [1.1, 0.6], [0.9, 0.6], [0.9, 0.4]]
+	]}`), &area))
+
+	options := &climacell.TimelineListOptions{Fields: []string{"temperature"}}
+	g, err := Sample(context.Background(), newClient(server.URL), area, 0.5, options, 0)
+	require.NoError(t, err)
+	require.Len(t, g.Lats, 5)
+	require.Len(t, g.Lons, 5)
+
+	// on the hypotenuse, above it, and in the hole
+	assert.True(t, g.Inside(2, 2))
+	assert.False(t, g.Inside(4, 4))
+	assert.False(t, g.Inside(1, 2))
+	_, ok := g.Value(4, 4, 0, 0)
+	assert.False(t, ok)
+
+	var buf bytes.Buffer
+	require.NoError(t, g.WriteCSV(&buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, "lat,lon,time,temperature", lines[0])
+	assert.Equal(t, "0,0.5,2020-12-21T06:00:00Z,0.5", lines[3])
+	// the 14 points in the triangle but not the hole, at two times
+	assert.Len(t, lines, 1+14*2)
+
+	fc := g.FeatureCollection()
+	assert.Equal(t, "FeatureCollection", fc.Type)
+	require.Len(t, fc.Features, 14*2)
+	f := fc.Features[3]
+	assert.Equal(t, []float64{0.5, 0}, f.Geometry.Coordinates)
+	assert.Equal(t, "0,0.5", f.Properties["location"])
+	assert.Equal(t, "C", fc.Fields["temperature"].Units)
+	assert.Equal(t, "2020-12-21T07:00:00Z", f.Properties["time"])
+	assert.Equal(t, 0.5, f.Properties["temperature"])
+
+	buf.Reset()
+	require.NoError(t, g.WriteGeoJSON(&buf))
+	assert.Contains(t, buf.String(), `"type":"FeatureCollection"`)
+}
+
+func TestSampleErrors(t *testing.T) {
+	server := gridServer(t)
+	defer server.Close()
+	c := newClient(server.URL)
+	options := &climacell.TimelineListOptions{Fields: []string{"temperature"}}
+
+	// the point at 5,5 fails, but the others are sampled
+	g, err := Sample(context.Background(), c, BBox{MaxLon: 5, MaxLat: 5}.Polygon(), 5, options, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sampling 5,5")
+	_, ok := g.Value(1, 1, 0, 0)
+	assert.False(t, ok)
+	v, ok := g.Value(1, 0, 0, 0)
+	assert.True(t, ok)
+	assert.Equal(t, 50.0, v)
+
+	// in DecodeLenient mode, points whose responses have values that can't
+	// be decoded keep the others
+	drifted := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"timelines": [{"timestep": "1h", "source": "new", "intervals": [
+			{"startTime": "2020-12-21T06:00:00Z", "values": {"temperature": 1}}
+		]}]}}`))
+	}))
+	defer drifted.Close()
+	lenient := newClient(drifted.URL)
+	lenient.DecodeMode = climacell.DecodeLenient
+	g, err = Sample(context.Background(), lenient, BBox{MaxLon: 1, MaxLat: 1}.Polygon(), 1, options, 0)
+	var warnings climacell.DecodeWarnings
+	require.True(t, errors.As(err, &warnings))
+	assert.Len(t, warnings, 4)
+	v, ok = g.Value(1, 1, 0, 0)
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, v)
+
+	_, err = Sample(context.Background(), c, BBox{MaxLon: 10, MaxLat: 10}.Polygon(), 0.01, options, 0)
+	assert.Contains(t, err.Error(), "exceed the maximum")
+	_, err = Sample(context.Background(), c, climacell.Geometry{Type: "Point", Coordinates: []float64{0, 0}}, 1, options, 0)
+	assert.Contains(t, err.Error(), "expected a Polygon")
+	_, err = Sample(context.Background(), c, BBox{MaxLon: 1, MaxLat: 1}.Polygon(), 0, options, 0)
+	assert.Error(t, err)
+}
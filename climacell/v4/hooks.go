@@ -0,0 +1,265 @@
+package climacell
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultRetryBackoff is the time before the first retry of a failed request.
+const DefaultRetryBackoff = time.Second
+
+// DefaultMaxRetryWait is the longest a retry of a failed request waits by
+// default.
+const DefaultMaxRetryWait = time.Minute
+
+// RequestInfo describes an attempt of a request to the ClimaCell API, given
+// to the hooks of a Middleware.
+type RequestInfo struct {
+	// Endpoint is the API endpoint of the request, like "timelines" or
+	// "weather/nowcast".
+	Endpoint string
+	// Options are the options of the call: a *TimelineListOptions for
+	// GetTimelines, a ForecastArgs for the weather endpoints of ClientV3,
+	// and nil for calls without options.
+	Options interface{}
+	// Attempt is the number of this attempt of the request, starting at
+	// 1.
+	Attempt int
+	// Key is the Name of the KeyPool key this attempt is sent with, if the
+	// client has a KeyPool.
+	Key string
+	// HTTP is the HTTP request of this attempt. BeforeRequest hooks can
+	// change it, like adding headers, or replace it.
+	HTTP *http.Request
+}
+
+// Middleware hooks into the requests of a ClientV3 or ClientV4, for things
+// like logging, tracing and auditing. Any of its hooks can be nil.
+//
+// The hooks are called for each attempt of a request, so a request that is
+// retried calls BeforeRequest again. Identical concurrent calls share a
+// request, as described by GetTimelines, whose hooks are called with the
+// context of the call that sent it; the other calls only call OnShared.
+type Middleware struct {
+	// BeforeRequest is called before an attempt is sent, and returns the
+	// context of the attempt, which the attempt's HTTP request and later
+	// hooks are given. If it returns an error, the request fails with it
+	// without being sent.
+	BeforeRequest func(ctx context.Context, req *RequestInfo) (context.Context, error)
+	// AfterResponse is called when an attempt's response is received,
+	// whatever its status.
+	AfterResponse func(ctx context.Context, req *RequestInfo, meta ResponseMeta)
+	// OnError is called when a request fails: when its last attempt
+	// couldn't be sent or had no response, or its response has an error
+	// status, in which case err is an *ErrorResponse.
+	OnError func(ctx context.Context, req *RequestInfo, err error)
+	// OnRetry is called when an attempt failed with err and the request is
+	// retried after wait, including when it is sent again right away with
+	// another key of a KeyPool.
+	OnRetry func(ctx context.Context, req *RequestInfo, err error, wait time.Duration)
+	// OnShared is called instead of the other hooks for a call that shared
+	// the response of an identical call's request rather than sending its
+	// own. Its req has an Attempt of 0.
+	OnShared func(ctx context.Context, req *RequestInfo, meta ResponseMeta)
+}
+
+// sender sends the attempts of a request, calling the hooks of its
+// middleware and retrying failed attempts. It is shared by ClientV3 and
+// ClientV4.
+type sender struct {
+	client     *http.Client
+	limiter    *RateLimiter
+	keys       *KeyPool
+	middleware []Middleware
+	retries    int
+	backoff    time.Duration
+	maxWait    time.Duration
+	// apiError returns the error of a response with an error status.
+	apiError func(res *response) error
+	// setKey sets the API key of a request sent with a key of keys.
+	setKey func(req *http.Request, key string)
+}
+
+// send sends req until it succeeds, fails with an error that isn't
+// retryable, or runs out of retries. Attempts rejected by the API because of
+// their key are sent again with another key of the sender's KeyPool, without
+// counting as retries, and retries wait until a key is available. Responses
+// with an error status are returned rather than their error, so that each
+// caller sharing the response decodes its own error. Waiting for a retry
+// stops when ctx is done.
+func (s *sender) send(ctx context.Context, info RequestInfo, req *http.Request) (*response, error) {
+	backoff := s.backoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	maxWait := s.maxWait
+	if maxWait <= 0 {
+		maxWait = DefaultMaxRetryWait
+	}
+
+	failovers := 0
+	var last *response
+	for attempt := 1; ; attempt++ {
+		info.Attempt = attempt
+		actx, res, retryable := ctx, (*response)(nil), false
+		key, err := s.keys.acquire()
+		if err == nil {
+			actx, res, retryable, err = s.attempt(ctx, &info, req, key)
+		} else {
+			info.HTTP = req
+			// a request whose keys were all rejected fails with the
+			// last rejection rather than ErrNoKeys
+			res = last
+		}
+		if res != nil {
+			last = res
+		}
+		failover := s.keys.report(key, res)
+		if err == nil && res.status < http.StatusBadRequest {
+			return res, nil
+		}
+		if res != nil {
+			err = s.apiError(res)
+			retryable = res.status == http.StatusTooManyRequests || res.status >= http.StatusInternalServerError
+		}
+		if failover {
+			failovers++
+			for _, m := range s.middleware {
+				if m.OnRetry != nil {
+					m.OnRetry(actx, &info, err, 0)
+				}
+			}
+			continue
+		}
+		if !retryable || attempt-failovers > s.retries {
+			for _, m := range s.middleware {
+				if m.OnError != nil {
+					m.OnError(actx, &info, err)
+				}
+			}
+			if res != nil {
+				return res, nil
+			}
+			return nil, err
+		}
+
+		wait := retryAfter(res, retryBackoff(backoff, maxWait, attempt-failovers))
+		if kw := s.keys.wait(); kw > wait {
+			wait = kw
+		}
+		if wait > maxWait {
+			wait = maxWait
+		}
+		for _, m := range s.middleware {
+			if m.OnRetry != nil {
+				m.OnRetry(actx, &info, err, wait)
+			}
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// attempt sends an attempt of req with key, if it isn't nil, returning the
+// context returned by the BeforeRequest hooks, and whether the attempt can be
+// retried if it failed without a response.
+func (s *sender) attempt(ctx context.Context, info *RequestInfo, req *http.Request, key *poolKey) (actx context.Context, res *response, retryable bool, err error) {
+	info.HTTP = req.Clone(ctx)
+	if req.GetBody != nil {
+		if info.HTTP.Body, err = req.GetBody(); err != nil {
+			return ctx, nil, false, err
+		}
+	}
+	info.Key = ""
+	if key != nil {
+		info.Key = key.Name
+		s.setKey(info.HTTP, key.Key)
+	}
+	for _, m := range s.middleware {
+		if m.BeforeRequest == nil {
+			continue
+		}
+		if ctx, err = m.BeforeRequest(ctx, info); err != nil {
+			return ctx, nil, false, err
+		}
+	}
+	info.HTTP = info.HTTP.WithContext(ctx)
+
+	if err := s.limiter.Wait(ctx); err != nil {
+		return ctx, nil, false, err
+	}
+
+	start := time.Now()
+	httpRes, err := s.client.Do(info.HTTP)
+	if err != nil {
+		return ctx, nil, ctx.Err() == nil, err
+	}
+	defer httpRes.Body.Close()
+
+	body, err := io.ReadAll(httpRes.Body)
+	if err != nil {
+		return ctx, nil, ctx.Err() == nil, err
+	}
+	res = &response{status: httpRes.StatusCode, header: httpRes.Header, body: body, latency: time.Since(start)}
+
+	meta := res.meta()
+	for _, m := range s.middleware {
+		if m.AfterResponse != nil {
+			m.AfterResponse(ctx, info, meta.clone())
+		}
+	}
+	return ctx, res, false, nil
+}
+
+// onShared calls the OnShared hooks for a call that shared another call's
+// response.
+func onShared(ctx context.Context, middleware []Middleware, info RequestInfo, req *http.Request, meta ResponseMeta) {
+	info.HTTP = req
+	for _, m := range middleware {
+		if m.OnShared != nil {
+			m.OnShared(ctx, &info, meta.clone())
+		}
+	}
+}
+
+// retryBackoff returns the backoff before the nth retry, doubling backoff
+// for each retry after the first, up to max.
+func retryBackoff(backoff, max time.Duration, n int) time.Duration {
+	for i := 1; i < n && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
+
+// retryAfter returns the time to wait before retrying a response, from its
+// Retry-After header if it has one, or backoff otherwise.
+func retryAfter(res *response, backoff time.Duration) time.Duration {
+	if res == nil {
+		return backoff
+	}
+	v := res.header.Get("Retry-After")
+	if v == "" {
+		return backoff
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if wait := time.Until(t); wait > 0 {
+			return wait
+		}
+		return 0
+	}
+	return backoff
+}
@@ -0,0 +1,271 @@
+package climacell
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyServer fails the first failures requests with status, then responds
+// with an empty timeline list.
+func flakyServer(failures int32, status int) (*httptest.Server, *int32) {
+	var n int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) <= failures {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			w.Write([]byte(`{"code": 500001, "type": "Internal Error", "message": "try again"}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"timelines": []}}`))
+	})), &n
+}
+
+type hookKey struct{}
+
+// recorder is Middleware recording the hooks it is called with.
+func recorder(calls *[]string) Middleware {
+	return Middleware{
+		BeforeRequest: func(ctx context.Context, req *RequestInfo) (context.Context, error) {
+			*calls = append(*calls, "before "+req.Endpoint)
+			req.HTTP.Header.Set("X-Audit", "yes")
+			return context.WithValue(ctx, hookKey{}, req.Attempt), nil
+		},
+		AfterResponse: func(ctx context.Context, req *RequestInfo, meta ResponseMeta) {
+			*calls = append(*calls, "after "+http.StatusText(meta.StatusCode))
+			if ctx.Value(hookKey{}) != req.Attempt {
+				*calls = append(*calls, "wrong context")
+			}
+		},
+		OnError: func(ctx context.Context, req *RequestInfo, err error) {
+			*calls = append(*calls, "error "+err.Error())
+		},
+		OnRetry: func(ctx context.Context, req *RequestInfo, err error, wait time.Duration) {
+			*calls = append(*calls, "retry "+err.Error())
+		},
+	}
+}
+
+func TestMiddlewareRetries(t *testing.T) {
+	server, n := flakyServer(2, http.StatusServiceUnavailable)
+	defer server.Close()
+
+	var calls []string
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+	client.Middleware = []Middleware{recorder(&calls)}
+	client.Retries = 2
+
+	options := &TimelineListOptions{Fields: []string{"temperature"}}
+	_, err := client.GetTimelines(context.Background(), options)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(n))
+	assert.Equal(t, []string{
+		"before timelines", "after Service Unavailable", "retry 503 (500001) API error: try again",
+		"before timelines", "after Service Unavailable", "retry 503 (500001) API error: try again",
+		"before timelines", "after OK",
+	}, calls)
+}
+
+func TestMiddlewareOnError(t *testing.T) {
+	server, n := flakyServer(5, http.StatusTooManyRequests)
+	defer server.Close()
+
+	var calls []string
+	var options interface{}
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+	client.Retries = 1
+	client.Middleware = []Middleware{recorder(&calls), {
+		BeforeRequest: func(ctx context.Context, req *RequestInfo) (context.Context, error) {
+			options = req.Options
+			return ctx, nil
+		},
+	}}
+
+	opts := &TimelineListOptions{Fields: []string{"temperature"}}
+	_, err := client.GetTimelines(context.Background(), opts)
+	var errRes *ErrorResponse
+	require.True(t, errors.As(err, &errRes))
+	assert.Equal(t, http.StatusTooManyRequests, errRes.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(n))
+	assert.Equal(t, "error 429 (500001) API error: try again", calls[len(calls)-1])
+	assert.Same(t, opts, options)
+
+	// a BeforeRequest error fails the request without sending it
+	client.Middleware = []Middleware{{
+		BeforeRequest: func(ctx context.Context, req *RequestInfo) (context.Context, error) {
+			return ctx, errors.New("not allowed")
+		},
+	}}
+	_, err = client.ListAlerts(context.Background())
+	assert.EqualError(t, err, "not allowed")
+	assert.Equal(t, int32(2), atomic.LoadInt32(n))
+}
+
+func TestMiddlewareV3(t *testing.T) {
+	var audited int32
+	var n int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Audit") == "yes" {
+			atomic.AddInt32(&audited, 1)
+		}
+		if atomic.AddInt32(&n, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	var calls []string
+	client := New("test_api_key")
+	client.baseURL = server.URL
+	client.Middleware = []Middleware{recorder(&calls)}
+	client.Retries = 1
+	client.RetryBackoff = time.Millisecond
+
+	_, err := client.Nowcast(ForecastArgs{Location: LatLon{Lat: 1, Lon: 2}})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&audited))
+	assert.Equal(t, []string{
+		"before weather/nowcast", "after Bad Gateway", "retry unexpected HTTP response status code: 502",
+		"before weather/nowcast", "after OK",
+	}, calls)
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert.Equal(t, time.Second, retryAfter(nil, time.Second))
+	res := &response{header: http.Header{}}
+	assert.Equal(t, time.Second, retryAfter(res, time.Second))
+	res.header.Set("Retry-After", "3")
+	assert.Equal(t, 3*time.Second, retryAfter(res, time.Second))
+	res.header.Set("Retry-After", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	assert.Zero(t, retryAfter(res, time.Second))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, retryBackoff(time.Second, time.Minute, 1))
+	assert.Equal(t, 4*time.Second, retryBackoff(time.Second, time.Minute, 3))
+	// doubling stops at the maximum rather than overflowing
+	assert.Equal(t, time.Minute, retryBackoff(time.Second, time.Minute, 100))
+	assert.Equal(t, time.Minute, retryBackoff(time.Hour, time.Minute, 1))
+}
+
+func TestMaxRetryWait(t *testing.T) {
+	var n int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"code": 429001, "type": "Too Many Calls", "message": "slow down"}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"timelines": []}}`))
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+	client.Retries = 1
+	client.MaxRetryWait = time.Millisecond
+	client.Middleware = []Middleware{{
+		OnRetry: func(ctx context.Context, req *RequestInfo, err error, wait time.Duration) {
+			waits = append(waits, wait)
+		},
+	}}
+
+	_, err := client.GetTimelines(context.Background(), &TimelineListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{time.Millisecond}, waits)
+
+	// a caller giving up stops waiting for the retry
+	atomic.StoreInt32(&n, 0)
+	client.MaxRetryWait = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = client.GetTimelines(ctx, &TimelineListOptions{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&n))
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	server, _ := flakyServer(1, http.StatusInternalServerError)
+	defer server.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := NewClient("secret_key")
+	client.BaseURL = server.URL
+	client.Middleware = []Middleware{LoggingMiddleware(logger)}
+	client.Retries = 1
+	client.RetryBackoff = time.Millisecond
+
+	_, err := client.GetTimelines(context.Background(), &TimelineListOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, buf.String(), "secret_key")
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var r map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &r))
+		records = append(records, r)
+	}
+	require.Len(t, records, 5)
+	assert.Equal(t, "climacell request", records[0]["msg"])
+	assert.Equal(t, server.URL+"/timelines?apikey=REDACTED", records[0]["url"])
+	assert.Equal(t, "WARN", records[1]["level"])
+	assert.Equal(t, 500.0, records[1]["status"])
+	assert.Equal(t, "climacell request retrying", records[2]["msg"])
+	assert.Equal(t, 2.0, records[3]["attempt"])
+	assert.Equal(t, "INFO", records[4]["level"])
+
+	// errors without a response are redacted too
+	server.Close()
+	buf.Reset()
+	_, err = client.ListLocations(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, buf.String(), "climacell request failed")
+	assert.NotContains(t, buf.String(), "secret_key")
+}
+
+func TestMiddlewareOnShared(t *testing.T) {
+	s := &heldServer{release: make(chan struct{}), body: `{"data": {"timelines": []}}`}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	var sent, shared int32
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+	client.Middleware = []Middleware{{
+		BeforeRequest: func(ctx context.Context, req *RequestInfo) (context.Context, error) {
+			atomic.AddInt32(&sent, 1)
+			return ctx, nil
+		},
+		OnShared: func(ctx context.Context, req *RequestInfo, meta ResponseMeta) {
+			if req.Endpoint == "timelines" && req.Attempt == 0 && meta.StatusCode == http.StatusOK {
+				atomic.AddInt32(&shared, 1)
+			}
+		},
+	}}
+
+	concurrently(s, 4, func(i int) {
+		_, err := client.GetTimelines(context.Background(), &TimelineListOptions{LocationID: "home"})
+		assert.NoError(t, err)
+	})
+	assert.EqualValues(t, 1, s.requests)
+	assert.EqualValues(t, 1, sent)
+	assert.EqualValues(t, 3, shared)
+}
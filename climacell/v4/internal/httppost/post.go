@@ -0,0 +1,41 @@
+// Package httppost sends the POST requests of the packages that push data
+// to other services, like the tsdb writers and the notify sinks.
+package httppost
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var defaultClient = &http.Client{Timeout: time.Minute}
+
+// Post sends body to url, returning an error if the response status isn't a
+// 2xx status. A nil c uses a client with a one minute timeout.
+func Post(ctx context.Context, c *http.Client, url string, header http.Header, body []byte) error {
+	if c == nil {
+		c = defaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+
+	res, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return errors.Errorf("post to %s failed with status %d: %s", req.URL.Host, res.StatusCode, bytes.TrimSpace(msg))
+	}
+	io.Copy(io.Discard, res.Body)
+	return nil
+}
@@ -0,0 +1,112 @@
+package interpolate
+
+import (
+	"errors"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+	"github.com/maskarb/climacell-go/climacell/v4/grid"
+)
+
+// ErrOutsideGrid is returned by Bilinear for coordinates outside its grid.
+var ErrOutsideGrid = errors.New("coordinates outside the grid")
+
+// ErrOutsideTimes is returned by Bilinear for a Time that isn't an index of
+// its grid's Times.
+var ErrOutsideTimes = errors.New("time outside the grid's times")
+
+// Bilinear interpolates between the values of the four points of a grid
+// around the coordinates, weighted by how close the coordinates are to each
+// along the grid's axes. Points missing from the grid don't count, so near
+// the edge of a sampled polygon fewer points are averaged.
+type Bilinear struct {
+	Grid *grid.Grid
+	// Time is the index of the grid's Times to interpolate at.
+	Time int
+}
+
+// Interpolate implements Interpolator.
+func (b *Bilinear) Interpolate(at climacell.LatLon) (climacell.Values, error) {
+	if b.Time < 0 || b.Time >= len(b.Grid.Times) {
+		return nil, ErrOutsideTimes
+	}
+	i0, i1, y, ok := locate(b.Grid.Lats, at.Lat)
+	if !ok {
+		return nil, ErrOutsideGrid
+	}
+	j0, j1, x, ok := locate(b.Grid.Lons, at.Lon)
+	if !ok {
+		return nil, ErrOutsideGrid
+	}
+
+	var (
+		weights []float64
+		values  []climacell.Values
+	)
+	corners := []struct {
+		lat, lon int
+		weight   float64
+	}{
+		{i0, j0, (1 - y) * (1 - x)},
+		{i0, j1, (1 - y) * x},
+		{i1, j0, y * (1 - x)},
+		{i1, j1, y * x},
+	}
+	for _, c := range corners {
+		if c.weight == 0 || !b.Grid.Inside(c.lat, c.lon) {
+			continue
+		}
+		weights = append(weights, c.weight)
+		values = append(values, gridValues(b.Grid, c.lat, c.lon, b.Time))
+	}
+	if len(values) == 0 {
+		return nil, ErrNoSamples
+	}
+	return combine(weights, values), nil
+}
+
+// locate returns the indices of the coordinates of an ascending axis around
+// x, and how far x is from the first to the second, or false if x is
+// outside the axis.
+func locate(axis []float64, x float64) (i0, i1 int, frac float64, ok bool) {
+	if len(axis) == 0 || x < axis[0] || x > axis[len(axis)-1] {
+		return 0, 0, 0, false
+	}
+	for i := 0; i < len(axis)-1; i++ {
+		if x <= axis[i+1] {
+			return i, i + 1, (x - axis[i]) / (axis[i+1] - axis[i]), true
+		}
+	}
+	// x is the only coordinate of the axis
+	return len(axis) - 1, len(axis) - 1, 0, true
+}
+
+func gridValues(g *grid.Grid, lat, lon, t int) climacell.Values {
+	values := climacell.Values{}
+	for f, name := range g.Fields {
+		if v, ok := g.Value(lat, lon, t, f); ok {
+			values[name] = v
+		}
+	}
+	return values
+}
+
+// GridSamples returns the values of a grid's sampled points at index t of
+// its Times, to interpolate with Nearest or IDW. There are none if t isn't an
+// index of the Times.
+func GridSamples(g *grid.Grid, t int) []Sample {
+	if t < 0 || t >= len(g.Times) {
+		return nil
+	}
+	var samples []Sample
+	for i, lat := range g.Lats {
+		for j, lon := range g.Lons {
+			if g.Inside(i, j) {
+				samples = append(samples, Sample{
+					Location: climacell.LatLon{Lat: lat, Lon: lon},
+					Values:   gridValues(g, i, j, t),
+				})
+			}
+		}
+	}
+	return samples
+}
@@ -0,0 +1,254 @@
+// Package interpolate estimates the values of timeline fields at arbitrary
+// coordinates from the values sampled at nearby points, without requesting
+// them from the API.
+//
+// Three methods are provided: Nearest takes the values of the closest
+// sample, IDW weights every sample by its inverse distance, and Bilinear
+// interpolates between the four points of a grid.Grid around the
+// coordinates. All of them treat fields according to the field registry:
+//
+//   - float and int fields are averaged, and int fields rounded;
+//   - windSpeed and windDirection are averaged together as a vector, so
+//     that winds from 350 and 10 degrees average to a wind from 0 rather
+//     than 180 degrees, and opposing winds cancel out; samples without
+//     both fields only count if none has both;
+//   - enum, time and unknown fields are taken from the sample with the
+//     greatest weight.
+//
+// A sample missing a field doesn't count towards that field's average.
+package interpolate
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// Wind fields averaged as vectors.
+const (
+	WindSpeed     = "windSpeed"
+	WindDirection = "windDirection"
+)
+
+// EarthRadius is the mean radius of the Earth, in meters.
+const EarthRadius = 6371008.8
+
+// ErrNoSamples is returned when there are no samples to interpolate from.
+var ErrNoSamples = errors.New("no samples to interpolate from")
+
+// Sample is the values of timeline fields at a location.
+type Sample struct {
+	Location climacell.LatLon
+	Values   climacell.Values
+}
+
+// Interpolator estimates the values of fields at coordinates.
+type Interpolator interface {
+	// Interpolate returns the estimated values at a location.
+	Interpolate(at climacell.LatLon) (climacell.Values, error)
+}
+
+// Distance returns the great-circle distance between two locations, in
+// meters.
+func Distance(a, b climacell.LatLon) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat, dLon := lat2-lat1, radians(b.Lon-a.Lon)
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+
+// Nearest interpolates by taking the values of the closest sample.
+type Nearest struct {
+	Samples []Sample
+}
+
+// Interpolate implements Interpolator.
+func (n *Nearest) Interpolate(at climacell.LatLon) (climacell.Values, error) {
+	if len(n.Samples) == 0 {
+		return nil, ErrNoSamples
+	}
+	nearest, min := 0, math.Inf(1)
+	for i, s := range n.Samples {
+		if d := Distance(at, s.Location); d < min {
+			nearest, min = i, d
+		}
+	}
+	return combine([]float64{1}, []climacell.Values{n.Samples[nearest].Values}), nil
+}
+
+// DefaultPower is the power of the distance IDW weights samples with.
+const DefaultPower = 2
+
+// IDW interpolates by inverse distance weighting, averaging the samples
+// weighted by one over their distance to a power, so that closer samples
+// count for more. At the location of a sample, it returns that sample's
+// values.
+type IDW struct {
+	Samples []Sample
+	// Power is the power of the distance, which defaults to DefaultPower.
+	// Higher powers favor the closest samples more.
+	Power float64
+	// Neighbors, if positive, is how many of the closest samples to
+	// average. By default every sample is.
+	Neighbors int
+}
+
+// Interpolate implements Interpolator.
+func (idw *IDW) Interpolate(at climacell.LatLon) (climacell.Values, error) {
+	if len(idw.Samples) == 0 {
+		return nil, ErrNoSamples
+	}
+	power := idw.Power
+	if power <= 0 {
+		power = DefaultPower
+	}
+
+	type neighbor struct {
+		distance float64
+		values   climacell.Values
+	}
+	neighbors := make([]neighbor, len(idw.Samples))
+	for i, s := range idw.Samples {
+		neighbors[i] = neighbor{Distance(at, s.Location), s.Values}
+	}
+	sort.SliceStable(neighbors, func(i, j int) bool { return neighbors[i].distance < neighbors[j].distance })
+	if idw.Neighbors > 0 && idw.Neighbors < len(neighbors) {
+		neighbors = neighbors[:idw.Neighbors]
+	}
+
+	// within a millimeter, the location is the sample's
+	if neighbors[0].distance < 1e-3 {
+		return combine([]float64{1}, []climacell.Values{neighbors[0].values}), nil
+	}
+	weights := make([]float64, len(neighbors))
+	values := make([]climacell.Values, len(neighbors))
+	for i, n := range neighbors {
+		weights[i] = 1 / math.Pow(n.distance, power)
+		values[i] = n.values
+	}
+	return combine(weights, values), nil
+}
+
+// combine returns the weighted average of values, with each field averaged
+// according to its kind. The values are copied, so the result never shares
+// them.
+func combine(weights []float64, values []climacell.Values) climacell.Values {
+	res := climacell.Values{}
+	fields := map[string]bool{}
+	for _, v := range values {
+		for name := range v {
+			fields[name] = true
+		}
+	}
+
+	for name := range fields {
+		if name == WindSpeed || name == WindDirection {
+			continue
+		}
+		f, ok := climacell.LookupField(name)
+		numeric := ok && (f.Kind == climacell.FieldFloat || f.Kind == climacell.FieldInt)
+		if !numeric {
+			if v, ok := heaviest(weights, values, name); ok {
+				res[name] = v
+			}
+			continue
+		}
+
+		var sum, total float64
+		for i, v := range values {
+			if x, ok := v.Float(name); ok {
+				sum += weights[i] * x
+				total += weights[i]
+			}
+		}
+		if total == 0 {
+			if v, ok := heaviest(weights, values, name); ok {
+				res[name] = v
+			}
+			continue
+		}
+		avg := sum / total
+		if f.Kind == climacell.FieldInt {
+			avg = math.Round(avg)
+		}
+		res[name] = avg
+	}
+
+	combineWind(weights, values, res)
+	return res
+}
+
+// combineWind averages the wind as a vector, from the samples with both a
+// speed and a direction. If no sample has both, the speeds are averaged like
+// any other field and the directions as unit vectors.
+func combineWind(weights []float64, values []climacell.Values, res climacell.Values) {
+	var paired bool
+	for _, vals := range values {
+		_, hasSpeed := vals.Float(WindSpeed)
+		_, hasDir := vals.Float(WindDirection)
+		if hasSpeed && hasDir {
+			paired = true
+			break
+		}
+	}
+
+	var u, v, dirTotal, speedSum, speedTotal float64
+	for i, vals := range values {
+		speed, hasSpeed := vals.Float(WindSpeed)
+		dir, hasDir := vals.Float(WindDirection)
+		if paired && !(hasSpeed && hasDir) {
+			continue
+		}
+		if hasSpeed {
+			speedSum += weights[i] * speed
+			speedTotal += weights[i]
+		}
+		if !hasDir {
+			continue
+		}
+		if !paired {
+			speed = 1
+		}
+		// the direction is where the wind blows from, clockwise from north
+		u += weights[i] * -speed * math.Sin(radians(dir))
+		v += weights[i] * -speed * math.Cos(radians(dir))
+		dirTotal += weights[i]
+	}
+
+	if speedTotal > 0 && !paired {
+		res[WindSpeed] = speedSum / speedTotal
+	}
+	if dirTotal == 0 {
+		return
+	}
+	u, v = u/dirTotal, v/dirTotal
+	dir := math.Mod(math.Atan2(-u, -v)*180/math.Pi+360, 360)
+	// round away the noise of going through sines and cosines
+	dir = math.Round(dir*1e9) / 1e9
+	if dir == 360 {
+		dir = 0
+	}
+	res[WindDirection] = dir
+	if paired {
+		res[WindSpeed] = math.Round(math.Hypot(u, v)*1e9) / 1e9
+	}
+}
+
+// heaviest returns the value of a field from the sample with the greatest
+// weight that has it.
+func heaviest(weights []float64, values []climacell.Values, name string) (interface{}, bool) {
+	best, found := math.Inf(-1), -1
+	for i, v := range values {
+		if _, ok := v[name]; ok && weights[i] > best {
+			best, found = weights[i], i
+		}
+	}
+	if found < 0 {
+		return nil, false
+	}
+	return values[found][name], true
+}
@@ -0,0 +1,148 @@
+package interpolate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+	"github.com/maskarb/climacell-go/climacell/v4/grid"
+)
+
+var samples = []Sample{
+	{climacell.LatLon{Lat: 0, Lon: 0}, climacell.Values{"temperature": 10.0, "weatherCode": 1000.0, "windSpeed": 10.0, "windDirection": 350.0}},
+	{climacell.LatLon{Lat: 0, Lon: 1}, climacell.Values{"temperature": 20.0, "weatherCode": 4001.0, "windSpeed": 10.0, "windDirection": 10.0}},
+}
+
+func TestDistance(t *testing.T) {
+	// a degree of longitude at the equator
+	assert.InDelta(t, 111195, Distance(climacell.LatLon{}, climacell.LatLon{Lon: 1}), 1)
+	assert.Zero(t, Distance(climacell.LatLon{Lat: 35, Lon: -78}, climacell.LatLon{Lat: 35, Lon: -78}))
+}
+
+func TestNearest(t *testing.T) {
+	n := &Nearest{Samples: samples}
+	v, err := n.Interpolate(climacell.LatLon{Lat: 0.1, Lon: 0.8})
+	require.NoError(t, err)
+	assert.Equal(t, samples[1].Values, v)
+
+	// the result is a copy
+	v["temperature"] = 0.0
+	assert.Equal(t, 20.0, samples[1].Values["temperature"])
+
+	_, err = (&Nearest{}).Interpolate(climacell.LatLon{})
+	assert.Equal(t, ErrNoSamples, err)
+}
+
+func TestIDW(t *testing.T) {
+	idw := &IDW{Samples: samples}
+
+	v, err := idw.Interpolate(climacell.LatLon{Lat: 0, Lon: 0.5})
+	require.NoError(t, err)
+	assert.InDelta(t, 15.0, v["temperature"], 1e-9)
+	// winds of 10 m/s from 350 and 10 degrees average to about 9.85 m/s
+	// from the north, not 10 m/s from the south
+	assert.Equal(t, 0.0, v["windDirection"])
+	assert.InDelta(t, 9.848, v["windSpeed"], 1e-3)
+	assert.Contains(t, []interface{}{1000.0, 4001.0}, v["weatherCode"])
+
+	v, err = idw.Interpolate(climacell.LatLon{Lat: 0, Lon: 0.25})
+	require.NoError(t, err)
+	// weights of 1/0.25² and 1/0.75² are 9 to 1
+	assert.InDelta(t, 11.0, v["temperature"], 1e-3)
+	assert.Equal(t, 1000.0, v["weatherCode"])
+
+	v, err = idw.Interpolate(samples[1].Location)
+	require.NoError(t, err)
+	assert.Equal(t, samples[1].Values, v)
+
+	idw.Neighbors = 1
+	v, err = idw.Interpolate(climacell.LatLon{Lat: 0, Lon: 0.4})
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, v["temperature"])
+}
+
+func TestCombine(t *testing.T) {
+	v := combine([]float64{1, 1, 2}, []climacell.Values{
+		{"epaIndex": 10.0, "windDirection": 90.0, "sunriseTime": "2020-12-21T12:00:00Z"},
+		{"epaIndex": 11.0, "windSpeed": 4.0},
+		{"windDirection": 270.0, "sunriseTime": "2020-12-21T12:01:00Z", "unknown": "x"},
+	})
+	// int fields are rounded, and samples without a field don't count
+	assert.Equal(t, 11.0, v["epaIndex"])
+	assert.Equal(t, "2020-12-21T12:01:00Z", v["sunriseTime"])
+	assert.Equal(t, "x", v["unknown"])
+	// without speeds, directions are averaged as unit vectors
+	assert.InDelta(t, 270.0, v["windDirection"], 1e-9)
+	// a speed without a direction is averaged on its own
+	assert.Equal(t, 4.0, v["windSpeed"])
+}
+
+// sampleGrid samples a 2x2 grid with temperatures 10*lat+lon from a test
+// server.
+func sampleGrid(t *testing.T) *grid.Grid {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var lon, lat float64
+		body, _ := io.ReadAll(r.Body)
+		fmt.Sscanf(string(body), `{"location":{"type":"Point","coordinates":[%g,%g]}`, &lon, &lat)
+		fmt.Fprintf(w, `{"data": {"timelines": [{"timestep": "1h", "intervals": [
+			{"startTime": "2020-12-21T06:00:00Z", "values": {"temperature": %v, "windSpeed": 5, "windDirection": %v}}
+		]}]}}`, 10*lat+lon, 90+180*lat)
+	}))
+	defer server.Close()
+
+	c := climacell.NewClient("test_api_key")
+	c.BaseURL = server.URL
+	g, err := grid.Sample(context.Background(), c, grid.BBox{MaxLon: 1, MaxLat: 1}.Polygon(), 1,
+		&climacell.TimelineListOptions{Fields: []string{"temperature", "windSpeed", "windDirection"}}, 0)
+	require.NoError(t, err)
+	return g
+}
+
+func TestBilinear(t *testing.T) {
+	g := sampleGrid(t)
+	b := &Bilinear{Grid: g}
+
+	v, err := b.Interpolate(climacell.LatLon{Lat: 0.25, Lon: 0.5})
+	require.NoError(t, err)
+	assert.InDelta(t, 3.0, v["temperature"], 1e-9)
+
+	v, err = b.Interpolate(climacell.LatLon{Lat: 1, Lon: 1})
+	require.NoError(t, err)
+	assert.InDelta(t, 11.0, v["temperature"], 1e-9)
+	assert.InDelta(t, 270.0, v["windDirection"], 1e-9)
+
+	// easterly and westerly winds of the same speed cancel out halfway
+	v, err = b.Interpolate(climacell.LatLon{Lat: 0.5, Lon: 0.5})
+	require.NoError(t, err)
+	assert.InDelta(t, 0.0, v["windSpeed"], 1e-9)
+
+	_, err = b.Interpolate(climacell.LatLon{Lat: 2, Lon: 0})
+	assert.Equal(t, ErrOutsideGrid, err)
+
+	samples := GridSamples(g, 0)
+	require.Len(t, samples, 4)
+	assert.Equal(t, climacell.LatLon{Lat: 1, Lon: 0}, samples[2].Location)
+	assert.Equal(t, 10.0, samples[2].Values["temperature"])
+}
+
+func TestBilinearOutsideTimes(t *testing.T) {
+	g := sampleGrid(t)
+	for _, i := range []int{-1, 1} {
+		b := &Bilinear{Grid: g, Time: i}
+		_, err := b.Interpolate(climacell.LatLon{Lat: 0.5, Lon: 0.5})
+		assert.Equal(t, ErrOutsideTimes, err)
+		assert.Empty(t, GridSamples(g, i))
+	}
+
+	// a grid without times has no values at all
+	b := &Bilinear{Grid: &grid.Grid{Lats: g.Lats, Lons: g.Lons, Fields: g.Fields}}
+	_, err := b.Interpolate(climacell.LatLon{Lat: 0.5, Lon: 0.5})
+	assert.Equal(t, ErrOutsideTimes, err)
+}
@@ -0,0 +1,275 @@
+package climacell
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultKeyCooldown is how long a KeyPool stops using a key after it is
+// rejected.
+const DefaultKeyCooldown = time.Minute
+
+// ErrNoKeys is returned by requests of a client with a KeyPool whose keys
+// are all cooling down.
+var ErrNoKeys = errors.New("no API key available")
+
+// APIKey is a key of a KeyPool.
+type APIKey struct {
+	// Name identifies the key in the pool's usage and to Middleware,
+	// without revealing it. It defaults to the key's position in the pool
+	// and, for keys of 8 characters or more, their last four characters,
+	// like "key 1 (...1a2b)".
+	Name string
+	// Key is the API key.
+	Key string
+	// Tier is the priority of the key. Keys in lower tiers are used first,
+	// and keys in higher tiers only while every key in the lower tiers is
+	// cooling down.
+	Tier int
+}
+
+// KeyUsage reports the usage of a key of a KeyPool.
+type KeyUsage struct {
+	Name string
+	Tier int
+	// Requests is the number of requests sent with the key, and Rejected
+	// the number of them rejected with a 401, 403 or 429 response.
+	Requests int
+	Rejected int
+	// Remaining is the number of requests remaining in the key's tightest
+	// rate limit window, as last reported by the API, or -1 if it isn't
+	// known.
+	Remaining int
+	// LastStatus is the status code of the key's last response, or 0 if it
+	// has none.
+	LastStatus int
+	// CooldownUntil is when the key is used again if it is cooling down,
+	// and zero otherwise.
+	CooldownUntil time.Time
+}
+
+// KeyPool distributes the requests of a client across several API keys,
+// such as the keys of different plans. Set it as the Keys of a ClientV4 or
+// ClientV3, whose own API key is then ignored.
+//
+// Each request uses the key with the most remaining quota, as reported by
+// the API's X-RateLimit-Remaining headers, of the lowest tier with a key
+// available; keys whose quota isn't known yet are used first. A key
+// rejected with a 401, 403 or 429 response, or that has no quota left, cools
+// down and isn't used for the pool's Cooldown, or the response's
+// Retry-After if longer, and the request is sent again right away with
+// another key if there is one. Otherwise a retry of the request waits for
+// the first key to cool down, up to the client's MaxRetryWait, and a
+// request that runs out of retries fails with the API's error.
+//
+// A KeyPool is safe for concurrent use, and can be shared between clients.
+type KeyPool struct {
+	// Cooldown is how long a rejected key isn't used. It defaults to
+	// DefaultKeyCooldown.
+	Cooldown time.Duration
+
+	mu   sync.Mutex
+	keys []*poolKey
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+type poolKey struct {
+	APIKey
+	usage KeyUsage
+}
+
+// NewKeyPool returns a KeyPool of keys.
+func NewKeyPool(keys ...APIKey) *KeyPool {
+	p := &KeyPool{now: time.Now}
+	for i, k := range keys {
+		if k.Name == "" {
+			k.Name = keyName(i, k.Key)
+		}
+		p.keys = append(p.keys, &poolKey{
+			APIKey: k,
+			usage:  KeyUsage{Name: k.Name, Tier: k.Tier, Remaining: -1},
+		})
+	}
+	return p
+}
+
+// Usage returns the usage of the pool's keys, in the order they were given
+// to NewKeyPool.
+func (p *KeyPool) Usage() []KeyUsage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	res := make([]KeyUsage, len(p.keys))
+	for i, k := range p.keys {
+		res[i] = k.usage
+	}
+	return res
+}
+
+// acquire returns the key to send a request with, or nil for a nil
+// KeyPool.
+func (p *KeyPool) acquire() (*poolKey, error) {
+	if p == nil {
+		return nil, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock()
+	var best *poolKey
+	for _, k := range p.keys {
+		if !k.usage.CooldownUntil.IsZero() {
+			if now.Before(k.usage.CooldownUntil) {
+				continue
+			}
+			// the quota of a key that cooled down is unknown until its
+			// next response
+			k.usage.CooldownUntil = time.Time{}
+			k.usage.Remaining = -1
+		}
+		if best == nil || better(k, best) {
+			best = k
+		}
+	}
+	if best == nil {
+		return nil, ErrNoKeys
+	}
+
+	best.usage.Requests++
+	// requests in flight count against the remaining quota until the
+	// API reports it, so that concurrent requests are spread across keys
+	if best.usage.Remaining > 0 {
+		best.usage.Remaining--
+	}
+	return best, nil
+}
+
+// clock returns the current time, from now if it is set.
+func (p *KeyPool) clock() time.Time {
+	if p.now == nil {
+		return time.Now()
+	}
+	return p.now()
+}
+
+// wait returns how long until a key of the pool is available again, which
+// is 0 if one is available now or for a nil KeyPool.
+func (p *KeyPool) wait() time.Duration {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock()
+	var earliest time.Time
+	for _, k := range p.keys {
+		if k.usage.CooldownUntil.IsZero() || !now.Before(k.usage.CooldownUntil) {
+			return 0
+		}
+		if earliest.IsZero() || k.usage.CooldownUntil.Before(earliest) {
+			earliest = k.usage.CooldownUntil
+		}
+	}
+	if earliest.IsZero() {
+		return 0
+	}
+	return earliest.Sub(now)
+}
+
+// better reports whether a should be used rather than b.
+func better(a, b *poolKey) bool {
+	if a.Tier != b.Tier {
+		return a.Tier < b.Tier
+	}
+	ra, rb := a.usage.Remaining, b.usage.Remaining
+	if ra != rb {
+		return ra < 0 || (rb >= 0 && ra > rb)
+	}
+	return a.usage.Requests < b.usage.Requests
+}
+
+// report records the response to a request sent with k, returning whether
+// k was rejected and the request can be sent again with another key.
+func (p *KeyPool) report(k *poolKey, res *response) (failover bool) {
+	if k == nil || res == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	k.usage.LastStatus = res.status
+	if remaining, ok := remainingQuota(res.header); ok {
+		k.usage.Remaining = remaining
+	}
+
+	rejected := res.status == http.StatusUnauthorized ||
+		res.status == http.StatusForbidden ||
+		res.status == http.StatusTooManyRequests
+	if rejected {
+		k.usage.Rejected++
+	}
+	if !rejected && k.usage.Remaining != 0 {
+		return false
+	}
+
+	cooldown := p.Cooldown
+	if cooldown <= 0 {
+		cooldown = DefaultKeyCooldown
+	}
+	if wait := retryAfter(res, 0); wait > cooldown {
+		cooldown = wait
+	}
+	now := p.clock()
+	k.usage.CooldownUntil = now.Add(cooldown)
+	if !rejected {
+		return false
+	}
+
+	for _, other := range p.keys {
+		if other.usage.CooldownUntil.IsZero() || !now.Before(other.usage.CooldownUntil) {
+			return true
+		}
+	}
+	return false
+}
+
+// remainingQuota returns the fewest requests remaining in any of the rate
+// limit windows of a response's headers.
+func remainingQuota(header http.Header) (int, bool) {
+	remaining, ok := 0, false
+	for _, n := range QuotaRemaining(header) {
+		if !ok || n < remaining {
+			remaining, ok = n, true
+		}
+	}
+	return remaining, ok
+}
+
+// keyName returns the default name of the ith key of a pool, which only
+// reveals the last four characters of keys long enough to keep the rest
+// secret.
+func keyName(i int, key string) string {
+	if len(key) < 8 {
+		return fmt.Sprintf("key %d", i+1)
+	}
+	return fmt.Sprintf("key %d (...%s)", i+1, key[len(key)-4:])
+}
+
+// setQueryKey sets the API key of a ClientV4 request.
+func setQueryKey(req *http.Request, key string) {
+	q := req.URL.Query()
+	q.Set("apikey", key)
+	req.URL.RawQuery = q.Encode()
+}
+
+// setHeaderKey sets the API key of a ClientV3 request.
+func setHeaderKey(req *http.Request, key string) {
+	req.Header.Set("apikey", key)
+}
@@ -0,0 +1,221 @@
+package climacell
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// quotaResponse is a response reporting remaining requests of a key.
+func quotaResponse(status int, remaining string) *response {
+	res := &response{status: status, header: http.Header{}}
+	if remaining != "" {
+		res.header.Set("X-RateLimit-Remaining-Hour", remaining)
+		res.header.Set("X-RateLimit-Remaining-Day", "500")
+	}
+	return res
+}
+
+func TestKeyPoolAcquire(t *testing.T) {
+	now := time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC)
+	p := NewKeyPool(
+		APIKey{Key: "premium_key_1234", Tier: 1},
+		APIKey{Name: "cheap-a", Key: "a", Tier: 0},
+		APIKey{Name: "cheap-b", Key: "b", Tier: 0},
+	)
+	p.now = func() time.Time { return now }
+
+	// keys of the lowest tier are used first, spreading requests while
+	// their quota is unknown
+	a, err := p.acquire()
+	require.NoError(t, err)
+	assert.Equal(t, "cheap-a", a.Name)
+	assert.False(t, p.report(a, quotaResponse(http.StatusOK, "3")))
+	b, _ := p.acquire()
+	assert.Equal(t, "cheap-b", b.Name)
+	assert.False(t, p.report(b, quotaResponse(http.StatusOK, "10")))
+
+	// then the key with the most remaining quota
+	k, _ := p.acquire()
+	assert.Equal(t, "cheap-b", k.Name)
+
+	// a rejected key cools down, and the request fails over
+	assert.True(t, p.report(k, quotaResponse(http.StatusTooManyRequests, "")))
+	k, _ = p.acquire()
+	assert.Equal(t, "cheap-a", k.Name)
+	// a key without quota left cools down too
+	assert.False(t, p.report(k, quotaResponse(http.StatusOK, "0")))
+
+	k, _ = p.acquire()
+	assert.Equal(t, "key 1 (...1234)", k.Name)
+	assert.False(t, p.report(k, quotaResponse(http.StatusUnauthorized, "")))
+	_, err = p.acquire()
+	assert.Equal(t, ErrNoKeys, err)
+
+	usage := p.Usage()
+	assert.Equal(t, KeyUsage{
+		Name: "key 1 (...1234)", Tier: 1, Requests: 1, Rejected: 1, Remaining: -1,
+		LastStatus: 401, CooldownUntil: now.Add(DefaultKeyCooldown),
+	}, usage[0])
+	assert.Equal(t, 2, usage[2].Requests)
+	assert.Equal(t, 1, usage[2].Rejected)
+	// the request in flight counted against the last reported quota
+	assert.Equal(t, 9, usage[2].Remaining)
+
+	// keys are used again after their cooldown, with an unknown quota
+	now = now.Add(DefaultKeyCooldown)
+	k, _ = p.acquire()
+	assert.Equal(t, "cheap-a", k.Name)
+	assert.Equal(t, -1, p.Usage()[1].Remaining)
+	assert.True(t, p.Usage()[1].CooldownUntil.IsZero())
+}
+
+func TestKeyPoolRetryAfterCooldown(t *testing.T) {
+	now := time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC)
+	p := NewKeyPool(APIKey{Key: "a"})
+	p.now = func() time.Time { return now }
+	p.Cooldown = time.Second
+
+	k, _ := p.acquire()
+	res := quotaResponse(http.StatusTooManyRequests, "")
+	res.header.Set("Retry-After", "30")
+	p.report(k, res)
+	assert.Equal(t, now.Add(30*time.Second), p.Usage()[0].CooldownUntil)
+
+	var none *KeyPool
+	k, err := none.acquire()
+	assert.Nil(t, k)
+	assert.NoError(t, err)
+
+	// a pool that isn't made with NewKeyPool uses the current time
+	_, err = (&KeyPool{Cooldown: time.Second}).acquire()
+	assert.Equal(t, ErrNoKeys, err)
+}
+
+func TestKeyPoolNames(t *testing.T) {
+	p := NewKeyPool(APIKey{Key: "a"}, APIKey{Key: "b"}, APIKey{Key: "premium_key_1234"}, APIKey{Name: "mine", Key: "c"})
+	var names []string
+	for _, u := range p.Usage() {
+		names = append(names, u.Name)
+	}
+	assert.Equal(t, []string{"key 1", "key 2", "key 3 (...1234)", "mine"}, names)
+}
+
+func TestClientKeyPool(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("apikey")
+		keys = append(keys, key)
+		switch key {
+		case "revoked_key":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code": 401001, "type": "Invalid Key", "message": "revoked"}`))
+		case "spent_key":
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"code": 429001, "type": "Too Many Calls", "message": "slow down"}`))
+		default:
+			w.Header().Set("X-RateLimit-Remaining-Hour", "99")
+			w.Write([]byte(`{"data": {"timelines": []}}`))
+		}
+	}))
+	defer server.Close()
+
+	var calls []string
+	client := NewClient("")
+	client.BaseURL = server.URL
+	client.Middleware = []Middleware{recorder(&calls)}
+	client.Keys = NewKeyPool(
+		APIKey{Name: "revoked", Key: "revoked_key"},
+		APIKey{Name: "spent", Key: "spent_key"},
+		APIKey{Name: "premium", Key: "premium_key", Tier: 1},
+	)
+
+	// failovers don't need retries
+	_, err := client.GetTimelines(context.Background(), &TimelineListOptions{LocationID: "home"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"revoked_key", "spent_key", "premium_key"}, keys)
+	assert.Equal(t, []string{
+		"before timelines", "after Unauthorized", "retry 401 (401001) API error: revoked",
+		"before timelines", "after Too Many Requests", "retry 429 (429001) API error: slow down",
+		"before timelines", "after OK",
+	}, calls)
+
+	usage := client.Keys.Usage()
+	assert.Equal(t, 1, usage[0].Rejected)
+	assert.False(t, usage[1].CooldownUntil.IsZero())
+	assert.Equal(t, 1, usage[2].Requests)
+	assert.Equal(t, 99, usage[2].Remaining)
+
+	// a pool whose only key is rejected has no keys left until it cools down
+	client.Keys = NewKeyPool(APIKey{Key: "revoked_key"})
+	_, err = client.ListAlerts(context.Background())
+	var errRes *ErrorResponse
+	require.ErrorAs(t, err, &errRes)
+	assert.Equal(t, http.StatusUnauthorized, errRes.StatusCode)
+	_, err = client.ListAlerts(context.Background())
+	assert.Equal(t, ErrNoKeys, err)
+}
+
+func TestClientV3KeyPool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("apikey") != "good_key" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"statusCode": 403, "message": "forbidden"}`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	client := New("")
+	client.baseURL = server.URL
+	client.Keys = NewKeyPool(APIKey{Key: "bad_key"}, APIKey{Key: "good_key", Tier: 1})
+
+	_, err := client.Nowcast(ForecastArgs{Location: LatLon{Lat: 1, Lon: 2}})
+	require.NoError(t, err)
+	usage := client.Keys.Usage()
+	assert.Equal(t, 403, usage[0].LastStatus)
+	assert.Equal(t, 200, usage[1].LastStatus)
+}
+
+func TestClientKeyPoolRetries(t *testing.T) {
+	var n int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		if n == 1 || r.URL.Query().Get("apikey") == "spent_key" {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"code": 429001, "type": "Too Many Calls", "message": "slow down"}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"timelines": []}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("")
+	client.BaseURL = server.URL
+	client.Retries = 1
+	client.RetryBackoff = time.Millisecond
+
+	// the retry waits for the only key to cool down
+	client.Keys = NewKeyPool(APIKey{Key: "only_key"})
+	client.Keys.Cooldown = 20 * time.Millisecond
+	start := time.Now()
+	_, err := client.GetTimelines(context.Background(), &TimelineListOptions{})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.EqualValues(t, 2, n)
+
+	// and a key still cooling down fails the request with the API's error
+	client.Keys = NewKeyPool(APIKey{Key: "spent_key"})
+	client.MaxRetryWait = time.Millisecond
+	_, err = client.GetTimelines(context.Background(), &TimelineListOptions{})
+	var errRes *ErrorResponse
+	require.ErrorAs(t, err, &errRes)
+	assert.Equal(t, http.StatusTooManyRequests, errRes.StatusCode)
+}
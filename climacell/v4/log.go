@@ -0,0 +1,81 @@
+package climacell
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// redacted replaces API keys in logged URLs and errors.
+const redacted = "REDACTED"
+
+// LoggingMiddleware returns Middleware logging the requests of a client to
+// logger as structured records: each attempt at debug level, responses at
+// info level, or warn level for error statuses, retries at warn level and
+// failed requests at error level. The client's API key is redacted from the
+// logged URLs and errors.
+func LoggingMiddleware(logger *slog.Logger) Middleware {
+	attrs := func(req *RequestInfo) []any {
+		args := []any{
+			slog.String("endpoint", req.Endpoint),
+			slog.String("method", req.HTTP.Method),
+			slog.String("url", redact(req.HTTP, req.HTTP.URL.String())),
+			slog.Int("attempt", req.Attempt),
+		}
+		if req.Key != "" {
+			args = append(args, slog.String("key", req.Key))
+		}
+		return args
+	}
+	return Middleware{
+		BeforeRequest: func(ctx context.Context, req *RequestInfo) (context.Context, error) {
+			logger.DebugContext(ctx, "climacell request", attrs(req)...)
+			return ctx, nil
+		},
+		AfterResponse: func(ctx context.Context, req *RequestInfo, meta ResponseMeta) {
+			level := slog.LevelInfo
+			if meta.StatusCode >= http.StatusBadRequest {
+				level = slog.LevelWarn
+			}
+			args := append(attrs(req),
+				slog.Int("status", meta.StatusCode),
+				slog.Duration("latency", meta.Latency),
+			)
+			if meta.RequestID != "" {
+				args = append(args, slog.String("request_id", meta.RequestID))
+			}
+			if len(meta.Warnings) > 0 {
+				args = append(args, slog.Int("warnings", len(meta.Warnings)))
+			}
+			logger.Log(ctx, level, "climacell response", args...)
+		},
+		OnError: func(ctx context.Context, req *RequestInfo, err error) {
+			args := append(attrs(req), slog.String("error", redact(req.HTTP, err.Error())))
+			logger.ErrorContext(ctx, "climacell request failed", args...)
+		},
+		OnRetry: func(ctx context.Context, req *RequestInfo, err error, wait time.Duration) {
+			args := append(attrs(req),
+				slog.String("error", redact(req.HTTP, err.Error())),
+				slog.Duration("wait", wait),
+			)
+			logger.WarnContext(ctx, "climacell request retrying", args...)
+		},
+	}
+}
+
+// redact replaces the API key of a request in s, which is sent in the
+// "apikey" query parameter by ClientV4 and the "apikey" header by ClientV3.
+func redact(req *http.Request, s string) string {
+	key := req.URL.Query().Get("apikey")
+	if key == "" {
+		key = req.Header.Get("apikey")
+	}
+	if key == "" {
+		return s
+	}
+	s = strings.ReplaceAll(s, key, redacted)
+	return strings.ReplaceAll(s, url.QueryEscape(key), redacted)
+}
@@ -0,0 +1,221 @@
+package climacell
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMergeWindow is how long a Merger waits for more requests to
+	// merge with the first one.
+	DefaultMergeWindow = 10 * time.Millisecond
+	// DefaultMaxFields is the most fields a Merger requests at once.
+	DefaultMaxFields = 50
+)
+
+// Merger merges concurrent requests for the same location and timesteps but
+// different fields into a single request for all of their fields, since the
+// API charges per request and not per field. Each caller gets a copy of the
+// timelines with the values of only the fields it requested.
+//
+// A Merger is safe to use from many goroutines at once:
+//
+//	m := climacell.NewMerger(client)
+//	list, err := m.GetTimelines(ctx, &climacell.TimelineListOptions{...})
+type Merger struct {
+	// Window is how long the first request of a merged request waits for
+	// more requests. It defaults to DefaultMergeWindow.
+	Window time.Duration
+	// MaxFields is the most fields requested at once. Requests that would
+	// take a merged request over it are merged into the next one, and
+	// requests with more fields than it are sent on their own. It defaults
+	// to DefaultMaxFields.
+	MaxFields int
+
+	client  *ClientV4
+	mu      sync.Mutex
+	pending map[string]*mergedRequest
+}
+
+// NewMerger returns a Merger sending its merged requests with c.
+func NewMerger(c *ClientV4) *Merger {
+	return &Merger{
+		Window:    DefaultMergeWindow,
+		MaxFields: DefaultMaxFields,
+		client:    c,
+		pending:   map[string]*mergedRequest{},
+	}
+}
+
+// mergedRequest is a request being merged, with the fields of every request
+// merged into it so far.
+type mergedRequest struct {
+	options TimelineListOptions
+	ctx     context.Context
+	fields  map[string]bool
+	done    chan struct{}
+	list    *TimelineList
+	err     error
+	// meta is the ResponseMeta of the merged request, copied to each
+	// caller's context.
+	meta ResponseMeta
+}
+
+// GetTimelines is like ClientV4.GetTimelines, but waits up to the Merger's
+// Window to send the request along with other requests for the same
+// location, timesteps, period and units. If ctx is done before the merged
+// request's response arrives, GetTimelines returns the context's error
+// without failing the other requests merged with it. A nil options is the
+// same as empty options.
+func (m *Merger) GetTimelines(ctx context.Context, options *TimelineListOptions) (*TimelineList, error) {
+	if options == nil {
+		options = &TimelineListOptions{}
+	}
+	max := m.MaxFields
+	if max <= 0 {
+		max = DefaultMaxFields
+	}
+	if len(options.Fields) > max {
+		return m.client.GetTimelines(ctx, options)
+	}
+
+	r := m.add(ctx, options, max)
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if r.meta.StatusCode != 0 {
+		setResponseMeta(ctx, r.meta)
+	}
+	if r.err != nil && !isWarnings(r.err) {
+		if errRes, ok := r.err.(*ErrorResponse); ok {
+			copied := *errRes
+			return nil, &copied
+		}
+		return nil, r.err
+	}
+	return r.list.only(options.Fields), r.err
+}
+
+// add merges a request's fields into the pending request for its key,
+// starting a new one if there is none or the fields don't fit in it.
+func (m *Merger) add(ctx context.Context, options *TimelineListOptions, max int) *mergedRequest {
+	key := mergeKey(options)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending == nil {
+		m.pending = map[string]*mergedRequest{}
+	}
+
+	r := m.pending[key]
+	if r != nil && len(r.fields)+r.missing(options.Fields) > max {
+		delete(m.pending, key)
+		go m.send(r)
+		r = nil
+	}
+	if r == nil {
+		r = m.start(ctx, key, options)
+	}
+	for _, f := range options.Fields {
+		r.fields[f] = true
+	}
+	return r
+}
+
+// start starts a pending request for key, which is sent once the Merger's
+// Window has passed unless it is sent before. m.mu must be held.
+func (m *Merger) start(ctx context.Context, key string, options *TimelineListOptions) *mergedRequest {
+	r := &mergedRequest{
+		options: *options,
+		fields:  map[string]bool{},
+		done:    make(chan struct{}),
+	}
+	r.ctx = WithResponseMeta(context.WithoutCancel(ctx), &r.meta)
+	m.pending[key] = r
+
+	window := m.Window
+	if window <= 0 {
+		window = DefaultMergeWindow
+	}
+	time.AfterFunc(window, func() {
+		m.mu.Lock()
+		pending := m.pending[key] == r
+		if pending {
+			delete(m.pending, key)
+		}
+		m.mu.Unlock()
+		if pending {
+			m.send(r)
+		}
+	})
+	return r
+}
+
+// send sends a merged request once no more requests can be merged into it.
+func (m *Merger) send(r *mergedRequest) {
+	r.options.Fields = make([]string, 0, len(r.fields))
+	for f := range r.fields {
+		r.options.Fields = append(r.options.Fields, f)
+	}
+	r.list, r.err = m.client.GetTimelines(r.ctx, &r.options)
+	close(r.done)
+}
+
+// missing returns how many of fields have not been merged into r yet.
+func (r *mergedRequest) missing(fields []string) int {
+	n := 0
+	for _, f := range fields {
+		if !r.fields[f] {
+			n++
+		}
+	}
+	return n
+}
+
+// mergeKey identifies the requests that can be merged, which are those that
+// only differ in their fields.
+func mergeKey(o *TimelineListOptions) string {
+	location := o.LocationID
+	if location == "" {
+		location = o.Location.Key()
+	}
+	return strings.Join([]string{
+		location,
+		strings.Join(o.TimeSteps, ","),
+		o.StartTime,
+		o.EndTime,
+		o.Units,
+	}, "|")
+}
+
+// only returns a copy of the timelines with only the values of fields, and
+// each interval's Extra fields.
+func (l *TimelineList) only(fields []string) *TimelineList {
+	res := &TimelineList{Timelines: make([]Timeline, len(l.Timelines))}
+	for i, t := range l.Timelines {
+		intervals := make([]Interval, len(t.Intervals))
+		for j, iv := range t.Intervals {
+			values := make(Values, len(fields))
+			for _, f := range fields {
+				if v, ok := iv.Values[f]; ok {
+					values[f] = v
+				}
+			}
+			intervals[j] = Interval{StartTime: iv.StartTime, Values: values}
+			if iv.Extra != nil {
+				intervals[j].Extra = make(map[string]json.RawMessage, len(iv.Extra))
+				for k, raw := range iv.Extra {
+					intervals[j].Extra[k] = append(json.RawMessage(nil), raw...)
+				}
+			}
+		}
+		t.Intervals = intervals
+		res.Timelines[i] = t
+	}
+	return res
+}
@@ -0,0 +1,188 @@
+package climacell
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fieldsServer responds with a value of 1 for every requested field, and
+// records the fields of each request it receives by location.
+type fieldsServer struct {
+	mu       sync.Mutex
+	requests map[string][]string
+}
+
+func (s *fieldsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var opts struct {
+		Location interface{} `json:"location"`
+		Fields   []string    `json:"fields"`
+	}
+	json.NewDecoder(r.Body).Decode(&opts)
+	sort.Strings(opts.Fields)
+
+	s.mu.Lock()
+	if s.requests == nil {
+		s.requests = map[string][]string{}
+	}
+	location := fmt.Sprint(opts.Location)
+	s.requests[location] = append(s.requests[location], strings.Join(opts.Fields, ","))
+	s.mu.Unlock()
+
+	values := map[string]float64{}
+	for _, f := range opts.Fields {
+		values[f] = 1
+	}
+	b, _ := json.Marshal(values)
+	fmt.Fprintf(w, `{"data": {"timelines": [{"timestep": "1h", "intervals": [{"startTime": "2020-12-21T06:00:00Z", "values": %s}]}]}}`, b)
+}
+
+func TestMergerGetTimelines(t *testing.T) {
+	s := &fieldsServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+	m := NewMerger(client)
+	m.Window = 50 * time.Millisecond
+
+	requests := []struct {
+		location string
+		fields   []string
+	}{
+		{"home", []string{"temperature"}},
+		{"home", []string{"windSpeed"}},
+		{"home", []string{"temperature", "humidity"}},
+		{"work", []string{"windSpeed"}},
+	}
+	lists := make([]*TimelineList, len(requests))
+	var wg sync.WaitGroup
+	wg.Add(len(requests))
+	for i, r := range requests {
+		go func(i int, location string, fields []string) {
+			defer wg.Done()
+			list, err := m.GetTimelines(context.Background(), &TimelineListOptions{
+				LocationID: location,
+				Fields:     fields,
+				TimeSteps:  []string{"1h"},
+			})
+			require.NoError(t, err)
+			lists[i] = list
+		}(i, r.location, r.fields)
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string][]string{
+		"home": {"humidity,temperature,windSpeed"},
+		"work": {"windSpeed"},
+	}, s.requests)
+	for i, r := range requests {
+		values := lists[i].Timelines[0].Intervals[0].Values
+		assert.Len(t, values, len(r.fields))
+		for _, f := range r.fields {
+			assert.Contains(t, values, f)
+		}
+	}
+}
+
+func TestMergerMaxFields(t *testing.T) {
+	s := &fieldsServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+	m := NewMerger(client)
+	m.Window = 50 * time.Millisecond
+	m.MaxFields = 2
+
+	var wg sync.WaitGroup
+	for _, fields := range [][]string{{"temperature", "humidity"}, {"temperature"}, {"windSpeed"}, {"a", "b", "c"}} {
+		wg.Add(1)
+		go func(fields []string) {
+			defer wg.Done()
+			list, err := m.GetTimelines(context.Background(), &TimelineListOptions{LocationID: "home", Fields: fields})
+			require.NoError(t, err)
+			assert.Len(t, list.Timelines[0].Intervals[0].Values, len(fields))
+		}(fields)
+		// keep the order the requests are merged in deterministic
+		time.Sleep(5 * time.Millisecond)
+	}
+	wg.Wait()
+
+	// requests with too many fields are sent without waiting
+	assert.Equal(t, []string{"humidity,temperature", "a,b,c", "windSpeed"}, s.requests["home"])
+}
+
+func TestMergerCanceled(t *testing.T) {
+	s := &fieldsServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+	m := NewMerger(client)
+	m.Window = 50 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := m.GetTimelines(ctx, &TimelineListOptions{LocationID: "home", Fields: []string{"temperature"}})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}()
+
+	list, err := m.GetTimelines(context.Background(), &TimelineListOptions{LocationID: "home", Fields: []string{"windSpeed"}})
+	require.NoError(t, err)
+	assert.Len(t, list.Timelines[0].Intervals[0].Values, 1)
+	wg.Wait()
+	assert.Equal(t, []string{"temperature,windSpeed"}, s.requests["home"])
+}
+
+func TestMergerNilOptions(t *testing.T) {
+	s := &fieldsServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+	m := NewMerger(client)
+	m.Window = time.Millisecond
+
+	_, err := m.GetTimelines(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Len(t, s.requests, 1)
+}
+
+func TestMergerKeepsExtra(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"timelines": [{"timestep": "1h", "intervals": [
+			{"startTime": "2020-12-21T06:00:00Z", "values": {"temperature": 1, "windSpeed": 2}, "quality": {"score": 0.9}}
+		]}]}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+	m := NewMerger(client)
+	m.Window = time.Millisecond
+
+	list, err := m.GetTimelines(context.Background(), &TimelineListOptions{LocationID: "home", Fields: []string{"temperature"}})
+	require.NoError(t, err)
+	iv := list.Timelines[0].Intervals[0]
+	assert.Equal(t, Values{"temperature": 1.0}, iv.Values)
+	assert.JSONEq(t, `{"score": 0.9}`, string(iv.Extra["quality"]))
+}
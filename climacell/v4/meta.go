@@ -0,0 +1,153 @@
+package climacell
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// quotaHeaderPrefix is the prefix of the response headers with the number of
+// requests remaining in each rate limit window, like
+// "X-RateLimit-Remaining-Hour", in canonical form.
+const quotaHeaderPrefix = "X-Ratelimit-Remaining-"
+
+// requestIDHeaders are the headers that the ID of a request is read from, in
+// order of preference.
+var requestIDHeaders = []string{"X-Request-Id", "X-Correlation-Id", "X-Amzn-Requestid"}
+
+// ResponseMeta is what a response from the ClimaCell API had besides its
+// decoded data, for debugging: the exact JSON returned, and headers like the
+// request ID and rate limit counters.
+//
+// The calls of a ClientV4, and the Context calls of a ClientV3 like
+// NowcastContext, fill in the ResponseMeta of a context from
+// WithResponseMeta:
+//
+//	var meta climacell.ResponseMeta
+//	list, err := c.GetTimelines(climacell.WithResponseMeta(ctx, &meta), options)
+//	fmt.Println(meta.RequestID, meta.Remaining()["day"])
+//
+// It is filled in whenever a response is received, including error
+// responses. Calls that make several requests, like BatchGetTimelines, fill
+// it in with the last response received.
+type ResponseMeta struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Header are the response's headers.
+	Header http.Header
+	// Body is the response's raw body.
+	Body []byte
+	// Latency is how long the request took, from sending it to reading
+	// its body. Requests coalesced with an identical request have the
+	// latency of that request.
+	Latency time.Duration
+	// RequestID is the ID of the request from the response's headers, if
+	// the API returned one.
+	RequestID string
+	// Warnings are the warnings of the envelope of a v4 response, like
+	// a field that isn't available for the requested location.
+	Warnings []Warning
+}
+
+// Warning is a warning about a request returned by the v4 API along with its
+// data.
+type Warning struct {
+	Code    int    `json:"code"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	// Meta holds the warning's details, which depend on its type.
+	Meta json.RawMessage `json:"meta,omitempty"`
+}
+
+type metaKey struct{}
+
+// metaHolder guards a ResponseMeta filled in by concurrent requests.
+type metaHolder struct {
+	mu   sync.Mutex
+	meta *ResponseMeta
+}
+
+// WithResponseMeta returns a context that makes the client calls it is
+// passed to fill in meta with the metadata of their response.
+func WithResponseMeta(ctx context.Context, meta *ResponseMeta) context.Context {
+	return context.WithValue(ctx, metaKey{}, &metaHolder{meta: meta})
+}
+
+// setResponseMeta fills in the ResponseMeta of ctx, if any, with a copy of
+// meta.
+func setResponseMeta(ctx context.Context, meta ResponseMeta) {
+	h, ok := ctx.Value(metaKey{}).(*metaHolder)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*h.meta = meta.clone()
+}
+
+// Remaining returns the number of requests remaining in each rate limit
+// window of the response, as reported by its X-RateLimit-Remaining headers,
+// like QuotaRemaining.
+func (m ResponseMeta) Remaining() map[string]int {
+	return QuotaRemaining(m.Header)
+}
+
+// QuotaRemaining returns the number of requests remaining in each rate limit
+// window of a ClimaCell API response's headers, by the lowercase name of the
+// window: X-RateLimit-Remaining-Hour is returned as "hour". It is nil if the
+// headers have none.
+func QuotaRemaining(header http.Header) map[string]int {
+	var res map[string]int
+	for name, values := range header {
+		if !strings.HasPrefix(name, quotaHeaderPrefix) || len(values) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(values[0])
+		if err != nil {
+			continue
+		}
+		if res == nil {
+			res = map[string]int{}
+		}
+		res[strings.ToLower(strings.TrimPrefix(name, quotaHeaderPrefix))] = n
+	}
+	return res
+}
+
+// clone returns a copy of meta that shares none of its values, since a
+// response coalesced between callers is shared.
+func (m ResponseMeta) clone() ResponseMeta {
+	m.Header = m.Header.Clone()
+	m.Body = append([]byte(nil), m.Body...)
+	m.Warnings = append([]Warning(nil), m.Warnings...)
+	return m
+}
+
+// meta returns the ResponseMeta of a response.
+func (r *response) meta() ResponseMeta {
+	meta := ResponseMeta{
+		StatusCode: r.status,
+		Header:     r.header,
+		Body:       r.body,
+		Latency:    r.latency,
+	}
+	for _, h := range requestIDHeaders {
+		if id := r.header.Get(h); id != "" {
+			meta.RequestID = id
+			break
+		}
+	}
+	if len(r.body) > 0 && r.body[0] == '{' {
+		var envelope struct {
+			Warnings []Warning `json:"warnings"`
+		}
+		if json.Unmarshal(r.body, &envelope) == nil {
+			meta.Warnings = envelope.Warnings
+		}
+	}
+	return meta
+}
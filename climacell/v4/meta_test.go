@@ -0,0 +1,148 @@
+package climacell
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var warnedTimelines = `{"data": {"timelines": [{"timestep": "1h", "intervals": [
+	{"startTime": "2020-12-21T06:00:00Z", "endTime": "2020-12-21T07:00:00Z", "values": {"temperature": 15.1}}
+]}]}, "warnings": [{"code": 246009, "type": "Missing Time Range", "message": "The timestep is not supported", "meta": {"timestep": "1h"}}]}`
+
+func TestResponseMeta(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-1")
+		w.Header().Set("X-RateLimit-Remaining-Day", "99")
+		if r.URL.Path == "/locations" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code": 401001, "type": "Invalid Auth", "message": "The method requires authentication"}`))
+			return
+		}
+		w.Write([]byte(warnedTimelines))
+	}))
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+
+	var meta ResponseMeta
+	list, err := client.GetTimelines(WithResponseMeta(context.Background(), &meta), &TimelineListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, meta.StatusCode)
+	assert.Equal(t, "req-1", meta.RequestID)
+	assert.Equal(t, "99", meta.Header.Get("X-RateLimit-Remaining-Day"))
+	assert.Equal(t, warnedTimelines, string(meta.Body))
+	assert.Positive(t, meta.Latency)
+	require.Len(t, meta.Warnings, 1)
+	assert.Equal(t, "Missing Time Range", meta.Warnings[0].Type)
+	assert.JSONEq(t, `{"timestep": "1h"}`, string(meta.Warnings[0].Meta))
+
+	// the interval's unmodelled fields are kept
+	iv := list.Timelines[0].Intervals[0]
+	assert.Equal(t, map[string]json.RawMessage{"endTime": json.RawMessage(`"2020-12-21T07:00:00Z"`)}, iv.Extra)
+
+	// error responses fill in the metadata too
+	meta = ResponseMeta{}
+	_, err = client.ListLocations(WithResponseMeta(context.Background(), &meta))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, meta.StatusCode)
+	assert.Contains(t, string(meta.Body), "Invalid Auth")
+
+	// calls without a ResponseMeta are unaffected
+	_, err = client.GetTimelines(context.Background(), &TimelineListOptions{})
+	assert.NoError(t, err)
+}
+
+func TestResponseMetaMerged(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "merged")
+		w.Write([]byte(warnedTimelines))
+	}))
+	defer server.Close()
+
+	client := NewClient("test_api_key")
+	client.BaseURL = server.URL
+	m := NewMerger(client)
+
+	metas := make([]ResponseMeta, 2)
+	var wg sync.WaitGroup
+	for i, field := range []string{"temperature", "humidity"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := WithResponseMeta(context.Background(), &metas[i])
+			_, err := m.GetTimelines(ctx, &TimelineListOptions{Fields: []string{field}})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	for _, meta := range metas {
+		assert.Equal(t, "merged", meta.RequestID)
+		assert.Len(t, meta.Warnings, 1)
+	}
+	// each caller has its own copy
+	metas[0].Body[0] = 'x'
+	assert.Equal(t, byte('{'), metas[1].Body[0])
+}
+
+func TestResponseMetaRemaining(t *testing.T) {
+	meta := ResponseMeta{Header: http.Header{}}
+	assert.Nil(t, meta.Remaining())
+
+	meta.Header.Set("X-RateLimit-Remaining-Hour", "24")
+	meta.Header.Set("X-RateLimit-Remaining-Day", "499")
+	meta.Header.Set("X-RateLimit-Remaining-Month", "unknown")
+	meta.Header.Set("X-RateLimit-Limit-Day", "500")
+	assert.Equal(t, map[string]int{"hour": 24, "day": 499}, meta.Remaining())
+}
+
+func TestResponseMetaV3(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Correlation-Id", "v3-req")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	client := New("test_api_key")
+	client.baseURL = server.URL
+
+	var meta ResponseMeta
+	_, err := client.NowcastContext(WithResponseMeta(context.Background(), &meta), ForecastArgs{})
+	require.NoError(t, err)
+	assert.Equal(t, "v3-req", meta.RequestID)
+	assert.Equal(t, "[]", string(meta.Body))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.RealTimeContext(ctx, ForecastArgs{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestIntervalExtra(t *testing.T) {
+	body := []byte(`{"startTime": "2020-12-21T06:00:00Z", "values": {"temperature": 15.1}, "quality": {"score": 0.9}}`)
+
+	for _, mode := range []DecodeMode{DecodeDefault, DecodeStrict, DecodeLenient} {
+		var iv Interval
+		require.NoError(t, mode.Unmarshal(body, &iv))
+		assert.JSONEq(t, `{"score": 0.9}`, string(iv.Extra["quality"]))
+		temp, _ := iv.Values.Float("temperature")
+		assert.Equal(t, 15.1, temp)
+	}
+
+	var iv Interval
+	require.NoError(t, json.Unmarshal(body, &iv))
+	b, err := json.Marshal(iv)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(body), string(b))
+
+	iv = Interval{}
+	require.NoError(t, json.Unmarshal([]byte(`{"startTime": "2020-12-21T06:00:00Z"}`), &iv))
+	assert.Nil(t, iv.Extra)
+}
@@ -0,0 +1,209 @@
+// Package notify delivers rule alerts and watcher events to people and
+// services.
+//
+// A Notifier renders a Message from each rules.Alert or watch.Event it is
+// given, using a Template, and sends it to every configured Sink: a JSON
+// webhook signed with HMAC-SHA256, a Slack-compatible incoming webhook, or
+// email over SMTP. Failed deliveries are retried with exponential backoff,
+// and messages that still can't be delivered are appended to a dead-letter
+// file as newline-delimited JSON so they can be inspected or replayed.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/maskarb/climacell-go/climacell/v4/rules"
+	"github.com/maskarb/climacell-go/climacell/v4/watch"
+)
+
+// Defaults for a Notifier's retries.
+const (
+	DefaultRetries = 3
+	DefaultBackoff = time.Second
+)
+
+// Message is a rendered notification.
+type Message struct {
+	// Subject is a one line summary.
+	Subject string `json:"subject"`
+	// Body is the full text of the message.
+	Body string `json:"body"`
+	// Labels are the labels of the rule that fired, if any.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Time is when the message was created.
+	Time time.Time `json:"time"`
+	// Data is the value the message is about, like a rules.Alert.
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Sink delivers messages to a destination.
+type Sink interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Notifier renders messages and sends them to its sinks.
+type Notifier struct {
+	// Sinks are the destinations every message is sent to.
+	Sinks []Sink
+	// AlertTemplate and EventTemplate render messages about rules.Alerts
+	// and watch.Events. They default to templates parsed from
+	// DefaultAlertSubject and DefaultAlertBody, and DefaultEventSubject
+	// and DefaultEventBody.
+	AlertTemplate *Template
+	EventTemplate *Template
+	// Units is the unit system values are in, "metric" or "imperial",
+	// used by the default templates.
+	Units string
+	// Retries is the number of times a failed delivery is retried. It
+	// defaults to DefaultRetries; set it to a negative number to disable
+	// retries.
+	Retries int
+	// Backoff is the time before the first retry, doubling for each
+	// retry after that. It defaults to DefaultBackoff.
+	Backoff time.Duration
+	// DeadLetter, if set, is the path of the file messages that couldn't
+	// be delivered to a sink are appended to.
+	DeadLetter string
+
+	mu sync.Mutex // serializes writes to DeadLetter
+}
+
+// Notify renders a message about v, which is a rules.Alert, a watch.Event or
+// a Message, and sends it to every sink.
+func (n *Notifier) Notify(ctx context.Context, v interface{}) error {
+	m, err := n.render(v)
+	if err != nil {
+		return err
+	}
+	return n.Send(ctx, m)
+}
+
+func (n *Notifier) render(v interface{}) (Message, error) {
+	var (
+		t   *Template
+		err error
+	)
+	switch v := v.(type) {
+	case Message:
+		return v, nil
+	case *rules.Alert:
+		return n.render(*v)
+	case rules.Alert:
+		if t = n.AlertTemplate; t == nil {
+			t, err = ParseTemplate(DefaultAlertSubject, DefaultAlertBody, n.Units)
+		}
+	case watch.Event:
+		if t = n.EventTemplate; t == nil {
+			t, err = ParseTemplate(DefaultEventSubject, DefaultEventBody, n.Units)
+		}
+	default:
+		return Message{}, fmt.Errorf("can't notify about %T", v)
+	}
+	if err != nil {
+		return Message{}, err
+	}
+
+	m, err := t.Render(v)
+	if err != nil {
+		return Message{}, err
+	}
+	if a, ok := v.(rules.Alert); ok {
+		m.Labels = a.Labels
+	}
+	m.Time = time.Now()
+	m.Data = v
+	return m, nil
+}
+
+// Send sends a message to every sink, retrying failed deliveries. A message
+// that can't be delivered to a sink is written to the dead-letter file, and
+// an error is returned describing every failed sink.
+func (n *Notifier) Send(ctx context.Context, m Message) error {
+	var failed []string
+	for _, sink := range n.Sinks {
+		err := n.sendWithRetries(ctx, sink, m)
+		if err == nil {
+			continue
+		}
+		failed = append(failed, fmt.Sprintf("%T: %v", sink, err))
+		if dlErr := n.deadLetter(sink, m, err); dlErr != nil {
+			failed = append(failed, dlErr.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("delivering %q: %s", m.Subject, strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (n *Notifier) sendWithRetries(ctx context.Context, sink Sink, m Message) error {
+	retries := n.Retries
+	if retries == 0 {
+		retries = DefaultRetries
+	}
+	backoff := n.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = sink.Send(ctx, m); err == nil || attempt >= retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// DeadLetter is a line of the dead-letter file.
+type DeadLetter struct {
+	// Sink is the Go type of the sink the message couldn't be delivered
+	// to, like "*notify.EmailSink".
+	Sink string `json:"sink"`
+	// Error is the error of the last delivery attempt.
+	Error string `json:"error"`
+	// Failed is when the last delivery attempt failed.
+	Failed time.Time `json:"failed"`
+	// Message is the undelivered message.
+	Message Message `json:"message"`
+}
+
+func (n *Notifier) deadLetter(sink Sink, m Message, sendErr error) error {
+	if n.DeadLetter == "" {
+		return nil
+	}
+	b, err := json.Marshal(DeadLetter{
+		Sink:    fmt.Sprintf("%T", sink),
+		Error:   sendErr.Error(),
+		Failed:  time.Now(),
+		Message: m,
+	})
+	if err != nil {
+		return errors.WithMessage(err, "encoding dead letter")
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	f, err := os.OpenFile(n.DeadLetter, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return errors.WithMessage(err, "opening dead-letter file")
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return errors.WithMessage(err, "writing dead-letter file")
+	}
+	return f.Close()
+}
@@ -0,0 +1,243 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+	"github.com/maskarb/climacell-go/climacell/v4/rules"
+	"github.com/maskarb/climacell-go/climacell/v4/watch"
+	"github.com/maskarb/climacell-go/climacell/v4/webhook"
+)
+
+var start = time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC)
+
+func testAlert() rules.Alert {
+	return rules.Alert{
+		Rule:      "high-wind",
+		Condition: "windGust > 20 m/s for 1h0m0s",
+		Labels:    map[string]string{"severity": "warning"},
+		Location:  "home",
+		Status:    rules.Firing,
+		Start:     start,
+		End:       start.Add(time.Hour),
+		Intervals: []climacell.Interval{
+			{StartTime: start, Values: climacell.Values{"windGust": 22.5, "weatherCode": float64(4001)}},
+		},
+	}
+}
+
+func TestRenderAlert(t *testing.T) {
+	n := &Notifier{}
+	m, err := n.render(testAlert())
+	require.NoError(t, err)
+	assert.Equal(t, "[FIRING] high-wind at home", m.Subject)
+	assert.Equal(t, `high-wind is firing at home: windGust > 20 m/s for 1h0m0s
+From 2020-12-21T06:00:00Z to 2020-12-21T07:00:00Z
+  2020-12-21T06:00:00Z  weatherCode=Rain windGust=22.5 m/s
+`, m.Body)
+	assert.Equal(t, "warning", m.Labels["severity"])
+
+	n.Units = "imperial"
+	n.AlertTemplate, err = ParseTemplate(`{{.Rule}}`, `gusts {{value "windGust" (index .Intervals 0).Values}} ({{units "windGust"}}), {{label "weatherCode" 1000}}`, "imperial")
+	require.NoError(t, err)
+	m, err = n.render(testAlert())
+	require.NoError(t, err)
+	assert.Equal(t, "gusts 22.5 mph (mph), Clear", m.Body)
+
+	_, err = n.render(42)
+	assert.Error(t, err)
+}
+
+type fakeEvent struct{}
+
+func (fakeEvent) Detected() time.Time { return start }
+func (fakeEvent) String() string      { return "precipitation expected" }
+
+var _ watch.Event = fakeEvent{}
+
+func TestRenderEvent(t *testing.T) {
+	m, err := (&Notifier{}).render(fakeEvent{})
+	require.NoError(t, err)
+	assert.Equal(t, "precipitation expected", m.Subject)
+	assert.Equal(t, "precipitation expected\nDetected at 2020-12-21T06:00:00Z\n", m.Body)
+}
+
+func TestWebhookSink(t *testing.T) {
+	var (
+		got       Message
+		signature string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signature = r.Header.Get(webhook.SignatureHeader)
+		assert.True(t, webhook.Verify([]byte("secret"), body, signature))
+		require.NoError(t, json.Unmarshal(body, &got))
+	}))
+	defer server.Close()
+
+	n := &Notifier{Sinks: []Sink{&WebhookSink{URL: server.URL, Secret: "secret"}}}
+	require.NoError(t, n.Notify(context.Background(), testAlert()))
+	assert.Equal(t, "[FIRING] high-wind at home", got.Subject)
+	assert.Equal(t, "warning", got.Labels["severity"])
+	assert.Contains(t, signature, "sha256=")
+	assert.False(t, webhook.Verify([]byte("other"), []byte("{}"), signature))
+}
+
+func TestSlackSink(t *testing.T) {
+	var payload map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+	}))
+	defer server.Close()
+
+	n := &Notifier{Sinks: []Sink{&SlackSink{URL: server.URL, Channel: "#weather"}}}
+	require.NoError(t, n.Send(context.Background(), Message{Subject: "wind > 20", Body: "gusts\n"}))
+	assert.Equal(t, "#weather", payload["channel"])
+	assert.Equal(t, "*wind &gt; 20*\ngusts", payload["text"])
+}
+
+// smtpServer is a minimal SMTP server that records the messages it
+// receives.
+type smtpServer struct {
+	l        net.Listener
+	mu       sync.Mutex
+	messages []string
+	rcpts    []string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &smtpServer{l: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 localhost ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, strings.TrimSpace(line[len("RCPT TO:"):]))
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestEmailSink(t *testing.T) {
+	s := newSMTPServer(t)
+	n := &Notifier{Sinks: []Sink{&EmailSink{
+		Addr: s.l.Addr().String(),
+		From: "weather@example.com",
+		To:   []string{"ops@example.com", "oncall@example.com"},
+	}}}
+	require.NoError(t, n.Notify(context.Background(), testAlert()))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.Equal(t, []string{"<ops@example.com>", "<oncall@example.com>"}, s.rcpts)
+	require.Len(t, s.messages, 1)
+	assert.Contains(t, s.messages[0], "Subject: [FIRING] high-wind at home\r\n")
+	assert.Contains(t, s.messages[0], "To: ops@example.com, oncall@example.com\r\n")
+	assert.Contains(t, s.messages[0], "\r\n\r\nhigh-wind is firing at home")
+}
+
+func TestRetryAndDeadLetter(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		n := calls[r.URL.Path]
+		mu.Unlock()
+		// /flaky succeeds on the third attempt, /down never does
+		if r.URL.Path == "/down" || n < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "dead.ndjson")
+	n := &Notifier{
+		Sinks: []Sink{
+			&WebhookSink{URL: server.URL + "/flaky"},
+			&WebhookSink{URL: server.URL + "/down"},
+		},
+		Retries:    2,
+		Backoff:    time.Millisecond,
+		DeadLetter: deadLetter,
+	}
+	err := n.Notify(context.Background(), testAlert())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 503")
+	assert.Equal(t, 3, calls["/flaky"])
+	assert.Equal(t, 3, calls["/down"])
+
+	b, err := os.ReadFile(deadLetter)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Len(t, lines, 1)
+	var dl DeadLetter
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &dl))
+	assert.Equal(t, "*notify.WebhookSink", dl.Sink)
+	assert.Contains(t, dl.Error, "unavailable")
+	assert.Equal(t, "[FIRING] high-wind at home", dl.Message.Subject)
+}
@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/maskarb/climacell-go/climacell/v4/internal/httppost"
+	"github.com/maskarb/climacell-go/climacell/v4/webhook"
+)
+
+// WebhookSink posts messages as JSON to a URL.
+type WebhookSink struct {
+	// URL is the webhook's URL.
+	URL string
+	// Secret, if set, is used to sign the request body, with the signature
+	// sent in the webhook.SignatureHeader header, so that the messages can
+	// be received with a webhook.Handler.
+	Secret string
+	// HTTPClient, if set, replaces the default client, which gives up on a
+	// message after a minute.
+	HTTPClient *http.Client
+}
+
+// Send implements Sink.
+func (s *WebhookSink) Send(ctx context.Context, m Message) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return errors.WithMessage(err, "encoding message")
+	}
+	header := http.Header{"Content-Type": {"application/json"}}
+	if s.Secret != "" {
+		header.Set(webhook.SignatureHeader, webhook.Sign([]byte(s.Secret), body))
+	}
+	return httppost.Post(ctx, s.HTTPClient, s.URL, header, body)
+}
+
+// SlackSink posts messages to a Slack incoming webhook, or any service that
+// accepts Slack-compatible payloads, like Mattermost.
+type SlackSink struct {
+	// URL is the incoming webhook's URL.
+	URL string
+	// Channel, if set, overrides the webhook's default channel.
+	Channel string
+	// HTTPClient, if set, replaces the default client, which gives up on a
+	// message after a minute.
+	HTTPClient *http.Client
+}
+
+// Send implements Sink.
+func (s *SlackSink) Send(ctx context.Context, m Message) error {
+	body, err := json.Marshal(struct {
+		Channel string `json:"channel,omitempty"`
+		Text    string `json:"text"`
+	}{
+		Channel: s.Channel,
+		Text:    "*" + slackEscape(m.Subject) + "*\n" + slackEscape(strings.TrimRight(m.Body, "\n")),
+	})
+	if err != nil {
+		return errors.WithMessage(err, "encoding message")
+	}
+	return httppost.Post(ctx, s.HTTPClient, s.URL, http.Header{"Content-Type": {"application/json"}}, body)
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackEscape escapes the characters Slack uses for its markup.
+func slackEscape(s string) string { return slackEscaper.Replace(s) }
+
+// EmailSink sends messages as plain text email over SMTP.
+type EmailSink struct {
+	// Addr is the address of the SMTP server, like "smtp.example.com:587".
+	Addr string
+	// Auth, if set, is used to authenticate with the server, for example
+	// smtp.PlainAuth. STARTTLS is used when the server supports it.
+	Auth smtp.Auth
+	// From is the sender's address.
+	From string
+	// To are the recipients' addresses.
+	To []string
+}
+
+// Send implements Sink. The context is not used, since net/smtp doesn't
+// support cancellation.
+func (s *EmailSink) Send(_ context.Context, m Message) error {
+	if len(s.To) == 0 {
+		return errors.New("email sink has no recipients")
+	}
+	date := m.Time
+	if date.IsZero() {
+		date = time.Now()
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	if err := smtp.SendMail(s.Addr, s.Auth, s.From, s.To, b.Bytes()); err != nil {
+		return errors.WithMessage(err, "sending email")
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// Default templates for rules.Alert notifications.
+const (
+	DefaultAlertSubject = `[{{upper .Status}}] {{.Rule}} at {{.Location}}`
+	DefaultAlertBody    = `{{.Rule}} is {{.Status}} at {{.Location}}: {{.Condition}}
+From {{time .Start}} to {{time .End}}
+{{range .Intervals}}  {{time .StartTime}}  {{values .Values}}
+{{end}}`
+)
+
+// Default templates for watch.Event notifications.
+const (
+	DefaultEventSubject = `{{.String}}`
+	DefaultEventBody    = `{{.String}}
+Detected at {{time .Detected}}
+`
+)
+
+// Template renders the subject and body of a message from the value being
+// notified about, like a rules.Alert or a watch.Event, using text/template.
+//
+// Besides the standard functions, templates can use:
+//
+//	value field values  the field's value from values, with its units, or its
+//	                    label for an enum field, like "21.5 m/s" or "Rain"
+//	values values       every value in values, sorted by field and formatted
+//	                    as field=value
+//	units field         the field's units
+//	label field code    the label of an enum field's code
+//	time t              t formatted as RFC 3339
+//	upper s             s in upper case
+type Template struct {
+	subject, body *template.Template
+}
+
+// ParseTemplate parses the subject and body templates of a message. Values
+// are formatted with the units of the given unit system, "metric" or
+// "imperial".
+func ParseTemplate(subject, body, system string) (*Template, error) {
+	funcs := templateFuncs(system)
+	s, err := template.New("subject").Funcs(funcs).Parse(subject)
+	if err != nil {
+		return nil, errors.WithMessage(err, "parsing subject template")
+	}
+	b, err := template.New("body").Funcs(funcs).Parse(body)
+	if err != nil {
+		return nil, errors.WithMessage(err, "parsing body template")
+	}
+	return &Template{subject: s, body: b}, nil
+}
+
+// Render renders a message about v.
+func (t *Template) Render(v interface{}) (Message, error) {
+	var subject, body bytes.Buffer
+	if err := t.subject.Execute(&subject, v); err != nil {
+		return Message{}, errors.WithMessage(err, "rendering subject")
+	}
+	if err := t.body.Execute(&body, v); err != nil {
+		return Message{}, errors.WithMessage(err, "rendering body")
+	}
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    body.String(),
+	}, nil
+}
+
+func templateFuncs(system string) template.FuncMap {
+	units := func(field string) string {
+		f, ok := climacell.LookupField(field)
+		if !ok {
+			return ""
+		}
+		return f.UnitsIn(system)
+	}
+	value := func(field string, v climacell.Values) string {
+		return formatValue(field, v, units(field))
+	}
+	return template.FuncMap{
+		"value": value,
+		"values": func(v climacell.Values) string {
+			fields := make([]string, 0, len(v))
+			for field := range v {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			parts := make([]string, len(fields))
+			for i, field := range fields {
+				parts[i] = field + "=" + value(field, v)
+			}
+			return strings.Join(parts, " ")
+		},
+		"units": units,
+		"label": func(field string, code int) string {
+			f, _ := climacell.LookupField(field)
+			if label, ok := f.Label(code); ok {
+				return label
+			}
+			return strconv.Itoa(code)
+		},
+		"time":  func(t time.Time) string { return t.Format(time.RFC3339) },
+		"upper": func(s interface{}) string { return strings.ToUpper(fmt.Sprint(s)) },
+	}
+}
+
+func formatValue(field string, v climacell.Values, units string) string {
+	f, _ := climacell.LookupField(field)
+	if f.Kind == climacell.FieldEnum {
+		if code, ok := v.Int(field); ok {
+			if label, ok := f.Label(code); ok {
+				return label
+			}
+		}
+	}
+	if n, ok := v.Float(field); ok {
+		s := strconv.FormatFloat(n, 'f', -1, 64)
+		switch units {
+		case "":
+			return s
+		case "%":
+			return s + "%"
+		default:
+			return s + " " + units
+		}
+	}
+	s, _ := v.String(field)
+	return s
+}
@@ -0,0 +1,88 @@
+package climacell
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits how often requests are sent to the ClimaCell API, so
+// that a client stays within the request limits of its API plan instead of
+// having requests rejected with a 429 error. It allows bursts of up to the
+// number of requests in a period, refilling one request at a time.
+//
+// A RateLimiter is safe for concurrent use, and can be shared between
+// clients that use the same API key.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    float64
+	tokens   float64
+	last     time.Time
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing the given number of requests
+// per period, for example NewRateLimiter(25, time.Hour).
+func NewRateLimiter(requests int, per time.Duration) *RateLimiter {
+	if requests < 1 {
+		requests = 1
+	}
+	return &RateLimiter{
+		interval: per / time.Duration(requests),
+		burst:    float64(requests),
+		tokens:   float64(requests),
+		now:      time.Now,
+	}
+}
+
+// Interval returns the average time between requests allowed by this
+// RateLimiter.
+func (l *RateLimiter) Interval() time.Duration { return l.interval }
+
+// Wait blocks until a request is allowed, or returns the context's error if
+// it is done first. A nil RateLimiter allows every request immediately.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	for {
+		delay := l.reserve()
+		if delay == 0 {
+			return nil
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// reserve takes a request from the limiter if one is available, returning 0,
+// or else returns how long to wait until one is.
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if !l.last.IsZero() && l.interval > 0 {
+		l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+
+	if l.tokens >= 1 || l.interval <= 0 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) * float64(l.interval))
+}
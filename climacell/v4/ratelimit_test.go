@@ -0,0 +1,38 @@
+package climacell
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	now := time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(2, time.Hour)
+	l.now = func() time.Time { return now }
+
+	// the first two requests are a burst
+	assert.Zero(t, l.reserve())
+	assert.Zero(t, l.reserve())
+	assert.Equal(t, 30*time.Minute, l.reserve())
+
+	now = now.Add(15 * time.Minute)
+	assert.Equal(t, 15*time.Minute, l.reserve())
+
+	now = now.Add(15 * time.Minute)
+	assert.Zero(t, l.reserve())
+}
+
+func TestRateLimiterWaitCanceled(t *testing.T) {
+	l := NewRateLimiter(1, time.Hour)
+	assert.NoError(t, l.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, l.Wait(ctx))
+
+	var unlimited *RateLimiter
+	assert.NoError(t, unlimited.Wait(context.Background()))
+}
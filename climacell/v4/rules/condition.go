@@ -0,0 +1,272 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// Comparison operators a Condition can use.
+var operators = []string{">=", "<=", "==", "!=", ">", "<"}
+
+// Condition is a parsed rule expression, like
+//
+//	windGust > 20 m/s for 2h within next 24h
+//
+// which holds when a field's value compares to a threshold, optionally for
+// some minimum duration and within some time from now.
+type Condition struct {
+	// Field is the compared field, which is a numeric or enum field in the
+	// field registry.
+	Field string
+	// Op is the comparison operator: >, >=, <, <=, == or !=.
+	Op string
+	// Value is the threshold, in Units.
+	Value float64
+	// Units are the units of Value. They default to the field's metric
+	// units, and are empty for enum and unitless fields.
+	Units string
+	// For, if nonzero, is how long the comparison must hold for across
+	// consecutive intervals.
+	For time.Duration
+	// Within, if nonzero, limits the condition to intervals starting less
+	// than this long after the evaluation time.
+	Within time.Duration
+
+	field climacell.Field
+	label string
+}
+
+// ParseCondition parses and type checks a rule expression. An expression is a
+// field name, a comparison operator and a threshold, followed by optional
+// units, an optional "for <duration>" and an optional "within [next]
+// <duration>". The thresholds of enum fields can be given as a quoted label
+// instead of a code, as in:
+//
+//	weatherCode == "Heavy Rain"
+//
+// Durations are Go durations, like "90m" or "2h", or a number of days like
+// "1d".
+func ParseCondition(expr string) (Condition, error) {
+	c, err := parseCondition(expr)
+	if err != nil {
+		return Condition{}, fmt.Errorf("parsing %q: %v", expr, err)
+	}
+	return c, nil
+}
+
+func parseCondition(expr string) (Condition, error) {
+	var c Condition
+	s := strings.TrimSpace(expr)
+
+	end := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	if end < 0 {
+		end = len(s)
+	}
+	if end == 0 {
+		return c, fmt.Errorf("expected a field name")
+	}
+	c.Field, s = s[:end], strings.TrimSpace(s[end:])
+
+	f, ok := climacell.LookupField(c.Field)
+	switch {
+	case !ok:
+		return c, fmt.Errorf("unknown field %q", c.Field)
+	case f.Kind == climacell.FieldTime:
+		return c, fmt.Errorf("field %s is a time and can't be compared", c.Field)
+	}
+	c.field = f
+
+	for _, op := range operators {
+		if strings.HasPrefix(s, op) {
+			c.Op, s = op, strings.TrimSpace(s[len(op):])
+			break
+		}
+	}
+	if c.Op == "" {
+		return c, fmt.Errorf("expected a comparison operator after %s", c.Field)
+	}
+
+	words, err := splitWords(s)
+	if err != nil {
+		return c, err
+	}
+	if len(words) == 0 {
+		return c, fmt.Errorf("expected a value after %s", c.Op)
+	}
+
+	// The threshold is a number, a number immediately followed by its
+	// units, or a quoted enum label.
+	value := words[0]
+	words = words[1:]
+	if label, ok := unquote(value); ok {
+		if f.Kind != climacell.FieldEnum {
+			return c, fmt.Errorf("field %s has no labels", c.Field)
+		}
+		code, ok := codeForLabel(f, label)
+		if !ok {
+			return c, fmt.Errorf("field %s has no label %q", c.Field, label)
+		}
+		c.Value, c.label = float64(code), label
+	} else {
+		numEnd := strings.IndexFunc(value, func(r rune) bool {
+			return !unicode.IsDigit(r) && !strings.ContainsRune(".-+eE", r)
+		})
+		if numEnd > 0 {
+			words = append([]string{value[numEnd:]}, words...)
+			value = value[:numEnd]
+		}
+		if c.Value, err = strconv.ParseFloat(value, 64); err != nil {
+			return c, fmt.Errorf("invalid value %q", value)
+		}
+	}
+
+	c.Units = f.Units
+	if len(words) > 0 && words[0] != "for" && words[0] != "within" {
+		if f.Units == "" {
+			return c, fmt.Errorf("field %s has no units", c.Field)
+		}
+		if _, err := climacell.ConvertUnits(0, words[0], f.Units); err != nil {
+			return c, fmt.Errorf("field %s: %v", c.Field, err)
+		}
+		c.Units = words[0]
+		words = words[1:]
+	}
+
+	for len(words) > 0 {
+		keyword := words[0]
+		words = words[1:]
+		if keyword == "within" && len(words) > 0 && words[0] == "next" {
+			words = words[1:]
+		}
+		if keyword != "for" && keyword != "within" {
+			return c, fmt.Errorf("unexpected %q", keyword)
+		}
+		if len(words) == 0 {
+			return c, fmt.Errorf("expected a duration after %q", keyword)
+		}
+		d, err := parseDuration(words[0])
+		if err != nil {
+			return c, err
+		}
+		words = words[1:]
+		if keyword == "for" {
+			c.For = d
+		} else {
+			c.Within = d
+		}
+	}
+	return c, nil
+}
+
+// splitWords splits s at spaces, keeping quoted strings together.
+func splitWords(s string) ([]string, error) {
+	var words []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] == '"' {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string %s", s)
+			}
+			words = append(words, s[:end+2])
+			s = s[end+2:]
+			continue
+		}
+		end := strings.IndexFunc(s, unicode.IsSpace)
+		if end < 0 {
+			end = len(s)
+		}
+		words = append(words, s[:end])
+		s = s[end:]
+	}
+	return words, nil
+}
+
+func unquote(s string) (string, bool) {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1], true
+	}
+	return "", false
+}
+
+func codeForLabel(f climacell.Field, label string) (int, bool) {
+	for code, l := range f.Labels {
+		if strings.EqualFold(l, label) {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64)
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// String returns the condition as an expression that parses back to it.
+func (c Condition) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s ", c.Field, c.Op)
+	if c.label != "" {
+		b.WriteString(strconv.Quote(c.label))
+	} else {
+		b.WriteString(strconv.FormatFloat(c.Value, 'g', -1, 64))
+	}
+	if c.Units != "" {
+		b.WriteString(" " + c.Units)
+	}
+	if c.For > 0 {
+		b.WriteString(" for " + c.For.String())
+	}
+	if c.Within > 0 {
+		b.WriteString(" within next " + c.Within.String())
+	}
+	return b.String()
+}
+
+// Holds returns whether the comparison holds for an interval's values, which
+// are in the given unit system, "metric" or "imperial". It returns false if
+// the field is absent.
+func (c Condition) Holds(v climacell.Values, system string) bool {
+	value, ok := v.Float(c.Field)
+	if !ok {
+		return false
+	}
+	threshold := c.Value
+	if units := c.field.UnitsIn(system); c.Units != units {
+		var err error
+		if threshold, err = climacell.ConvertUnits(c.Value, c.Units, units); err != nil {
+			return false
+		}
+	}
+
+	switch c.Op {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	case "==":
+		return value == threshold
+	case "!=":
+		return value != threshold
+	}
+	return false
+}
@@ -0,0 +1,297 @@
+// Package rules evaluates alert rules against ClimaCell timelines locally,
+// without the Insights or Alerts APIs.
+//
+// A rule is a named condition over a single field, like
+//
+//	windGust > 20 m/s for 2h within next 24h
+//
+// Conditions are type checked against the field registry when they are
+// parsed, and thresholds are converted to the units the timelines were
+// requested in when they are evaluated. An Engine evaluates a set of rules
+// every time new timelines are fetched for a location and reports alerts as
+// they start firing and when they resolve.
+package rules
+
+import (
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// Rule is a named condition.
+type Rule struct {
+	// Name identifies the rule in its alerts.
+	Name string
+	// Condition is the rule's parsed expression.
+	Condition Condition
+	// Timestep, if set, limits the rule to timelines with this timestep.
+	Timestep string
+	// Labels are copied to the rule's alerts, for example to set a
+	// severity for the notifier.
+	Labels map[string]string
+}
+
+// NewRule parses expr and returns a rule with the given name.
+func NewRule(name, expr string) (*Rule, error) {
+	c, err := ParseCondition(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Rule{Name: name, Condition: c}, nil
+}
+
+// Match is a run of consecutive intervals for which a rule's condition
+// holds.
+type Match struct {
+	// Start is the start of the first interval, and End the end of the
+	// last.
+	Start, End time.Time
+	// Intervals are the matching intervals.
+	Intervals []climacell.Interval
+}
+
+// Evaluate returns the matches of the rule in a timeline whose values are in
+// the given unit system, "metric" or "imperial". If the condition has a For
+// duration, only runs lasting at least that long match. If it has a Within
+// duration, only intervals starting before now plus Within are considered.
+func (r *Rule) Evaluate(t climacell.Timeline, system string, now time.Time) []Match {
+	if r.Timestep != "" && t.Timestep != r.Timestep {
+		return nil
+	}
+	c := r.Condition
+	var (
+		matches []Match
+		run     *Match
+	)
+	finish := func() {
+		if run != nil && run.End.Sub(run.Start) >= c.For {
+			matches = append(matches, *run)
+		}
+		run = nil
+	}
+
+	for i, iv := range t.Intervals {
+		if c.Within > 0 && !iv.StartTime.Before(now.Add(c.Within)) {
+			break
+		}
+		if !c.Holds(iv.Values, system) {
+			finish()
+			continue
+		}
+		end := intervalEnd(t, i)
+		if run == nil {
+			run = &Match{Start: iv.StartTime}
+		}
+		run.End = end
+		run.Intervals = append(run.Intervals, iv)
+	}
+	finish()
+	return matches
+}
+
+// intervalEnd returns the end of the i'th interval of a timeline, which is
+// the start of the next interval, or the start plus the timestep for the last
+// interval.
+func intervalEnd(t climacell.Timeline, i int) time.Time {
+	if i+1 < len(t.Intervals) {
+		return t.Intervals[i+1].StartTime
+	}
+	start := t.Intervals[i].StartTime
+	if step := timestepDuration(t.Timestep); step > 0 {
+		return start.Add(step)
+	}
+	if i > 0 {
+		return start.Add(start.Sub(t.Intervals[i-1].StartTime))
+	}
+	return start
+}
+
+func timestepDuration(timestep string) time.Duration {
+	if timestep == "current" {
+		return 0
+	}
+	d, err := parseDuration(timestep)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// ruleFile is the format of a rules file.
+type ruleFile struct {
+	Rules []struct {
+		Name     string            `yaml:"name"`
+		Expr     string            `yaml:"expr"`
+		Timestep string            `yaml:"timestep"`
+		Labels   map[string]string `yaml:"labels"`
+	} `yaml:"rules"`
+}
+
+// Load reads rules in YAML or JSON from r, in the format
+//
+//	rules:
+//	  - name: high-wind
+//	    expr: windGust > 20 m/s for 2h within next 24h
+//	    timestep: 1h
+//	    labels: {severity: warning}
+//
+// Every rule must have a unique name and a valid expression.
+func Load(r io.Reader) ([]*Rule, error) {
+	var f ruleFile
+	if err := yaml.NewDecoder(r).Decode(&f); err != nil && err != io.EOF {
+		return nil, errors.WithMessage(err, "decoding rules")
+	}
+
+	rules := make([]*Rule, 0, len(f.Rules))
+	names := map[string]bool{}
+	for i, fr := range f.Rules {
+		if fr.Name == "" {
+			return nil, errors.Errorf("rule %d has no name", i+1)
+		}
+		if names[fr.Name] {
+			return nil, errors.Errorf("duplicate rule name %q", fr.Name)
+		}
+		names[fr.Name] = true
+
+		rule, err := NewRule(fr.Name, fr.Expr)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "rule %s", fr.Name)
+		}
+		rule.Timestep = fr.Timestep
+		rule.Labels = fr.Labels
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// LoadFile reads rules from a YAML or JSON file.
+func LoadFile(path string) ([]*Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithMessage(err, "opening rules file")
+	}
+	defer f.Close()
+	rules, err := Load(f)
+	if err != nil {
+		return nil, errors.WithMessage(err, path)
+	}
+	return rules, nil
+}
+
+// Status is the state of an Alert.
+type Status string
+
+// Alert statuses.
+const (
+	Firing   Status = "firing"
+	Resolved Status = "resolved"
+)
+
+// Alert reports a rule starting or stopping to match at a location.
+type Alert struct {
+	// Rule is the name of the rule.
+	Rule string `json:"rule"`
+	// Condition is the rule's expression.
+	Condition string `json:"condition"`
+	// Labels are the rule's labels.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Location is the key of the location the rule matched at.
+	Location string `json:"location"`
+	// Status is Firing when the rule starts matching, and Resolved when
+	// it stops.
+	Status Status `json:"status"`
+	// Start and End are the times of the first match. For a resolved
+	// alert, they are the times of the last match while it was firing.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Intervals are the intervals of the first match.
+	Intervals []climacell.Interval `json:"intervals"`
+	// Evaluated is the evaluation time the alert started firing or
+	// resolved at.
+	Evaluated time.Time `json:"evaluated"`
+}
+
+type alertKey struct {
+	rule, location string
+}
+
+// Engine evaluates a set of rules against the timelines of any number of
+// locations and tracks which rules are firing at each location.
+type Engine struct {
+	// Units is the unit system the timelines are requested in, "metric" or
+	// "imperial".
+	Units string
+
+	rules  []*Rule
+	active map[alertKey]*Alert
+}
+
+// NewEngine returns an Engine evaluating rules.
+func NewEngine(rules []*Rule) *Engine {
+	return &Engine{rules: rules, active: map[alertKey]*Alert{}}
+}
+
+// Evaluate evaluates every rule against the timelines just fetched for a
+// location, and returns the alerts that started firing or resolved since
+// the location's last evaluation, sorted by rule name. A rule that matches
+// any of the timelines fires.
+func (e *Engine) Evaluate(location string, timelines []climacell.Timeline, now time.Time) []Alert {
+	var changed []Alert
+	for _, r := range e.rules {
+		key := alertKey{rule: r.Name, location: location}
+		var first *Match
+		for _, t := range timelines {
+			if matches := r.Evaluate(t, e.Units, now); len(matches) > 0 {
+				if first == nil || matches[0].Start.Before(first.Start) {
+					first = &matches[0]
+				}
+			}
+		}
+
+		active := e.active[key]
+		switch {
+		case first != nil && active == nil:
+			a := &Alert{
+				Rule:      r.Name,
+				Condition: r.Condition.String(),
+				Labels:    r.Labels,
+				Location:  location,
+				Status:    Firing,
+				Start:     first.Start,
+				End:       first.End,
+				Intervals: first.Intervals,
+				Evaluated: now,
+			}
+			e.active[key] = a
+			changed = append(changed, *a)
+		case first != nil:
+			active.Start, active.End, active.Intervals = first.Start, first.End, first.Intervals
+		case active != nil:
+			delete(e.active, key)
+			resolved := *active
+			resolved.Status = Resolved
+			resolved.Evaluated = now
+			changed = append(changed, resolved)
+		}
+	}
+	sort.SliceStable(changed, func(i, j int) bool { return changed[i].Rule < changed[j].Rule })
+	return changed
+}
+
+// Active returns the alerts firing at a location, sorted by rule name.
+func (e *Engine) Active(location string) []Alert {
+	var alerts []Alert
+	for key, a := range e.active {
+		if key.location == location {
+			alerts = append(alerts, *a)
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].Rule < alerts[j].Rule })
+	return alerts
+}
@@ -0,0 +1,160 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+var now = time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC)
+
+func TestParseCondition(t *testing.T) {
+	c, err := ParseCondition("windGust > 20 m/s for 2h within next 24h")
+	require.NoError(t, err)
+	assert.Equal(t, "windGust", c.Field)
+	assert.Equal(t, ">", c.Op)
+	assert.Equal(t, 20.0, c.Value)
+	assert.Equal(t, "m/s", c.Units)
+	assert.Equal(t, 2*time.Hour, c.For)
+	assert.Equal(t, 24*time.Hour, c.Within)
+
+	c, err = ParseCondition("epaIndex>=150")
+	require.NoError(t, err)
+	assert.Equal(t, ">=", c.Op)
+	assert.Equal(t, 150.0, c.Value)
+	assert.Equal(t, "", c.Units)
+
+	c, err = ParseCondition("temperature < 32F within 1d")
+	require.NoError(t, err)
+	assert.Equal(t, "F", c.Units)
+	assert.Equal(t, 24*time.Hour, c.Within)
+
+	c, err = ParseCondition(`weatherCode == "heavy rain"`)
+	require.NoError(t, err)
+	assert.Equal(t, 4201.0, c.Value)
+	assert.Equal(t, `weatherCode == "heavy rain"`, c.String())
+
+	c, err = ParseCondition("temperatureMax > 30")
+	require.NoError(t, err)
+	assert.Equal(t, "C", c.Units)
+	assert.Equal(t, "temperatureMax > 30 C", c.String())
+
+	for _, expr := range []string{
+		"",
+		"notAField > 1",
+		"sunriseTime > 1",
+		"temperature 30",
+		"temperature >",
+		"temperature > hot",
+		"temperature > 30 mph",
+		"epaIndex > 150 ppb",
+		`temperature == "Clear"`,
+		`weatherCode == "Sunny With A Chance Of Meatballs"`,
+		"temperature > 30 for",
+		"temperature > 30 for ever",
+		"temperature > 30 until 2h",
+	} {
+		_, err := ParseCondition(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func windTimeline(gusts ...float64) climacell.Timeline {
+	t := climacell.Timeline{Timestep: "1h"}
+	for i, g := range gusts {
+		t.Intervals = append(t.Intervals, climacell.Interval{
+			StartTime: now.Add(time.Duration(i) * time.Hour),
+			Values:    climacell.Values{"windGust": g},
+		})
+	}
+	return t
+}
+
+func TestRuleEvaluate(t *testing.T) {
+	r, err := NewRule("high-wind", "windGust > 20 m/s for 2h within next 24h")
+	require.NoError(t, err)
+
+	// a single windy hour isn't enough, but the two hour run is
+	matches := r.Evaluate(windTimeline(25, 10, 21, 22, 10), "metric", now)
+	require.Len(t, matches, 1)
+	assert.Equal(t, now.Add(2*time.Hour), matches[0].Start)
+	assert.Equal(t, now.Add(4*time.Hour), matches[0].End)
+	assert.Len(t, matches[0].Intervals, 2)
+
+	// a run at the end of the timeline lasts until the end of its last
+	// interval
+	matches = r.Evaluate(windTimeline(10, 21, 22), "metric", now)
+	require.Len(t, matches, 1)
+	assert.Equal(t, now.Add(3*time.Hour), matches[0].End)
+
+	// 45 mph is only about 20.1 m/s
+	assert.Len(t, r.Evaluate(windTimeline(45, 45), "imperial", now), 1)
+	assert.Len(t, r.Evaluate(windTimeline(44, 44), "imperial", now), 0)
+
+	// outside the window
+	assert.Len(t, r.Evaluate(windTimeline(25, 25), "metric", now.Add(-24*time.Hour)), 0)
+
+	r.Timestep = "1d"
+	assert.Len(t, r.Evaluate(windTimeline(25, 25), "metric", now), 0)
+}
+
+func TestEngine(t *testing.T) {
+	rules, err := Load(strings.NewReader(`
+rules:
+  - name: high-wind
+    expr: windGust > 20 m/s for 2h
+    labels: {severity: warning}
+  - name: any-wind
+    expr: windGust > 0
+`))
+	require.NoError(t, err)
+	e := NewEngine(rules)
+
+	alerts := e.Evaluate("home", []climacell.Timeline{windTimeline(25, 25, 10)}, now)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, "any-wind", alerts[0].Rule)
+	assert.Equal(t, "high-wind", alerts[1].Rule)
+	assert.Equal(t, Firing, alerts[1].Status)
+	assert.Equal(t, "warning", alerts[1].Labels["severity"])
+	assert.Equal(t, "home", alerts[1].Location)
+	assert.Equal(t, "windGust > 20 m/s for 2h0m0s", alerts[1].Condition)
+	assert.Equal(t, now, alerts[1].Start)
+	assert.Equal(t, now.Add(2*time.Hour), alerts[1].End)
+
+	// still firing: nothing changes
+	alerts = e.Evaluate("home", []climacell.Timeline{windTimeline(25, 25, 25)}, now.Add(time.Hour))
+	assert.Empty(t, alerts)
+	require.Len(t, e.Active("home"), 2)
+	assert.Equal(t, now.Add(3*time.Hour), e.Active("home")[1].End)
+	assert.Empty(t, e.Active("cabin"))
+
+	alerts = e.Evaluate("home", []climacell.Timeline{windTimeline(5, 5, 5)}, now.Add(2*time.Hour))
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "high-wind", alerts[0].Rule)
+	assert.Equal(t, Resolved, alerts[0].Status)
+	assert.Equal(t, now.Add(2*time.Hour), alerts[0].Evaluated)
+	assert.Len(t, e.Active("home"), 1)
+}
+
+func TestLoad(t *testing.T) {
+	rules, err := Load(strings.NewReader(`{"rules": [
+		{"name": "smog", "expr": "epaIndex >= 150", "timestep": "1h"}
+	]}`))
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, "smog", rules[0].Name)
+	assert.Equal(t, "1h", rules[0].Timestep)
+	assert.Equal(t, "epaIndex", rules[0].Condition.Field)
+
+	_, err = Load(strings.NewReader(`{"rules": [{"name": "bad", "expr": "epaIndex >= lots"}]}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rule bad")
+
+	_, err = Load(strings.NewReader(`{"rules": [{"name": "a", "expr": "epaIndex > 1"}, {"name": "a", "expr": "epaIndex > 2"}]}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,376 @@
+// Package store keeps a local history of weather data fetched from the
+// ClimaCell API in a SQLite database, so that data which has already been
+// paid for doesn't need to be requested again.
+//
+// Every value is stored as its own row keyed on location, timestep, time and
+// field, along with the time the forecast it came from was issued. Saving the
+// same data again updates it in place, while saving a newer forecast for the
+// same times keeps both forecast vintages, so past forecasts can still be
+// queried after they are revised.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite" // pure-Go SQLite driver
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS weather_values (
+	location TEXT    NOT NULL,
+	timestep TEXT    NOT NULL,
+	time     INTEGER NOT NULL,
+	field    TEXT    NOT NULL,
+	issued   INTEGER NOT NULL,
+	num      REAL,
+	text     TEXT,
+	fetched  INTEGER NOT NULL,
+	PRIMARY KEY (location, timestep, time, field, issued)
+);
+CREATE INDEX IF NOT EXISTS weather_values_issued
+	ON weather_values (location, timestep, issued);
+`
+
+// Store is a SQLite-backed history of fetched weather data. It is safe for
+// concurrent use.
+type Store struct {
+	db *sql.DB
+
+	// now returns the current time, used for recording when data was
+	// saved. It is replaced in tests.
+	now func() time.Time
+}
+
+// Open opens the SQLite database at path, creating it and its tables if they
+// don't exist yet. Use ":memory:" for a database that only lives as long as
+// the Store.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, errors.WithMessage(err, "opening database")
+	}
+	// SQLite only allows one writer at a time, and each connection to
+	// ":memory:" would otherwise get its own empty database.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, errors.WithMessage(err, "creating tables")
+	}
+	return &Store{db: db, now: time.Now}, nil
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error { return s.db.Close() }
+
+// SaveTimelines saves every interval of the timelines fetched for a location,
+// such as those of a TimelineList from ClientV4.GetTimelines. The location is
+// a key like the one returned by Geometry.Key.
+//
+// issued is the time the forecast was issued, which is usually the time it
+// was fetched; data for the same location, timestep, time and field saved
+// with a different issue time is kept as a separate vintage. For data which is
+// not a forecast, such as historical data, pass the zero time.
+func (s *Store) SaveTimelines(ctx context.Context, location string, timelines []climacell.Timeline, issued time.Time) error {
+	return s.inTx(ctx, func(stmt *sql.Stmt) error {
+		for _, t := range timelines {
+			for _, iv := range t.Intervals {
+				if err := s.saveInterval(ctx, stmt, location, t.Timestep, iv, issued); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// SaveSamples saves weather samples returned from one of ClientV3's
+// endpoints, like the []HourlyForecast returned from HourlyForecast or the
+// RealTime returned from RealTime. samples can be either a single sample or a
+// slice of samples. Since v3 samples don't carry their timestep, it is passed
+// in, for example "1h" for hourly forecasts. The location and issued arguments
+// work the same way as in SaveTimelines.
+func (s *Store) SaveSamples(ctx context.Context, location, timestep string, samples interface{}, issued time.Time) error {
+	v := reflect.ValueOf(samples)
+	if v.Kind() != reflect.Slice {
+		v = reflect.ValueOf([]interface{}{samples})
+	}
+
+	return s.inTx(ctx, func(stmt *sql.Stmt) error {
+		for i := 0; i < v.Len(); i++ {
+			iv, err := climacell.SampleInterval(v.Index(i).Interface())
+			if err != nil {
+				return err
+			}
+			if err := s.saveInterval(ctx, stmt, location, timestep, iv, issued); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) inTx(ctx context.Context, fn func(*sql.Stmt) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.WithMessage(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO weather_values (location, timestep, time, field, issued, num, text, fetched)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (location, timestep, time, field, issued) DO UPDATE SET
+			num = excluded.num,
+			text = excluded.text,
+			fetched = excluded.fetched`)
+	if err != nil {
+		return errors.WithMessage(err, "preparing insert")
+	}
+	defer stmt.Close()
+
+	if err := fn(stmt); err != nil {
+		return err
+	}
+	return errors.WithMessage(tx.Commit(), "committing transaction")
+}
+
+func (s *Store) saveInterval(ctx context.Context, stmt *sql.Stmt, location, timestep string, iv climacell.Interval, issued time.Time) error {
+	fetched := toMillis(s.now())
+	for field, value := range iv.Values {
+		var num, text interface{}
+		switch v := value.(type) {
+		case nil:
+			continue
+		case float64, int, int64:
+			num = v
+		case string:
+			text = v
+		case time.Time:
+			// times are stored as the API returns them, rather than as
+			// quoted JSON strings
+			text = v.UTC().Format(time.RFC3339)
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return errors.WithMessagef(err, "serializing %s", field)
+			}
+			text = string(b)
+		}
+
+		_, err := stmt.ExecContext(ctx, location, timestep, toMillis(iv.StartTime), field, toMillis(issued), num, text, fetched)
+		if err != nil {
+			return errors.WithMessagef(err, "saving %s at %s", field, iv.StartTime.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// Query selects the stored data to return from a Store.
+type Query struct {
+	// Location is the key of the location to return data for. Required.
+	Location string
+	// Timestep is the timestep to return data for, like "1h". Required.
+	Timestep string
+	// Start, if nonzero, is the earliest interval start time to return.
+	Start time.Time
+	// End, if nonzero, is the time before which intervals are returned.
+	End time.Time
+	// Fields, if not empty, limits the returned values to these fields.
+	Fields []string
+	// AsOf, if nonzero, returns the data as it was known at this time:
+	// for each value, the vintage with the latest issue time at or before
+	// AsOf is returned. By default the latest vintage is returned.
+	AsOf time.Time
+	// Issued, if nonzero, only returns data from the vintage issued at
+	// exactly this time. It takes precedence over AsOf.
+	Issued time.Time
+	// Observed, if true, only returns data saved with a zero issue time,
+	// such as historical observations. It takes precedence over Issued
+	// and AsOf.
+	Observed bool
+}
+
+func (q Query) where() (string, []interface{}) {
+	conds := []string{"location = ?", "timestep = ?"}
+	args := []interface{}{q.Location, q.Timestep}
+	if !q.Start.IsZero() {
+		conds = append(conds, "time >= ?")
+		args = append(args, toMillis(q.Start))
+	}
+	if !q.End.IsZero() {
+		conds = append(conds, "time < ?")
+		args = append(args, toMillis(q.End))
+	}
+	if len(q.Fields) > 0 {
+		conds = append(conds, "field IN (?"+strings.Repeat(", ?", len(q.Fields)-1)+")")
+		for _, f := range q.Fields {
+			args = append(args, f)
+		}
+	}
+
+	switch {
+	case q.Observed:
+		conds = append(conds, "issued = 0")
+	case !q.Issued.IsZero():
+		conds = append(conds, "issued = ?")
+		args = append(args, toMillis(q.Issued))
+	default:
+		asOf := int64(1<<63 - 1)
+		if !q.AsOf.IsZero() {
+			asOf = toMillis(q.AsOf)
+		}
+		conds = append(conds, `issued = (
+			SELECT MAX(i.issued) FROM weather_values i
+			WHERE i.location = v.location AND i.timestep = v.timestep
+				AND i.time = v.time AND i.field = v.field AND i.issued <= ?)`)
+		args = append(args, asOf)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// Intervals returns the stored intervals matching a query, ordered by start
+// time.
+func (s *Store) Intervals(ctx context.Context, q Query) ([]climacell.Interval, error) {
+	where, args := q.where()
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT time, field, num, text FROM weather_values v WHERE "+where+" ORDER BY time, field", args...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "querying weather values")
+	}
+	defer rows.Close()
+
+	var intervals []climacell.Interval
+	for rows.Next() {
+		var (
+			ms    int64
+			field string
+			num   sql.NullFloat64
+			text  sql.NullString
+		)
+		if err := rows.Scan(&ms, &field, &num, &text); err != nil {
+			return nil, errors.WithMessage(err, "reading weather value")
+		}
+
+		start := fromMillis(ms)
+		if n := len(intervals); n == 0 || !intervals[n-1].StartTime.Equal(start) {
+			intervals = append(intervals, climacell.Interval{StartTime: start, Values: climacell.Values{}})
+		}
+		values := intervals[len(intervals)-1].Values
+		switch {
+		case num.Valid:
+			values[field] = num.Float64
+		case text.Valid:
+			values[field] = text.String
+		}
+	}
+	return intervals, errors.WithMessage(rows.Err(), "reading weather values")
+}
+
+// Timeline returns the stored data matching a query as a Timeline, the same
+// way it is returned from ClientV4.GetTimelines.
+func (s *Store) Timeline(ctx context.Context, q Query) (climacell.Timeline, error) {
+	intervals, err := s.Intervals(ctx, q)
+	if err != nil {
+		return climacell.Timeline{}, err
+	}
+
+	t := climacell.Timeline{Timestep: q.Timestep, Intervals: intervals}
+	if len(intervals) > 0 {
+		t.StartTime = intervals[0].StartTime
+		t.EndTime = intervals[len(intervals)-1].StartTime
+	}
+	return t, nil
+}
+
+// Samples deserializes the stored data matching a query into dst, which must
+// be a pointer to a slice of a v3 sample type, like *[]HourlyForecast. Each
+// interval becomes one sample, with its start time as the observation time.
+func (s *Store) Samples(ctx context.Context, q Query, dst interface{}) error {
+	if t := reflect.TypeOf(dst); t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("store: Samples destination must be a pointer to a slice, got %T", dst)
+	}
+
+	intervals, err := s.Intervals(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	slice := reflect.ValueOf(dst).Elem()
+	slice.SetLen(0)
+	for _, iv := range intervals {
+		sample := reflect.New(slice.Type().Elem())
+		if err := climacell.IntervalSample(iv, sample.Interface()); err != nil {
+			return errors.WithMessage(err, "deserializing stored samples")
+		}
+		slice.Set(reflect.Append(slice, sample.Elem()))
+	}
+	return nil
+}
+
+// Vintages returns the issue times of the forecasts stored for a location
+// and timestep, oldest first. Data saved with a zero issue time is not
+// included.
+func (s *Store) Vintages(ctx context.Context, location, timestep string) ([]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT issued FROM weather_values
+		WHERE location = ? AND timestep = ? AND issued <> 0
+		ORDER BY issued`, location, timestep)
+	if err != nil {
+		return nil, errors.WithMessage(err, "querying forecast vintages")
+	}
+	defer rows.Close()
+
+	var vintages []time.Time
+	for rows.Next() {
+		var ms int64
+		if err := rows.Scan(&ms); err != nil {
+			return nil, errors.WithMessage(err, "reading forecast vintage")
+		}
+		vintages = append(vintages, fromMillis(ms))
+	}
+	return vintages, errors.WithMessage(rows.Err(), "reading forecast vintages")
+}
+
+// Locations returns the keys of every location with stored data, sorted.
+func (s *Store) Locations(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT DISTINCT location FROM weather_values")
+	if err != nil {
+		return nil, errors.WithMessage(err, "querying locations")
+	}
+	defer rows.Close()
+
+	var locations []string
+	for rows.Next() {
+		var loc string
+		if err := rows.Scan(&loc); err != nil {
+			return nil, errors.WithMessage(err, "reading location")
+		}
+		locations = append(locations, loc)
+	}
+	sort.Strings(locations)
+	return locations, errors.WithMessage(rows.Err(), "reading locations")
+}
+
+// toMillis converts a time to Unix milliseconds, with the zero time
+// converting to 0 so that it can mark non-forecast data.
+func toMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC()
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+var start = time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC)
+
+func openTestStore(t *testing.T) *Store {
+	s, err := Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func hourlyTimeline(temps ...float64) []climacell.Timeline {
+	t := climacell.Timeline{Timestep: "1h"}
+	for i, temp := range temps {
+		t.Intervals = append(t.Intervals, climacell.Interval{
+			StartTime: start.Add(time.Duration(i) * time.Hour),
+			Values:    climacell.Values{"temperature": temp, "sunriseTime": "2020-12-21T12:15:00Z"},
+		})
+	}
+	return []climacell.Timeline{t}
+}
+
+func TestSaveTimelinesUpsertsAndKeepsVintages(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	firstRun := start.Add(-6 * time.Hour)
+	secondRun := start.Add(-3 * time.Hour)
+	require.NoError(t, s.SaveTimelines(ctx, "35.8,-78.6", hourlyTimeline(1, 2, 3), firstRun))
+	// re-fetching the same run replaces its values instead of duplicating them
+	require.NoError(t, s.SaveTimelines(ctx, "35.8,-78.6", hourlyTimeline(1.5, 2, 3), firstRun))
+	require.NoError(t, s.SaveTimelines(ctx, "35.8,-78.6", hourlyTimeline(4, 5), secondRun))
+
+	vintages, err := s.Vintages(ctx, "35.8,-78.6", "1h")
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{firstRun, secondRun}, vintages)
+
+	latest, err := s.Timeline(ctx, Query{Location: "35.8,-78.6", Timestep: "1h", Fields: []string{"temperature"}})
+	require.NoError(t, err)
+	require.Len(t, latest.Intervals, 3)
+	assert.Equal(t, climacell.Values{"temperature": 4.0}, latest.Intervals[0].Values)
+	assert.Equal(t, climacell.Values{"temperature": 5.0}, latest.Intervals[1].Values)
+	// the second run didn't cover the third hour, so the first run's value is kept
+	assert.Equal(t, climacell.Values{"temperature": 3.0}, latest.Intervals[2].Values)
+
+	old, err := s.Intervals(ctx, Query{Location: "35.8,-78.6", Timestep: "1h", AsOf: firstRun.Add(time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, old, 3)
+	if v, ok := old[0].Values.Float("temperature"); assert.True(t, ok) {
+		assert.Equal(t, 1.5, v)
+	}
+	if v, ok := old[0].Values.Time("sunriseTime"); assert.True(t, ok) {
+		assert.Equal(t, time.Date(2020, 12, 21, 12, 15, 0, 0, time.UTC), v)
+	}
+
+	window, err := s.Intervals(ctx, Query{
+		Location: "35.8,-78.6",
+		Timestep: "1h",
+		Start:    start.Add(time.Hour),
+		End:      start.Add(2 * time.Hour),
+		Issued:   firstRun,
+	})
+	require.NoError(t, err)
+	require.Len(t, window, 1)
+	assert.Equal(t, start.Add(time.Hour), window[0].StartTime)
+}
+
+func TestSaveTimes(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	sunrise := time.Date(2020, 12, 21, 7, 15, 0, 0, time.FixedZone("EST", -5*3600))
+	require.NoError(t, s.SaveTimelines(ctx, "35.8,-78.6", []climacell.Timeline{{
+		Timestep:  "1d",
+		Intervals: []climacell.Interval{{StartTime: start, Values: climacell.Values{"sunriseTime": sunrise}}},
+	}}, start))
+
+	intervals, err := s.Intervals(ctx, Query{Location: "35.8,-78.6", Timestep: "1d"})
+	require.NoError(t, err)
+	require.Len(t, intervals, 1)
+	assert.Equal(t, "2020-12-21T12:15:00Z", intervals[0].Values["sunriseTime"])
+	if v, ok := intervals[0].Values.Time("sunriseTime"); assert.True(t, ok) {
+		assert.True(t, sunrise.Equal(v))
+	}
+}
+
+func TestSaveSamples(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	temp := 15.1
+	code := "mostly_clear"
+	samples := []climacell.HistoricalStation{{
+		BaseResponseType: climacell.BaseResponseType{ObservationTime: climacell.DateValue{Value: start}},
+		WeatherType: climacell.WeatherType{
+			Temp:        &climacell.FloatValue{Value: &temp},
+			WeatherCode: &climacell.StringValue{Value: &code},
+		},
+	}}
+	require.NoError(t, s.SaveSamples(ctx, "42.4,-71.1", "1h", samples, time.Time{}))
+
+	var got []climacell.HistoricalStation
+	require.NoError(t, s.Samples(ctx, Query{Location: "42.4,-71.1", Timestep: "1h"}, &got))
+	require.Len(t, got, 1)
+	assert.Equal(t, start, got[0].ObservationTime.Value)
+	if v, ok := got[0].Temp.GetValue(); assert.True(t, ok) {
+		assert.Equal(t, 15.1, v)
+	}
+	if v, ok := got[0].WeatherCode.GetValue(); assert.True(t, ok) {
+		assert.Equal(t, "mostly_clear", v)
+	}
+
+	vintages, err := s.Vintages(ctx, "42.4,-71.1", "1h")
+	require.NoError(t, err)
+	assert.Empty(t, vintages)
+
+	locations, err := s.Locations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"42.4,-71.1"}, locations)
+
+	assert.Error(t, s.Samples(ctx, Query{Location: "42.4,-71.1", Timestep: "1h"}, got))
+}
@@ -0,0 +1,245 @@
+// Package telemetry instruments the ClimaCell API clients with OpenTelemetry
+// traces and metrics.
+//
+// NewMiddleware returns a climacell.Middleware that starts a client span for
+// each attempt of a request, as a child of the span in the caller's context,
+// and propagates it to the API in the request's headers. Spans are named
+// after the endpoint, like "climacell timelines", and have attributes for the
+// endpoint, timesteps, number of fields, location type, KeyPool key, HTTP
+// status code and API error code. It also records these metrics:
+//
+//   - climacell.client.request.duration: a histogram of the latency of
+//     requests in seconds, by endpoint and status code.
+//   - climacell.client.retries: a counter of retried requests, by endpoint.
+//   - climacell.client.cache_hits: a counter of calls that shared the
+//     response of an identical call's request, by endpoint.
+//   - climacell.client.quota.remaining: a gauge of the requests remaining
+//     in each rate limit window, as last reported by the API.
+//
+// The middleware is added to a client like any other:
+//
+//	mw, err := telemetry.NewMiddleware(telemetry.Config{})
+//	if err != nil {
+//		/* handle the error */
+//	}
+//	client.Middleware = append(client.Middleware, mw)
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// ScopeName is the instrumentation scope of the tracer and meter.
+const ScopeName = "github.com/maskarb/climacell-go/climacell/v4/telemetry"
+
+// Attribute keys of the spans and metrics.
+const (
+	EndpointKey     = attribute.Key("climacell.endpoint")
+	TimestepsKey    = attribute.Key("climacell.timesteps")
+	FieldCountKey   = attribute.Key("climacell.field_count")
+	LocationTypeKey = attribute.Key("climacell.location.type")
+	AttemptKey      = attribute.Key("climacell.attempt")
+	ErrorCodeKey    = attribute.Key("climacell.error.code")
+	WindowKey       = attribute.Key("climacell.quota.window")
+	KeyNameKey      = attribute.Key("climacell.key.name")
+	StatusCodeKey   = attribute.Key("http.response.status_code")
+	MethodKey       = attribute.Key("http.request.method")
+)
+
+// Config configures the Middleware returned by NewMiddleware.
+type Config struct {
+	// TracerProvider provides the tracer of the spans. It defaults to the
+	// global TracerProvider.
+	TracerProvider trace.TracerProvider
+	// MeterProvider provides the meter of the metrics. It defaults to the
+	// global MeterProvider.
+	MeterProvider metric.MeterProvider
+	// Propagator injects the spans into the requests' headers. It defaults
+	// to the global TextMapPropagator.
+	Propagator propagation.TextMapPropagator
+}
+
+type instruments struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+
+	duration  metric.Float64Histogram
+	retries   metric.Int64Counter
+	cacheHits metric.Int64Counter
+	quota     metric.Int64Gauge
+}
+
+type spanKey struct{}
+
+// NewMiddleware returns Middleware tracing requests and recording their
+// metrics with the providers of cfg.
+func NewMiddleware(cfg Config) (climacell.Middleware, error) {
+	if cfg.TracerProvider == nil {
+		cfg.TracerProvider = otel.GetTracerProvider()
+	}
+	if cfg.MeterProvider == nil {
+		cfg.MeterProvider = otel.GetMeterProvider()
+	}
+	if cfg.Propagator == nil {
+		cfg.Propagator = otel.GetTextMapPropagator()
+	}
+
+	in := instruments{
+		tracer:     cfg.TracerProvider.Tracer(ScopeName),
+		propagator: cfg.Propagator,
+	}
+	meter := cfg.MeterProvider.Meter(ScopeName)
+	var err error
+	if in.duration, err = meter.Float64Histogram("climacell.client.request.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Latency of requests to the ClimaCell API."),
+	); err != nil {
+		return climacell.Middleware{}, errors.WithMessage(err, "creating duration histogram")
+	}
+	if in.retries, err = meter.Int64Counter("climacell.client.retries",
+		metric.WithUnit("{retry}"),
+		metric.WithDescription("Number of retried requests to the ClimaCell API."),
+	); err != nil {
+		return climacell.Middleware{}, errors.WithMessage(err, "creating retries counter")
+	}
+	if in.cacheHits, err = meter.Int64Counter("climacell.client.cache_hits",
+		metric.WithUnit("{call}"),
+		metric.WithDescription("Number of calls that shared the response of an identical call's request."),
+	); err != nil {
+		return climacell.Middleware{}, errors.WithMessage(err, "creating cache hits counter")
+	}
+	if in.quota, err = meter.Int64Gauge("climacell.client.quota.remaining",
+		metric.WithUnit("{request}"),
+		metric.WithDescription("Requests remaining in each rate limit window, as last reported by the API."),
+	); err != nil {
+		return climacell.Middleware{}, errors.WithMessage(err, "creating quota gauge")
+	}
+
+	return climacell.Middleware{
+		BeforeRequest: in.beforeRequest,
+		AfterResponse: in.afterResponse,
+		OnError:       in.onError,
+		OnRetry:       in.onRetry,
+		OnShared:      in.onShared,
+	}, nil
+}
+
+func (in *instruments) beforeRequest(ctx context.Context, req *climacell.RequestInfo) (context.Context, error) {
+	attrs := append(requestAttributes(req),
+		MethodKey.String(req.HTTP.Method),
+		AttemptKey.Int(req.Attempt),
+	)
+	if req.Key != "" {
+		attrs = append(attrs, KeyNameKey.String(req.Key))
+	}
+	ctx, span := in.tracer.Start(ctx, "climacell "+req.Endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	in.propagator.Inject(ctx, propagation.HeaderCarrier(req.HTTP.Header))
+	return context.WithValue(ctx, spanKey{}, span), nil
+}
+
+// afterResponse records the response of an attempt. The spans of error
+// responses are ended by onError or onRetry, which always follow them, with
+// the API's error code.
+func (in *instruments) afterResponse(ctx context.Context, req *climacell.RequestInfo, meta climacell.ResponseMeta) {
+	in.duration.Record(ctx, meta.Latency.Seconds(), metric.WithAttributes(
+		EndpointKey.String(req.Endpoint),
+		StatusCodeKey.Int(meta.StatusCode),
+	))
+	for window, n := range meta.Remaining() {
+		in.quota.Record(ctx, int64(n), metric.WithAttributes(WindowKey.String(window)))
+	}
+
+	span, ok := ctx.Value(spanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	span.SetAttributes(StatusCodeKey.Int(meta.StatusCode))
+	if meta.RequestID != "" {
+		span.SetAttributes(attribute.String("climacell.request_id", meta.RequestID))
+	}
+	if meta.StatusCode < http.StatusBadRequest {
+		span.End()
+	}
+}
+
+func (in *instruments) onError(ctx context.Context, req *climacell.RequestInfo, err error) {
+	endWithError(ctx, err)
+}
+
+func (in *instruments) onRetry(ctx context.Context, req *climacell.RequestInfo, err error, wait time.Duration) {
+	in.retries.Add(ctx, 1, metric.WithAttributes(EndpointKey.String(req.Endpoint)))
+	if span, ok := ctx.Value(spanKey{}).(trace.Span); ok {
+		span.AddEvent("retry", trace.WithAttributes(attribute.String("climacell.retry.wait", wait.String())))
+	}
+	endWithError(ctx, err)
+}
+
+func (in *instruments) onShared(ctx context.Context, req *climacell.RequestInfo, meta climacell.ResponseMeta) {
+	in.cacheHits.Add(ctx, 1, metric.WithAttributes(EndpointKey.String(req.Endpoint)))
+}
+
+// endWithError ends the span of an attempt that failed with err, if the
+// attempt has a span.
+func endWithError(ctx context.Context, err error) {
+	span, ok := ctx.Value(spanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	var errRes *climacell.ErrorResponse
+	if errors.As(err, &errRes) {
+		span.SetAttributes(StatusCodeKey.Int(errRes.StatusCode))
+		if errRes.ErrorCode != "" && errRes.ErrorCode != "0" {
+			span.SetAttributes(ErrorCodeKey.String(errRes.ErrorCode))
+		}
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.End()
+}
+
+// requestAttributes returns the attributes describing the options of a
+// request.
+func requestAttributes(req *climacell.RequestInfo) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{EndpointKey.String(req.Endpoint)}
+	switch o := req.Options.(type) {
+	case *climacell.TimelineListOptions:
+		attrs = append(attrs,
+			TimestepsKey.StringSlice(o.TimeSteps),
+			FieldCountKey.Int(len(o.Fields)),
+		)
+		if o.LocationID != "" {
+			attrs = append(attrs, LocationTypeKey.String("locationId"))
+		} else if o.Location.Type != "" {
+			attrs = append(attrs, LocationTypeKey.String(strings.ToLower(o.Location.Type)))
+		}
+	case climacell.ForecastArgs:
+		attrs = append(attrs, FieldCountKey.Int(len(o.Fields)))
+		if o.Timestep > 0 {
+			attrs = append(attrs, TimestepsKey.StringSlice([]string{fmt.Sprintf("%dm", o.Timestep)}))
+		}
+		switch o.Location.(type) {
+		case climacell.LatLon, *climacell.LatLon:
+			attrs = append(attrs, LocationTypeKey.String("point"))
+		case climacell.LocationID:
+			attrs = append(attrs, LocationTypeKey.String("locationId"))
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,203 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+type fixture struct {
+	spans  *tracetest.SpanRecorder
+	reader *sdkmetric.ManualReader
+	tp     *sdktrace.TracerProvider
+	mw     climacell.Middleware
+}
+
+func newFixture(t *testing.T) *fixture {
+	f := &fixture{
+		spans:  tracetest.NewSpanRecorder(),
+		reader: sdkmetric.NewManualReader(),
+	}
+	f.tp = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(f.spans))
+	mw, err := NewMiddleware(Config{
+		TracerProvider: f.tp,
+		MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(f.reader)),
+		Propagator:     propagation.TraceContext{},
+	})
+	require.NoError(t, err)
+	f.mw = mw
+	return f
+}
+
+// metrics returns the data points of the metrics collected by f's reader,
+// by metric name.
+func (f *fixture) metrics(t *testing.T) map[string]metricdata.Aggregation {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, f.reader.Collect(context.Background(), &rm))
+	res := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			res[m.Name] = m.Data
+		}
+	}
+	return res
+}
+
+func attrs(kvs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	res := map[attribute.Key]attribute.Value{}
+	for _, kv := range kvs {
+		res[kv.Key] = kv.Value
+	}
+	return res
+}
+
+func TestTimelinesSpans(t *testing.T) {
+	var n int32
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		w.Header().Set("X-RateLimit-Remaining-Hour", "24")
+		if atomic.AddInt32(&n, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"code": 429001, "type": "Too Many Calls", "message": "slow down"}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"timelines": []}}`))
+	}))
+	defer server.Close()
+
+	f := newFixture(t)
+	client := climacell.NewClient("test_api_key")
+	client.BaseURL = server.URL
+	client.Middleware = []climacell.Middleware{f.mw}
+	client.Retries = 1
+
+	ctx, parent := f.tp.Tracer("test").Start(context.Background(), "parent")
+	_, err := client.GetTimelines(ctx, &climacell.TimelineListOptions{
+		Location:  climacell.Geometry{Type: "Point", Coordinates: []float64{-78.6, 35.8}},
+		Fields:    []string{"temperature", "humidity"},
+		TimeSteps: []string{"1h"},
+	})
+	require.NoError(t, err)
+	parent.End()
+
+	spans := f.spans.Ended()
+	require.Len(t, spans, 3)
+	failed, ok := spans[0], spans[1]
+	for _, s := range []sdktrace.ReadOnlySpan{failed, ok} {
+		assert.Equal(t, "climacell timelines", s.Name())
+		assert.Equal(t, parent.SpanContext().SpanID(), s.Parent().SpanID())
+	}
+	a := attrs(failed.Attributes())
+	assert.Equal(t, "timelines", a[EndpointKey].AsString())
+	assert.Equal(t, []string{"1h"}, a[TimestepsKey].AsStringSlice())
+	assert.Equal(t, int64(2), a[FieldCountKey].AsInt64())
+	assert.Equal(t, "point", a[LocationTypeKey].AsString())
+	assert.Equal(t, int64(429), a[StatusCodeKey].AsInt64())
+	assert.Equal(t, "429001", a[ErrorCodeKey].AsString())
+	assert.Equal(t, codes.Error, failed.Status().Code)
+	assert.Equal(t, "retry", failed.Events()[0].Name)
+
+	a = attrs(ok.Attributes())
+	assert.Equal(t, int64(2), a[AttemptKey].AsInt64())
+	assert.Equal(t, int64(200), a[StatusCodeKey].AsInt64())
+	assert.NotEqual(t, codes.Error, ok.Status().Code)
+	assert.Contains(t, traceparent, ok.SpanContext().SpanID().String())
+
+	m := f.metrics(t)
+	duration := m["climacell.client.request.duration"].(metricdata.Histogram[float64])
+	assert.Len(t, duration.DataPoints, 2)
+	retries := m["climacell.client.retries"].(metricdata.Sum[int64])
+	require.Len(t, retries.DataPoints, 1)
+	assert.Equal(t, int64(1), retries.DataPoints[0].Value)
+	quota := m["climacell.client.quota.remaining"].(metricdata.Gauge[int64])
+	require.Len(t, quota.DataPoints, 1)
+	assert.Equal(t, int64(24), quota.DataPoints[0].Value)
+	window, _ := quota.DataPoints[0].Attributes.Value(WindowKey)
+	assert.Equal(t, "hour", window.AsString())
+}
+
+func TestV3Spans(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message": "invalid key"}`))
+	}))
+	defer server.Close()
+
+	f := newFixture(t)
+	client := climacell.NewWithClient("test_api_key", server.Client())
+	client.Middleware = []climacell.Middleware{f.mw}
+	// the v3 client's base URL can't be set from outside the package, so
+	// requests are redirected to the server by middleware ahead of f.mw
+	client.Middleware = append([]climacell.Middleware{{
+		BeforeRequest: func(ctx context.Context, req *climacell.RequestInfo) (context.Context, error) {
+			req.HTTP.URL.Scheme = "http"
+			req.HTTP.URL.Host = server.Listener.Addr().String()
+			return ctx, nil
+		},
+	}}, client.Middleware...)
+
+	_, err := client.Nowcast(climacell.ForecastArgs{
+		Location: climacell.LocationID("home"),
+		Timestep: 5,
+		Fields:   []string{"temp"},
+	})
+	assert.Error(t, err)
+
+	spans := f.spans.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "climacell weather/nowcast", spans[0].Name())
+	a := attrs(spans[0].Attributes())
+	assert.Equal(t, []string{"5m"}, a[TimestepsKey].AsStringSlice())
+	assert.Equal(t, "locationId", a[LocationTypeKey].AsString())
+	assert.Equal(t, int64(401), a[StatusCodeKey].AsInt64())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+}
+
+func TestCacheHits(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte(`{"data": {"timelines": []}}`))
+	}))
+	defer server.Close()
+
+	f := newFixture(t)
+	client := climacell.NewClient("test_api_key")
+	client.BaseURL = server.URL
+	client.Middleware = []climacell.Middleware{f.mw}
+
+	done := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		go func() {
+			client.GetTimelines(context.Background(), &climacell.TimelineListOptions{LocationID: "home"})
+			done <- struct{}{}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+
+	assert.Len(t, f.spans.Ended(), 1)
+	hits := f.metrics(t)["climacell.client.cache_hits"].(metricdata.Sum[int64])
+	require.Len(t, hits.DataPoints, 1)
+	assert.Equal(t, int64(2), hits.DataPoints[0].Value)
+}
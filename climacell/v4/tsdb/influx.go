@@ -0,0 +1,117 @@
+package tsdb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/maskarb/climacell-go/climacell/v4/internal/httppost"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// AppendLineProtocol appends p to b as a line of InfluxDB line protocol,
+// with a nanosecond timestamp, and returns the extended buffer. Tags and
+// fields are written in sorted order.
+func AppendLineProtocol(b []byte, p Point) []byte {
+	b = append(b, measurementEscaper.Replace(p.Measurement)...)
+	for _, k := range sortedKeys(p.Tags) {
+		if p.Tags[k] == "" {
+			continue
+		}
+		b = append(b, ',')
+		b = append(b, tagEscaper.Replace(k)...)
+		b = append(b, '=')
+		b = append(b, tagEscaper.Replace(p.Tags[k])...)
+	}
+
+	for i, k := range sortedKeys(p.Fields) {
+		if i == 0 {
+			b = append(b, ' ')
+		} else {
+			b = append(b, ',')
+		}
+		b = append(b, tagEscaper.Replace(k)...)
+		b = append(b, '=')
+		switch v := p.Fields[k].(type) {
+		case int64:
+			b = strconv.AppendInt(b, v, 10)
+			b = append(b, 'i')
+		case float64:
+			b = strconv.AppendFloat(b, v, 'g', -1, 64)
+		case bool:
+			b = strconv.AppendBool(b, v)
+		default:
+			b = append(b, '"')
+			b = append(b, stringEscaper.Replace(fmt.Sprint(v))...)
+			b = append(b, '"')
+		}
+	}
+
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, p.Time.UnixNano(), 10)
+	return append(b, '\n')
+}
+
+// LineProtocol returns points as InfluxDB line protocol.
+func LineProtocol(points []Point) []byte {
+	var b []byte
+	for _, p := range points {
+		if len(p.Fields) == 0 {
+			continue
+		}
+		b = AppendLineProtocol(b, p)
+	}
+	return b
+}
+
+// LineWriter writes points as InfluxDB line protocol to an io.Writer, such as
+// a file to be loaded with "influx write".
+type LineWriter struct {
+	w io.Writer
+}
+
+// NewLineWriter returns a LineWriter writing to w.
+func NewLineWriter(w io.Writer) *LineWriter { return &LineWriter{w: w} }
+
+// WritePoints implements Writer.
+func (w *LineWriter) WritePoints(_ context.Context, points []Point) error {
+	_, err := w.w.Write(LineProtocol(points))
+	return errors.WithMessage(err, "writing line protocol")
+}
+
+// InfluxWriter writes points to an InfluxDB HTTP write endpoint.
+type InfluxWriter struct {
+	// URL is the write endpoint, including its query parameters, for
+	// example "http://localhost:8086/api/v2/write?org=acme&bucket=weather"
+	// for InfluxDB 2 or "http://localhost:8086/write?db=weather" for
+	// InfluxDB 1. The precision must be nanoseconds, the default.
+	URL string
+	// Token, if set, is sent in the Authorization header.
+	Token string
+	// HTTPClient is the client used to send requests. It defaults to a
+	// client with a one minute timeout.
+	HTTPClient *http.Client
+}
+
+// WritePoints implements Writer.
+func (w *InfluxWriter) WritePoints(ctx context.Context, points []Point) error {
+	body := LineProtocol(points)
+	if len(body) == 0 {
+		return nil
+	}
+	header := http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+	if w.Token != "" {
+		header.Set("Authorization", "Token "+w.Token)
+	}
+	return httppost.Post(ctx, w.HTTPClient, w.URL, header, body)
+}
@@ -0,0 +1,135 @@
+package tsdb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"github.com/maskarb/climacell-go/climacell/v4/internal/httppost"
+)
+
+// OpenTSDBPoint is a single data point in the format of OpenTSDB's
+// /api/put endpoint.
+type OpenTSDBPoint struct {
+	// Metric is the point's measurement and field, like
+	// "weather.temperature".
+	Metric string `json:"metric"`
+	// Timestamp is the point's time, in milliseconds since the Unix epoch.
+	Timestamp int64 `json:"timestamp"`
+	// Value is the field's value.
+	Value float64 `json:"value"`
+	// Tags are the point's tags, with characters OpenTSDB doesn't allow
+	// replaced with underscores.
+	Tags map[string]string `json:"tags"`
+}
+
+// OpenTSDBPoints converts points to OpenTSDB data points, one per numeric
+// field, sorted by metric within each point. Since OpenTSDB only stores
+// numbers, string fields are left out.
+func OpenTSDBPoints(points []Point) []OpenTSDBPoint {
+	var out []OpenTSDBPoint
+	for _, p := range points {
+		tags := make(map[string]string, len(p.Tags))
+		for k, v := range p.Tags {
+			if v != "" {
+				tags[openTSDBName(k)] = openTSDBName(v)
+			}
+		}
+		for _, field := range sortedKeys(p.Fields) {
+			var value float64
+			switch v := p.Fields[field].(type) {
+			case float64:
+				value = v
+			case int64:
+				value = float64(v)
+			default:
+				continue
+			}
+			out = append(out, OpenTSDBPoint{
+				Metric:    openTSDBName(p.Measurement + "." + field),
+				Timestamp: p.Time.UnixMilli(),
+				Value:     value,
+				Tags:      tags,
+			})
+		}
+	}
+	return out
+}
+
+// openTSDBName replaces the characters that aren't allowed in OpenTSDB metric
+// names and tags with underscores.
+func openTSDBName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_./", r) {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// AppendOpenTSDB appends p to b in the format of OpenTSDB's telnet "put"
+// command, and returns the extended buffer.
+func AppendOpenTSDB(b []byte, p OpenTSDBPoint) []byte {
+	b = append(b, "put "...)
+	b = append(b, p.Metric...)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, p.Timestamp, 10)
+	b = append(b, ' ')
+	b = strconv.AppendFloat(b, p.Value, 'g', -1, 64)
+	for _, k := range sortedKeys(p.Tags) {
+		b = append(b, ' ')
+		b = append(b, k...)
+		b = append(b, '=')
+		b = append(b, p.Tags[k]...)
+	}
+	return append(b, '\n')
+}
+
+// OpenTSDBWriter writes points as OpenTSDB "put" commands to an io.Writer,
+// such as a file to be loaded with "tsdb import" or a connection to
+// OpenTSDB's telnet interface.
+type OpenTSDBWriter struct {
+	w io.Writer
+}
+
+// NewOpenTSDBWriter returns an OpenTSDBWriter writing to w.
+func NewOpenTSDBWriter(w io.Writer) *OpenTSDBWriter { return &OpenTSDBWriter{w: w} }
+
+// WritePoints implements Writer.
+func (w *OpenTSDBWriter) WritePoints(_ context.Context, points []Point) error {
+	var b []byte
+	for _, p := range OpenTSDBPoints(points) {
+		b = AppendOpenTSDB(b, p)
+	}
+	_, err := w.w.Write(b)
+	return errors.WithMessage(err, "writing OpenTSDB points")
+}
+
+// OpenTSDBHTTPWriter writes points to OpenTSDB's /api/put HTTP endpoint.
+type OpenTSDBHTTPWriter struct {
+	// URL is the put endpoint, for example
+	// "http://localhost:4242/api/put?details".
+	URL string
+	// HTTPClient is the client used to send requests. It defaults to a
+	// client with a one minute timeout.
+	HTTPClient *http.Client
+}
+
+// WritePoints implements Writer.
+func (w *OpenTSDBHTTPWriter) WritePoints(ctx context.Context, points []Point) error {
+	tsdbPoints := OpenTSDBPoints(points)
+	if len(tsdbPoints) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(tsdbPoints)
+	if err != nil {
+		return err
+	}
+	return httppost.Post(ctx, w.HTTPClient, w.URL, http.Header{"Content-Type": {"application/json"}}, body)
+}
@@ -0,0 +1,151 @@
+// Package tsdb converts ClimaCell timelines and v3 weather samples to time
+// series data points, and writes them to InfluxDB, as line protocol, or to
+// OpenTSDB.
+//
+// Each interval becomes one Point per field group, so that, for example,
+// temperature and wind speed are written to the "weather" measurement and
+// the pollen indexes to the "pollen" measurement, tagged with the location,
+// timestep and source they came from.
+package tsdb
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// Names of the tags every Point has.
+const (
+	LocationTag = "location"
+	TimestepTag = "timestep"
+	SourceTag   = "source"
+)
+
+// Tags identifies where a Point's values came from.
+type Tags struct {
+	// Location is the key of the location the values are for.
+	Location string
+	// Timestep is the timestep the values were requested with, like "1h".
+	Timestep string
+	// Source describes where the values came from, for example "forecast"
+	// or "observation". It is left out of the Point's tags if empty.
+	Source string
+}
+
+// Point is a single data point of one or more fields of a measurement.
+type Point struct {
+	// Measurement is the name of the measurement, which is the field
+	// group of the values.
+	Measurement string
+	// Tags are the tags of the point, keyed by LocationTag, TimestepTag and
+	// SourceTag.
+	Tags map[string]string
+	// Fields are the values of the point. Values are float64s, int64s for
+	// int and enum fields, or strings for time fields.
+	Fields map[string]interface{}
+	// Time is the start time of the interval the values are for.
+	Time time.Time
+}
+
+// Writer writes points to a time series database.
+type Writer interface {
+	WritePoints(ctx context.Context, points []Point) error
+}
+
+// IntervalPoints converts an interval to a Point for each field group it has
+// values for, sorted by measurement. Fields are grouped by their Group in the
+// field registry; fields that aren't in the registry, such as those of v3
+// samples, are put in the GroupWeather measurement.
+func IntervalPoints(tags Tags, iv climacell.Interval) []Point {
+	byGroup := map[string]map[string]interface{}{}
+	for name := range iv.Values {
+		group := climacell.GroupWeather
+		kind := climacell.FieldFloat
+		if f, ok := climacell.LookupField(name); ok {
+			group, kind = f.Group, f.Kind
+		}
+
+		var value interface{}
+		switch kind {
+		case climacell.FieldInt, climacell.FieldEnum:
+			if n, ok := iv.Values.Int(name); ok {
+				value = int64(n)
+			}
+		case climacell.FieldTime:
+			if t, ok := iv.Values.Time(name); ok {
+				value = t.UTC().Format(time.RFC3339)
+			}
+		default:
+			if n, ok := iv.Values.Float(name); ok {
+				value = n
+			} else if s, ok := iv.Values[name].(string); ok {
+				value = s
+			}
+		}
+		if value == nil {
+			continue
+		}
+
+		if byGroup[group] == nil {
+			byGroup[group] = map[string]interface{}{}
+		}
+		byGroup[group][name] = value
+	}
+
+	groups := make([]string, 0, len(byGroup))
+	for group := range byGroup {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	points := make([]Point, 0, len(groups))
+	for _, group := range groups {
+		points = append(points, Point{
+			Measurement: group,
+			Tags:        tags.m(),
+			Fields:      byGroup[group],
+			Time:        iv.StartTime,
+		})
+	}
+	return points
+}
+
+// TimelinePoints converts every interval of a timeline requested for a
+// location to points. The timeline's timestep is used as the timestep tag.
+func TimelinePoints(location, source string, t climacell.Timeline) []Point {
+	var points []Point
+	tags := Tags{Location: location, Timestep: t.Timestep, Source: source}
+	for _, iv := range t.Intervals {
+		points = append(points, IntervalPoints(tags, iv)...)
+	}
+	return points
+}
+
+// SamplePoints converts a weather sample returned from one of ClientV3's
+// endpoints, like a HourlyForecast or RealTime, to points.
+func SamplePoints(tags Tags, sample interface{}) ([]Point, error) {
+	iv, err := climacell.SampleInterval(sample)
+	if err != nil {
+		return nil, err
+	}
+	return IntervalPoints(tags, iv), nil
+}
+
+func (t Tags) m() map[string]string {
+	m := map[string]string{LocationTag: t.Location, TimestepTag: t.Timestep}
+	if t.Source != "" {
+		m[SourceTag] = t.Source
+	}
+	return m
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,141 @@
+package tsdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+var start = time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC)
+
+func testTimeline() climacell.Timeline {
+	return climacell.Timeline{
+		Timestep: "1h",
+		Intervals: []climacell.Interval{{
+			StartTime: start,
+			Values: climacell.Values{
+				"temperature": 15.1,
+				"weatherCode": float64(1000),
+				"treeOak":     float64(2),
+				"sunriseTime": "2020-12-21T12:10:00Z",
+			},
+		}},
+	}
+}
+
+func TestTimelinePoints(t *testing.T) {
+	points := TimelinePoints("35.8,-78.6", "forecast", testTimeline())
+	require.Len(t, points, 3)
+
+	assert.Equal(t, "celestial", points[0].Measurement)
+	assert.Equal(t, map[string]interface{}{"sunriseTime": "2020-12-21T12:10:00Z"}, points[0].Fields)
+	assert.Equal(t, "pollen", points[1].Measurement)
+	assert.Equal(t, map[string]interface{}{"treeOak": int64(2)}, points[1].Fields)
+	assert.Equal(t, "weather", points[2].Measurement)
+	assert.Equal(t, map[string]interface{}{"temperature": 15.1, "weatherCode": int64(1000)}, points[2].Fields)
+	assert.Equal(t, map[string]string{"location": "35.8,-78.6", "timestep": "1h", "source": "forecast"}, points[2].Tags)
+	assert.Equal(t, start, points[2].Time)
+}
+
+func TestSamplePoints(t *testing.T) {
+	temp := 10.5
+	points, err := SamplePoints(Tags{Location: "home", Timestep: "1h"}, climacell.HourlyForecast{
+		BaseResponseType: climacell.BaseResponseType{ObservationTime: climacell.DateValue{Value: start}},
+		WeatherType:      climacell.WeatherType{Temp: &climacell.FloatValue{Value: &temp, Units: "C"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, points, 1)
+	assert.Equal(t, "weather", points[0].Measurement)
+	assert.Equal(t, map[string]interface{}{"temperature": 10.5}, points[0].Fields)
+	assert.Equal(t, map[string]string{"location": "home", "timestep": "1h"}, points[0].Tags)
+}
+
+func TestLineProtocol(t *testing.T) {
+	points := TimelinePoints("35.8,-78.6", "forecast", testTimeline())
+	points = append(points, Point{
+		Measurement: "my weather",
+		Tags:        map[string]string{"location": "home=1 a"},
+		Fields:      map[string]interface{}{"note": `say "hi"\`},
+		Time:        start,
+	})
+
+	assert.Equal(t, `celestial,location=35.8\,-78.6,source=forecast,timestep=1h sunriseTime="2020-12-21T12:10:00Z" 1608530400000000000
+pollen,location=35.8\,-78.6,source=forecast,timestep=1h treeOak=2i 1608530400000000000
+weather,location=35.8\,-78.6,source=forecast,timestep=1h temperature=15.1,weatherCode=1000i 1608530400000000000
+my\ weather,location=home\=1\ a note="say \"hi\"\\" 1608530400000000000
+`, string(LineProtocol(points)))
+}
+
+func TestInfluxWriter(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"code":"unauthorized","message":"unauthorized access"}`)
+			return
+		}
+		assert.Equal(t, "/api/v2/write", r.URL.Path)
+		assert.Equal(t, "weather", r.URL.Query().Get("bucket"))
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	points := TimelinePoints("home", "", testTimeline())
+	w := &InfluxWriter{URL: server.URL + "/api/v2/write?org=acme&bucket=weather", Token: "secret"}
+	require.NoError(t, w.WritePoints(context.Background(), points))
+	assert.Equal(t, LineProtocol(points), body)
+
+	w.Token = "wrong"
+	err := w.WritePoints(context.Background(), points)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 401")
+	assert.Contains(t, err.Error(), "unauthorized access")
+}
+
+func TestLineWriter(t *testing.T) {
+	var buf bytes.Buffer
+	points := TimelinePoints("home", "", testTimeline())
+	require.NoError(t, NewLineWriter(&buf).WritePoints(context.Background(), points))
+	assert.Equal(t, string(LineProtocol(points)), buf.String())
+}
+
+func TestOpenTSDBWriter(t *testing.T) {
+	var buf bytes.Buffer
+	points := TimelinePoints("35.8,-78.6", "forecast", testTimeline())
+	require.NoError(t, NewOpenTSDBWriter(&buf).WritePoints(context.Background(), points))
+	assert.Equal(t, `put pollen.treeOak 1608530400000 2 location=35.8_-78.6 source=forecast timestep=1h
+put weather.temperature 1608530400000 15.1 location=35.8_-78.6 source=forecast timestep=1h
+put weather.weatherCode 1608530400000 1000 location=35.8_-78.6 source=forecast timestep=1h
+`, buf.String())
+}
+
+func TestOpenTSDBHTTPWriter(t *testing.T) {
+	var got []OpenTSDBPoint
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	w := &OpenTSDBHTTPWriter{URL: server.URL + "/api/put"}
+	require.NoError(t, w.WritePoints(context.Background(), TimelinePoints("home", "", testTimeline())))
+	require.Len(t, got, 3)
+	assert.Equal(t, OpenTSDBPoint{
+		Metric:    "weather.temperature",
+		Timestamp: 1608530400000,
+		Value:     15.1,
+		Tags:      map[string]string{"location": "home", "timestep": "1h"},
+	}, got[1])
+}
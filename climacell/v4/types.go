@@ -1,22 +1,86 @@
 package climacell
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
 
 type Geometry struct {
 	Type        string      `json:"type"`
 	Coordinates interface{} `json:"coordinates"`
 }
 
+// Key returns a string identifying this Geometry. Points are formatted as
+// "lat,lon", matching LatLon.Key, so that v3 and v4 data for the same place
+// share a key; other geometries are formatted as their GeoJSON.
+func (g Geometry) Key() string {
+	if g.Type == "Point" {
+		if c, ok := g.Coordinates.([]float64); ok && len(c) == 2 {
+			return LatLon{Lat: c[1], Lon: c[0]}.Key()
+		}
+		if c, ok := g.Coordinates.([]string); ok && len(c) == 2 {
+			return c[1] + "," + c[0]
+		}
+		if c, ok := g.Coordinates.([]interface{}); ok && len(c) == 2 {
+			return fmt.Sprint(c[1]) + "," + fmt.Sprint(c[0])
+		}
+	}
+	b, _ := json.Marshal(g)
+	return string(b)
+}
+
 type TimelineListOptions struct {
-	Location  Geometry
-	Fields    []string
-	StartTime string
-	EndTime   string
-	TimeSteps []string
+	Location Geometry `json:"location"`
+	// LocationID, if set, requests data for a location saved with the
+	// Locations API instead of Location.
+	LocationID string   `json:"-"`
+	Fields     []string `json:"fields"`
+	StartTime  string   `json:"startTime,omitempty"`
+	EndTime    string   `json:"endTime,omitempty"`
+	TimeSteps  []string `json:"timesteps"`
+	// Units, if set, is the unit system to request data in, either
+	// "metric" or "imperial". The API defaults to metric.
+	Units string `json:"units,omitempty"`
+}
+
+// MarshalJSON serializes TimelineListOptions to the body of a request to the
+// timelines endpoint, sending LocationID as the location if it is set.
+func (o TimelineListOptions) MarshalJSON() ([]byte, error) {
+	type options TimelineListOptions
+	if o.LocationID == "" {
+		return json.Marshal(options(o))
+	}
+	return json.Marshal(struct {
+		options
+		Location string `json:"location"`
+	}{options(o), o.LocationID})
 }
 
+// TimelineList contains the timelines returned from the timelines endpoint,
+// one for each requested timestep.
 type TimelineList struct {
-	Data []Timeline `json:"data"`
+	Timelines []Timeline `json:"timelines"`
+}
+
+// SavedLocation is a location saved with the ClimaCell Locations API.
+type SavedLocation struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Geometry Geometry `json:"geometry"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// Alert is an alert configured with the ClimaCell Alerts API, which calls a
+// webhook when its insight's conditions are met at one of its locations.
+type Alert struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Insight   string    `json:"insight"`
+	IsActive  bool      `json:"isActive"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
 
 type Timeline struct {
@@ -29,11 +93,127 @@ type Timeline struct {
 type Interval struct {
 	StartTime time.Time `json:"startTime"`
 	Values    Values    `json:"values"`
+	// Extra holds the interval's fields that Interval doesn't model, like
+	// fields added to the API since, keyed by their names. It is nil if
+	// there are none.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON deserializes an Interval from JSON, keeping the fields that
+// it doesn't model in Extra.
+func (iv *Interval) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+
+	type interval Interval
+	if err := json.Unmarshal(b, (*interval)(iv)); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	for name, v := range fields {
+		if _, ok := lookupField(intervalFields, name); ok {
+			continue
+		}
+		if iv.Extra == nil {
+			iv.Extra = map[string]json.RawMessage{}
+		}
+		iv.Extra[name] = v
+	}
+	return nil
+}
+
+// MarshalJSON serializes an Interval to JSON, along with the fields in its
+// Extra.
+func (iv Interval) MarshalJSON() ([]byte, error) {
+	type interval Interval
+	b, err := json.Marshal(interval(iv))
+	if err != nil || len(iv.Extra) == 0 {
+		return b, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	for name, v := range iv.Extra {
+		if _, ok := fields[name]; !ok {
+			fields[name] = v
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// intervalFields are the fields that Interval models.
+var intervalFields = structFields(reflect.TypeOf(Interval{}))
+
+func (iv *Interval) extraFields() *map[string]json.RawMessage { return &iv.Extra }
+
+// Values contains the data for a single interval of a timeline, keyed by the
+// field names that were requested, such as "temperature" or "weatherCode".
+// Numbers are held as float64 and timestamps as RFC3339 strings, the way they
+// are deserialized from the API's JSON; the accessor methods convert them to
+// their Go types.
+type Values map[string]interface{}
+
+// Float returns the value of a numeric field and a true "ok" if present, or
+// returns 0.0 and false "ok" if the field is absent or not a number.
+func (v Values) Float(field string) (val float64, ok bool) {
+	switch n := v[field].(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0.0, false
+}
+
+// Int returns the value of an integer or enum field and a true "ok" if
+// present, or returns 0 and false "ok" if the field is absent or not a
+// number.
+func (v Values) Int(field string) (val int, ok bool) {
+	f, ok := v.Float(field)
+	if !ok {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// String returns the value of a field formatted as a string and a true "ok" if
+// present, or returns a blank string and false "ok" if the field is absent.
+func (v Values) String(field string) (val string, ok bool) {
+	switch s := v[field].(type) {
+	case nil:
+		return "", false
+	case string:
+		return s, true
+	case time.Time:
+		return s.Format(time.RFC3339), true
+	default:
+		return fmt.Sprint(s), true
+	}
 }
 
-type Values struct {
-	Limit int `json:"limit"`
-	Page  int `json:"page"`
+// Time returns the value of a timestamp field and a true "ok" if present, or
+// returns the zero time and false "ok" if the field is absent or is not an
+// RFC3339 timestamp.
+func (v Values) Time(field string) (val time.Time, ok bool) {
+	switch t := v[field].(type) {
+	case time.Time:
+		return t, true
+	case string:
+		tm, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return tm, true
+	}
+	return time.Time{}, false
 }
 
 type Core struct {
@@ -134,11 +314,11 @@ type PrecipitationType struct {
 }
 
 type SunriseTime struct {
-	sunriseTime time.Time `json:"sunriseTime"`
+	SunriseTime time.Time `json:"sunriseTime"`
 }
 
 type SunsetTime struct {
-	sunsetTime time.Time `json:"sunsetTime"`
+	SunsetTime time.Time `json:"sunsetTime"`
 }
 
 type SolarGHI struct {
@@ -0,0 +1,56 @@
+package climacell
+
+import "fmt"
+
+// unit describes how to convert a unit of measure to the base unit of its
+// quantity: base = value*scale + offset.
+type unit struct {
+	quantity      string
+	scale, offset float64
+}
+
+var units = map[string]unit{
+	"C": {"temperature", 1, 0},
+	"F": {"temperature", 5.0 / 9, -32 * 5.0 / 9},
+	"K": {"temperature", 1, -273.15},
+
+	"m/s":  {"speed", 1, 0},
+	"km/h": {"speed", 1 / 3.6, 0},
+	"mph":  {"speed", 0.44704, 0},
+	"kn":   {"speed", 1852.0 / 3600, 0},
+
+	"hPa":  {"pressure", 1, 0},
+	"mbar": {"pressure", 1, 0},
+	"Pa":   {"pressure", 0.01, 0},
+	"inHg": {"pressure", 33.8639, 0},
+
+	"mm/hr": {"precipitation", 1, 0},
+	"in/hr": {"precipitation", 25.4, 0},
+
+	"km": {"distance", 1, 0},
+	"m":  {"distance", 0.001, 0},
+	"mi": {"distance", 1.609344, 0},
+}
+
+// ConvertUnits converts a value from one unit of measure to another, such as
+// from "m/s" to "mph". Units are written the way the field registry writes
+// them, and can also be "K", "km/h", "kn", "mbar", "Pa" or "m". Converting
+// a value to the units it's already in always succeeds, so that unitless
+// values and units like "%" pass through unchanged.
+func ConvertUnits(value float64, from, to string) (float64, error) {
+	if from == to {
+		return value, nil
+	}
+	f, ok := units[from]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", from)
+	}
+	t, ok := units[to]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", to)
+	}
+	if f.quantity != t.quantity {
+		return 0, fmt.Errorf("cannot convert %s, a unit of %s, to %s, a unit of %s", from, f.quantity, to, t.quantity)
+	}
+	return (value*f.scale + f.offset - t.offset) / t.scale, nil
+}
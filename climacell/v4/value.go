@@ -0,0 +1,113 @@
+package climacell
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Value is a field on a Weather returned from the ClimaCell API, or on the
+// typed results of the v4 helpers, whose value may be absent. Its JSON form
+// is an object with the value and its units, like
+// {"value": 15.1, "units": "C"}, with a null value if it is absent.
+//
+// The pointer fields holding Values are nil if a field was not requested or
+// has no data, and all of the methods of a Value can be called on a nil
+// pointer, so that callers don't need to check for nil:
+//
+//	temp, ok := w.Temp.GetValue()
+//	if !ok {
+//		/* handle the case where the temperature value is absent */
+//	}
+type Value[T any] struct {
+	// Value indicates the value for this field on a Weather.
+	Value *T `json:"value"`
+	// Units, if present, indicates the unit of measure for this value.
+	Units string `json:"units,omitempty"`
+}
+
+// StringValue is a field that is of type string.
+type StringValue = Value[string]
+
+// FloatValue is a field that is a floating-point number.
+type FloatValue = Value[float64]
+
+// IntValue is a field that is an integer.
+type IntValue = Value[int]
+
+// TimeValue is a field that is a timestamp.
+type TimeValue = Value[time.Time]
+
+// NewValue returns a Value holding v in the given units.
+func NewValue[T any](v T, units string) *Value[T] {
+	return &Value[T]{Value: &v, Units: units}
+}
+
+// GetValue returns this Value's value and a true "ok" if present, or returns
+// the zero value and false "ok" if either this Value is nil, or its Value is
+// nil.
+func (v *Value[T]) GetValue() (val T, ok bool) {
+	if v == nil || v.Value == nil {
+		return val, false
+	}
+	return *v.Value, true
+}
+
+// OrElse returns this Value's value if present, or def otherwise.
+func (v *Value[T]) OrElse(def T) T {
+	if val, ok := v.GetValue(); ok {
+		return val
+	}
+	return def
+}
+
+// String formats this Value's value followed by its units, like "15.1 C",
+// or returns a blank string if it is absent. Times are formatted as RFC3339.
+func (v *Value[T]) String() string {
+	val, ok := v.GetValue()
+	if !ok {
+		return ""
+	}
+
+	var s string
+	switch x := any(val).(type) {
+	case float64:
+		s = strconv.FormatFloat(x, 'f', -1, 64)
+	case time.Time:
+		s = x.Format(time.RFC3339)
+	default:
+		s = fmt.Sprint(x)
+	}
+	switch v.Units {
+	case "":
+		return s
+	case "%":
+		return s + "%"
+	}
+	return s + " " + v.Units
+}
+
+// MapValue returns a Value holding f applied to v's value, in the same units,
+// or nil if v's value is absent.
+func MapValue[T, U any](v *Value[T], f func(T) U) *Value[U] {
+	val, ok := v.GetValue()
+	if !ok {
+		return nil
+	}
+	return NewValue(f(val), v.Units)
+}
+
+// ConvertValue returns v converted to the given units with ConvertUnits, or
+// nil if v's value is absent. An error is returned if v's units can't be
+// converted to the given ones.
+func ConvertValue(v *FloatValue, to string) (*FloatValue, error) {
+	val, ok := v.GetValue()
+	if !ok {
+		return nil, nil
+	}
+	converted, err := ConvertUnits(val, v.Units, to)
+	if err != nil {
+		return nil, err
+	}
+	return NewValue(converted, to), nil
+}
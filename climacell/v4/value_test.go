@@ -0,0 +1,77 @@
+package climacell
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueGetValue(t *testing.T) {
+	var nilValue *FloatValue
+	_, ok := nilValue.GetValue()
+	assert.False(t, ok)
+	_, ok = (&IntValue{}).GetValue()
+	assert.False(t, ok)
+
+	v, ok := NewValue("rain", "").GetValue()
+	assert.True(t, ok)
+	assert.Equal(t, "rain", v)
+
+	assert.Equal(t, 1.5, nilValue.OrElse(1.5))
+	assert.Equal(t, 0.0, NewValue(0.0, "C").OrElse(1.5))
+}
+
+func TestMapValue(t *testing.T) {
+	upper := MapValue(NewValue("rain", "type"), strings.ToUpper)
+	assert.Equal(t, NewValue("RAIN", "type"), upper)
+
+	assert.Nil(t, MapValue(&FloatValue{Units: "C"}, func(f float64) int { return int(f) }))
+}
+
+func TestValueString(t *testing.T) {
+	assert.Equal(t, "15.1 C", NewValue(15.1, "C").String())
+	assert.Equal(t, "80%", NewValue(80, "%").String())
+	assert.Equal(t, "1001", NewValue(1001, "").String())
+	assert.Equal(t, "2020-12-21T06:00:00Z", NewValue(time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC), "").String())
+	assert.Equal(t, "", (*StringValue)(nil).String())
+}
+
+func TestConvertValue(t *testing.T) {
+	v, err := ConvertValue(NewValue(10.0, "m/s"), "km/h")
+	require.NoError(t, err)
+	assert.Equal(t, "km/h", v.Units)
+	assert.InDelta(t, 36.0, v.OrElse(0), 1e-9)
+
+	v, err = ConvertValue(nil, "km/h")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	_, err = ConvertValue(NewValue(10.0, "m/s"), "C")
+	assert.Error(t, err)
+}
+
+func TestValueJSON(t *testing.T) {
+	b, err := json.Marshal(NewValue(15.1, "C"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"value": 15.1, "units": "C"}`, string(b))
+
+	var f FloatValue
+	require.NoError(t, json.Unmarshal(b, &f))
+	assert.Equal(t, *NewValue(15.1, "C"), f)
+
+	var s StringValue
+	require.NoError(t, json.Unmarshal([]byte(`{"value": null}`), &s))
+	_, ok := s.GetValue()
+	assert.False(t, ok)
+	b, err = json.Marshal(s)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"value": null}`, string(b))
+
+	var tv TimeValue
+	require.NoError(t, json.Unmarshal([]byte(`{"value": "2020-12-21T06:00:00Z"}`), &tv))
+	assert.Equal(t, time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC), tv.OrElse(time.Time{}))
+}
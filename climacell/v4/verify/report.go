@@ -0,0 +1,124 @@
+package verify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"text/tabwriter"
+	"time"
+)
+
+// Report contains the verification scores for the forecasts at a location.
+type Report struct {
+	// Location is the key of the location the forecasts were for.
+	Location string `json:"location"`
+	// Timestep is the timestep of the verified forecasts.
+	Timestep string `json:"timestep"`
+	// Runs is the number of forecast runs that were verified.
+	Runs int `json:"runs"`
+	// Scores contains the error statistics for each field and lead time
+	// bucket, sorted by field and then lead time.
+	Scores []Score `json:"scores"`
+	// Precipitation contains the precipitation contingency counts for
+	// each lead time bucket, sorted by lead time.
+	Precipitation []Contingency `json:"precipitation"`
+}
+
+// Score contains the error statistics for a forecast field at a lead time,
+// where the error of each pair is the forecast value minus the observed
+// value.
+type Score struct {
+	// Field is the forecast field that was scored.
+	Field string `json:"field"`
+	// Lead is the start of the lead time bucket that was scored.
+	Lead time.Duration `json:"lead"`
+	// N is the number of forecast/observation pairs that were scored.
+	N int `json:"n"`
+	// Bias is the mean error; a positive bias means the forecasts were
+	// too high.
+	Bias float64 `json:"bias"`
+	// MAE is the mean absolute error.
+	MAE float64 `json:"mae"`
+	// RMSE is the root mean squared error.
+	RMSE float64 `json:"rmse"`
+}
+
+// Contingency contains the counts of whether precipitation was forecast and
+// whether it was observed, for a lead time bucket.
+type Contingency struct {
+	// Lead is the start of the lead time bucket that was scored.
+	Lead time.Duration `json:"lead"`
+	// Hits is the number of times precipitation was forecast and observed.
+	Hits int `json:"hits"`
+	// Misses is the number of times precipitation was observed but not
+	// forecast.
+	Misses int `json:"misses"`
+	// FalseAlarms is the number of times precipitation was forecast but
+	// not observed.
+	FalseAlarms int `json:"falseAlarms"`
+	// CorrectNegatives is the number of times precipitation was neither
+	// forecast nor observed.
+	CorrectNegatives int `json:"correctNegatives"`
+}
+
+func (c *Contingency) add(forecast, observed bool) {
+	switch {
+	case forecast && observed:
+		c.Hits++
+	case observed:
+		c.Misses++
+	case forecast:
+		c.FalseAlarms++
+	default:
+		c.CorrectNegatives++
+	}
+}
+
+// POD returns the probability of detection, the fraction of observed
+// precipitation that was forecast, or NaN if no precipitation was observed.
+func (c Contingency) POD() float64 { return ratio(c.Hits, c.Hits+c.Misses) }
+
+// FAR returns the false alarm ratio, the fraction of forecast precipitation
+// that was not observed, or NaN if no precipitation was forecast.
+func (c Contingency) FAR() float64 { return ratio(c.FalseAlarms, c.Hits+c.FalseAlarms) }
+
+// CSI returns the critical success index, the fraction of forecast or
+// observed precipitation that was both forecast and observed, or NaN if
+// precipitation was never forecast or observed.
+func (c Contingency) CSI() float64 { return ratio(c.Hits, c.Hits+c.Misses+c.FalseAlarms) }
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return math.NaN()
+	}
+	return float64(n) / float64(d)
+}
+
+// WriteText writes this report as human-readable tables to w.
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Forecast verification for %s (%s timestep, %d runs)\n\n", r.Location, r.Timestep, r.Runs)
+
+	fmt.Fprintln(tw, "FIELD\tLEAD\tN\tBIAS\tMAE\tRMSE\t")
+	for _, s := range r.Scores {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.2f\t%.2f\t%.2f\t\n", s.Field, s.Lead, s.N, s.Bias, s.MAE, s.RMSE)
+	}
+
+	if len(r.Precipitation) > 0 {
+		fmt.Fprintln(tw, "\t\t\t\t\t\t")
+		fmt.Fprintln(tw, "LEAD\tHITS\tMISSES\tFALSE ALARMS\tPOD\tFAR\tCSI\t")
+		for _, c := range r.Precipitation {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.2f\t%.2f\t%.2f\t\n",
+				c.Lead, c.Hits, c.Misses, c.FalseAlarms, c.POD(), c.FAR(), c.CSI())
+		}
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes this report as indented JSON to w.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,396 @@
+// Package verify measures how well ClimaCell forecasts matched what actually
+// happened at a location.
+//
+// Successive forecast runs, such as the results of ClientV3.HourlyForecast or
+// ClientV3.DailyForecast fetched every few hours, are recorded along with the
+// time they were issued, and observations, such as the results of
+// ClientV3.HistoricalStation or ClientV3.HistoricalClimaCell, are recorded as
+// they become available. Verify then pairs each forecast value with the
+// observation for the same time, and scores the forecasts per field and by
+// lead time, which is how far ahead of the forecast's issue time it was
+// predicting.
+package verify
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+	"github.com/maskarb/climacell-go/climacell/v4/store"
+)
+
+// DefaultPrecipitationThreshold is the precipitation intensity, in mm/hr,
+// at or above which a forecast or observation counts as precipitation.
+const DefaultPrecipitationThreshold = 0.1
+
+// FieldAliases maps the v3 names of fields to the fields on hourly samples
+// they are verified against. Samples are recorded with their v4 names by
+// climacell.SampleInterval, but the aliases still resolve v3 names given in
+// Options.
+var FieldAliases = map[string]string{
+	"temp":           "temperature",
+	"feels_like":     "temperatureApparent",
+	"wind_speed":     "windSpeed",
+	"wind_direction": "windDirection",
+	"baro_pressure":  "pressureSurfaceLevel",
+	"precipitation":  "precipitationIntensity",
+}
+
+// Verifier records forecast runs and observations in a store.Store, and
+// scores the forecasts against the observations.
+type Verifier struct {
+	// ObservationTimestep is the timestep observations are recorded with.
+	// It defaults to "1h".
+	ObservationTimestep string
+
+	store *store.Store
+}
+
+// New returns a Verifier that records forecasts and observations in s.
+func New(s *store.Store) *Verifier {
+	return &Verifier{ObservationTimestep: "1h", store: s}
+}
+
+// RecordForecast records a forecast run for a location, issued at the given
+// time. forecast is either a slice of v3 samples, like the []HourlyForecast
+// returned from ClientV3.HourlyForecast or the []ForecastDay returned from
+// ClientV3.DailyForecast, or a []Timeline from ClientV4.GetTimelines. The
+// timestep is the one the forecast was requested with, like "1h" or "1d".
+func (v *Verifier) RecordForecast(ctx context.Context, location, timestep string, forecast interface{}, issued time.Time) error {
+	if issued.IsZero() {
+		return errors.New("forecast issue time must be nonzero")
+	}
+	if timelines, ok := forecast.([]climacell.Timeline); ok {
+		return v.store.SaveTimelines(ctx, location, timelines, issued)
+	}
+	return v.store.SaveSamples(ctx, location, timestep, forecast, issued)
+}
+
+// RecordObservations records observations for a location, like the
+// []HistoricalStation returned from ClientV3.HistoricalStation.
+func (v *Verifier) RecordObservations(ctx context.Context, location string, observations interface{}) error {
+	return v.store.SaveSamples(ctx, location, v.observationTimestep(), observations, time.Time{})
+}
+
+func (v *Verifier) observationTimestep() string {
+	if v.ObservationTimestep == "" {
+		return "1h"
+	}
+	return v.ObservationTimestep
+}
+
+// Options selects the forecasts to verify.
+type Options struct {
+	// Location is the key of the location to verify forecasts for.
+	Location string
+	// Timestep is the timestep of the forecast runs to verify, like "1h"
+	// or "1d".
+	Timestep string
+	// Fields, if not empty, limits verification to these forecast fields.
+	// By default every numeric field with matching observations is
+	// verified. Enum fields, like weatherCode, are never verified.
+	Fields []string
+	// Start and End, if nonzero, limit verification to forecasts valid
+	// within [Start, End).
+	Start time.Time
+	End   time.Time
+	// LeadBucket is the width of the lead time buckets scores are grouped
+	// in. It defaults to the forecast timestep, or an hour if the
+	// timestep can't be parsed.
+	LeadBucket time.Duration
+	// PrecipitationField is the forecast field used for the precipitation
+	// hit/miss/false alarm scores. It defaults to
+	// "precipitationIntensity". Daily forecasts are scored with the field's
+	// daily maximum, like "precipitationIntensityMax", unless it names an
+	// aggregate itself.
+	PrecipitationField string
+	// PrecipitationThreshold is the value at or above which the
+	// precipitation field counts as precipitation. It defaults to
+	// DefaultPrecipitationThreshold.
+	PrecipitationThreshold float64
+}
+
+// Verify pairs every recorded forecast run matching opts with the recorded
+// observations and returns the scores.
+//
+// Forecasts with a daily ("1d") timestep are verified against the
+// observations aggregated over the 24 hours starting at the forecast time: a
+// field ending in "Max" or "Min" is compared with the maximum or minimum
+// observation, and other fields with the mean. Field names of v3 daily
+// forecasts are translated to the observed field names with FieldAliases.
+func (v *Verifier) Verify(ctx context.Context, opts Options) (*Report, error) {
+	step := timestepDuration(opts.Timestep)
+	bucket := opts.LeadBucket
+	if bucket <= 0 {
+		bucket = step
+		if bucket <= 0 {
+			bucket = time.Hour
+		}
+	}
+	precipField := opts.PrecipitationField
+	if precipField == "" {
+		precipField = "precipitationIntensity"
+	}
+	threshold := opts.PrecipitationThreshold
+	if threshold == 0 {
+		threshold = DefaultPrecipitationThreshold
+	}
+	wanted := map[string]bool{}
+	for _, f := range opts.Fields {
+		wanted[f] = true
+	}
+
+	obsWindowEnd := opts.End
+	if !obsWindowEnd.IsZero() && step > 0 {
+		obsWindowEnd = obsWindowEnd.Add(step)
+	}
+	observed, err := v.store.Intervals(ctx, store.Query{
+		Location: opts.Location,
+		Timestep: v.observationTimestep(),
+		Start:    opts.Start,
+		End:      obsWindowEnd,
+		Observed: true,
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "loading observations")
+	}
+	obs := observations(observed)
+
+	runs, err := v.store.Vintages(ctx, opts.Location, opts.Timestep)
+	if err != nil {
+		return nil, errors.WithMessage(err, "loading forecast runs")
+	}
+
+	acc := map[scoreKey]*accumulator{}
+	contingency := map[time.Duration]*Contingency{}
+	report := &Report{Location: opts.Location, Timestep: opts.Timestep}
+	for _, issued := range runs {
+		forecast, err := v.store.Intervals(ctx, store.Query{
+			Location: opts.Location,
+			Timestep: opts.Timestep,
+			Start:    opts.Start,
+			End:      opts.End,
+			Issued:   issued,
+		})
+		if err != nil {
+			return nil, errors.WithMessagef(err, "loading forecast issued at %s", issued.Format(time.RFC3339))
+		}
+		if len(forecast) == 0 {
+			continue
+		}
+		report.Runs++
+
+		for _, iv := range forecast {
+			lead := iv.StartTime.Sub(issued)
+			if lead < 0 {
+				continue
+			}
+			lead = lead / bucket * bucket
+
+			for field := range iv.Values {
+				if len(wanted) > 0 && !wanted[field] {
+					continue
+				}
+				if !scored(field) {
+					continue
+				}
+				f, ok := iv.Values.Float(field)
+				if !ok {
+					continue
+				}
+				o, ok := obs.value(field, iv.StartTime, step, opts.Timestep == "1d")
+				if !ok {
+					continue
+				}
+
+				key := scoreKey{field: field, lead: lead}
+				if acc[key] == nil {
+					acc[key] = &accumulator{}
+				}
+				acc[key].add(difference(field, f, o))
+
+				if isField(field, precipField) {
+					c := contingency[lead]
+					if c == nil {
+						c = &Contingency{Lead: lead}
+						contingency[lead] = c
+					}
+					c.add(f >= threshold, o >= threshold)
+				}
+			}
+		}
+	}
+
+	for key, a := range acc {
+		report.Scores = append(report.Scores, a.score(key))
+	}
+	sort.Slice(report.Scores, func(i, j int) bool {
+		a, b := report.Scores[i], report.Scores[j]
+		if a.Field != b.Field {
+			return a.Field < b.Field
+		}
+		return a.Lead < b.Lead
+	})
+	for _, c := range contingency {
+		report.Precipitation = append(report.Precipitation, *c)
+	}
+	sort.Slice(report.Precipitation, func(i, j int) bool {
+		return report.Precipitation[i].Lead < report.Precipitation[j].Lead
+	})
+	return report, nil
+}
+
+type observationSet struct {
+	times  []time.Time
+	byTime map[time.Time]climacell.Values
+}
+
+func observations(intervals []climacell.Interval) observationSet {
+	set := observationSet{byTime: make(map[time.Time]climacell.Values, len(intervals))}
+	for _, iv := range intervals {
+		set.times = append(set.times, iv.StartTime)
+		set.byTime[iv.StartTime] = iv.Values
+	}
+	return set
+}
+
+// splitField returns the observed field a forecast field is verified
+// against, and the suffix of the field's daily aggregate, if it is one.
+func splitField(field string) (base, agg string) {
+	base = field
+	for _, suffix := range []string{"Max", "Min", "Avg"} {
+		if strings.HasSuffix(field, suffix) {
+			base, agg = strings.TrimSuffix(field, suffix), suffix
+			break
+		}
+	}
+	if alias, ok := FieldAliases[base]; ok {
+		base = alias
+	}
+	return base, agg
+}
+
+// scored reports whether a forecast field is scored: fields of the field
+// registry are only scored if they are float or int measurements, since the
+// differences between enum codes like weather codes mean nothing.
+func scored(field string) bool {
+	base, _ := splitField(field)
+	f, ok := climacell.LookupField(base)
+	return !ok || f.Kind == climacell.FieldFloat || f.Kind == climacell.FieldInt
+}
+
+// difference returns the error of a forecast value f of a field against the
+// observed value o. Wind directions are compared around the circle, so that
+// 350 against 10 degrees is an error of -20 degrees rather than 340.
+func difference(field string, f, o float64) float64 {
+	d := f - o
+	if base, _ := splitField(field); base == "windDirection" {
+		d = math.Mod(d+540, 360) - 180
+	}
+	return d
+}
+
+// isField reports whether a forecast field is the field named by an
+// option, resolving aliases. A field without an aggregate suffix selects the
+// daily maximum of daily forecasts.
+func isField(field, option string) bool {
+	base, agg := splitField(field)
+	obase, oagg := splitField(option)
+	return base == obase && (agg == oagg || (oagg == "" && agg == "Max"))
+}
+
+// value returns the observed value to compare a forecast field at time t
+// with.
+func (s observationSet) value(field string, t time.Time, step time.Duration, daily bool) (float64, bool) {
+	base, agg := splitField(field)
+
+	if !daily {
+		if agg != "" {
+			return 0, false
+		}
+		return s.byTime[t].Float(base)
+	}
+
+	end := t.Add(step)
+	i := sort.Search(len(s.times), func(i int) bool { return !s.times[i].Before(t) })
+	var (
+		n             int
+		sum, min, max float64
+	)
+	for ; i < len(s.times) && s.times[i].Before(end); i++ {
+		v, ok := s.byTime[s.times[i]].Float(base)
+		if !ok {
+			continue
+		}
+		if n == 0 || v < min {
+			min = v
+		}
+		if n == 0 || v > max {
+			max = v
+		}
+		sum += v
+		n++
+	}
+	if n == 0 {
+		return 0, false
+	}
+	switch agg {
+	case "Max":
+		return max, true
+	case "Min":
+		return min, true
+	default:
+		return sum / float64(n), true
+	}
+}
+
+// timestepDuration parses a timestep like "1m", "1h" or "1d", returning 0 if
+// it can't be parsed.
+func timestepDuration(timestep string) time.Duration {
+	if strings.HasSuffix(timestep, "d") {
+		d, err := time.ParseDuration(strings.TrimSuffix(timestep, "d") + "h")
+		if err != nil {
+			return 0
+		}
+		return 24 * d
+	}
+	d, err := time.ParseDuration(timestep)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+type scoreKey struct {
+	field string
+	lead  time.Duration
+}
+
+type accumulator struct {
+	n                  int
+	sum, sumAbs, sumSq float64
+}
+
+func (a *accumulator) add(err float64) {
+	a.n++
+	a.sum += err
+	a.sumAbs += math.Abs(err)
+	a.sumSq += err * err
+}
+
+func (a *accumulator) score(key scoreKey) Score {
+	n := float64(a.n)
+	return Score{
+		Field: key.field,
+		Lead:  key.lead,
+		N:     a.n,
+		Bias:  a.sum / n,
+		MAE:   a.sumAbs / n,
+		RMSE:  math.Sqrt(a.sumSq / n),
+	}
+}
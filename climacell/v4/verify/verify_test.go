@@ -0,0 +1,194 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+	"github.com/maskarb/climacell-go/climacell/v4/store"
+)
+
+const site = "35.8,-78.6"
+
+var day = time.Date(2020, 12, 21, 0, 0, 0, 0, time.UTC)
+
+func float(v float64) *climacell.FloatValue { return &climacell.FloatValue{Value: &v} }
+
+func hourly(t time.Time, temp, precip float64) climacell.HourlyForecast {
+	return climacell.HourlyForecast{
+		BaseResponseType: climacell.BaseResponseType{ObservationTime: climacell.DateValue{Value: t}},
+		WeatherType: climacell.WeatherType{
+			Temp:          float(temp),
+			Precipitation: float(precip),
+		},
+	}
+}
+
+func station(t time.Time, temp, precip float64) climacell.HistoricalStation {
+	return climacell.HistoricalStation{
+		BaseResponseType: climacell.BaseResponseType{ObservationTime: climacell.DateValue{Value: t}},
+		WeatherType: climacell.WeatherType{
+			Temp:          float(temp),
+			Precipitation: float(precip),
+		},
+	}
+}
+
+func newVerifier(t *testing.T) *Verifier {
+	s, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return New(s)
+}
+
+func TestVerifyHourly(t *testing.T) {
+	ctx := context.Background()
+	v := newVerifier(t)
+
+	require.NoError(t, v.RecordObservations(ctx, site, []climacell.HistoricalStation{
+		station(day.Add(1*time.Hour), 10, 0),
+		station(day.Add(2*time.Hour), 12, 1.5),
+		station(day.Add(3*time.Hour), 14, 0),
+	}))
+
+	// issued at midnight: 1h and 2h leads
+	require.NoError(t, v.RecordForecast(ctx, site, "1h", []climacell.HourlyForecast{
+		hourly(day.Add(1*time.Hour), 11, 0.5),
+		hourly(day.Add(2*time.Hour), 10, 0),
+	}, day))
+	// issued at 1am: 1h and 2h leads
+	require.NoError(t, v.RecordForecast(ctx, site, "1h", []climacell.HourlyForecast{
+		hourly(day.Add(2*time.Hour), 13, 2),
+		hourly(day.Add(3*time.Hour), 14, 0),
+	}, day.Add(time.Hour)))
+
+	r, err := v.Verify(ctx, Options{Location: site, Timestep: "1h"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, r.Runs)
+
+	var temp1h, temp2h Score
+	for _, s := range r.Scores {
+		switch {
+		case s.Field == "temperature" && s.Lead == time.Hour:
+			temp1h = s
+		case s.Field == "temperature" && s.Lead == 2*time.Hour:
+			temp2h = s
+		}
+	}
+	// 1h lead errors: +1 (11 vs 10), +1 (13 vs 12)
+	assert.Equal(t, 2, temp1h.N)
+	assert.InDelta(t, 1, temp1h.Bias, 1e-9)
+	assert.InDelta(t, 1, temp1h.MAE, 1e-9)
+	assert.InDelta(t, 1, temp1h.RMSE, 1e-9)
+	// 2h lead errors: -2 (10 vs 12), 0 (14 vs 14)
+	assert.Equal(t, 2, temp2h.N)
+	assert.InDelta(t, -1, temp2h.Bias, 1e-9)
+	assert.InDelta(t, 1, temp2h.MAE, 1e-9)
+	assert.InDelta(t, 1.41421356, temp2h.RMSE, 1e-6)
+
+	require.Len(t, r.Precipitation, 2)
+	// 1h lead: false alarm at 1am, hit at 2am
+	assert.Equal(t, Contingency{Lead: time.Hour, Hits: 1, FalseAlarms: 1}, r.Precipitation[0])
+	// 2h lead: miss at 2am, correct negative at 3am
+	assert.Equal(t, Contingency{Lead: 2 * time.Hour, Misses: 1, CorrectNegatives: 1}, r.Precipitation[1])
+	assert.InDelta(t, 0.5, r.Precipitation[0].FAR(), 1e-9)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteText(&buf))
+	assert.Contains(t, buf.String(), "temperature")
+	assert.Contains(t, buf.String(), "FALSE ALARMS")
+}
+
+func TestVerifyFieldKinds(t *testing.T) {
+	ctx := context.Background()
+	v := newVerifier(t)
+
+	sample := func(direction float64, code string) climacell.WeatherType {
+		return climacell.WeatherType{WindDirection: float(direction), WeatherCode: &climacell.StringValue{Value: &code}}
+	}
+	require.NoError(t, v.RecordObservations(ctx, site, []climacell.HistoricalStation{{
+		BaseResponseType: climacell.BaseResponseType{ObservationTime: climacell.DateValue{Value: day.Add(time.Hour)}},
+		WeatherType:      sample(10, "clear"),
+	}}))
+	require.NoError(t, v.RecordForecast(ctx, site, "1h", []climacell.HourlyForecast{{
+		BaseResponseType: climacell.BaseResponseType{ObservationTime: climacell.DateValue{Value: day.Add(time.Hour)}},
+		WeatherType:      sample(350, "rain"),
+	}}, day))
+
+	r, err := v.Verify(ctx, Options{Location: site, Timestep: "1h"})
+	require.NoError(t, err)
+	// weather codes aren't scored, and wind directions wrap around
+	require.Len(t, r.Scores, 1)
+	assert.Equal(t, "windDirection", r.Scores[0].Field)
+	assert.InDelta(t, -20, r.Scores[0].Bias, 1e-9)
+	assert.InDelta(t, 20, r.Scores[0].MAE, 1e-9)
+}
+
+func TestVerifyDaily(t *testing.T) {
+	ctx := context.Background()
+	v := newVerifier(t)
+
+	var obs []climacell.HistoricalStation
+	for h := 0; h < 24; h++ {
+		obs = append(obs, station(day.Add(time.Duration(h)*time.Hour), float64(h), 0))
+	}
+	require.NoError(t, v.RecordObservations(ctx, site, obs))
+
+	high, low := 25.0, 1.0
+	forecast := []climacell.ForecastDay{{
+		ObservationTime: climacell.DateValue{Value: day},
+		Temp: &climacell.ForecastMinAndMax{
+			{ObservationTime: day, Min: &climacell.FloatValue{Value: &low}},
+			{ObservationTime: day.Add(15 * time.Hour), Max: &climacell.FloatValue{Value: &high}},
+		},
+	}}
+	require.NoError(t, v.RecordForecast(ctx, site, "1d", forecast, day.Add(-24*time.Hour)))
+
+	r, err := v.Verify(ctx, Options{Location: site, Timestep: "1d"})
+	require.NoError(t, err)
+	require.Len(t, r.Scores, 2)
+	assert.Equal(t, "temperatureMax", r.Scores[0].Field)
+	assert.Equal(t, 24*time.Hour, r.Scores[0].Lead)
+	assert.InDelta(t, 2, r.Scores[0].Bias, 1e-9)
+	assert.Equal(t, "temperatureMin", r.Scores[1].Field)
+	assert.InDelta(t, 1, r.Scores[1].Bias, 1e-9)
+}
+
+func TestVerifyDailyPrecipitation(t *testing.T) {
+	ctx := context.Background()
+	v := newVerifier(t)
+
+	// it rained on the first day, but not the second
+	var obs []climacell.HistoricalStation
+	for h := 0; h < 48; h++ {
+		precip := 0.0
+		if h == 10 {
+			precip = 2
+		}
+		obs = append(obs, station(day.Add(time.Duration(h)*time.Hour), 10, precip))
+	}
+	require.NoError(t, v.RecordObservations(ctx, site, obs))
+
+	forecastDay := func(t time.Time, max float64) climacell.ForecastDay {
+		return climacell.ForecastDay{
+			ObservationTime: climacell.DateValue{Value: t},
+			Precipitation: &climacell.ForecastMinAndMax{
+				{ObservationTime: t, Min: float(0)},
+				{ObservationTime: t.Add(12 * time.Hour), Max: float(max)},
+			},
+		}
+	}
+	forecast := []climacell.ForecastDay{forecastDay(day, 1.2), forecastDay(day.Add(24*time.Hour), 0.5)}
+	require.NoError(t, v.RecordForecast(ctx, site, "1d", forecast, day))
+
+	for _, field := range []string{"", "precipitation"} {
+		r, err := v.Verify(ctx, Options{Location: site, Timestep: "1d", LeadBucket: 48 * time.Hour, PrecipitationField: field})
+		require.NoError(t, err)
+		assert.Equal(t, []Contingency{{Hits: 1, FalseAlarms: 1}}, r.Precipitation, "field %q", field)
+	}
+}
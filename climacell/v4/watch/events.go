@@ -0,0 +1,102 @@
+package watch
+
+import (
+	"fmt"
+	"time"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// Event is sent by a Watcher when something about the weather at its
+// location changes. It is one of *ForecastRevision, *ConditionsChange,
+// *PrecipitationStart, *PrecipitationStop or *PollError.
+type Event interface {
+	// Detected returns the time of the poll that detected the event.
+	Detected() time.Time
+	// String returns a short description of the event.
+	String() string
+}
+
+type detected time.Time
+
+func (d detected) Detected() time.Time { return time.Time(d) }
+
+// ForecastRevision is sent when the forecast value of a field for a time
+// changes by at least the field's threshold from the value last reported
+// for that time.
+type ForecastRevision struct {
+	detected
+	// Field is the revised field.
+	Field string
+	// Time is the start of the forecast interval that was revised.
+	Time time.Time
+	// Previous is the value last reported for the interval, and Current
+	// the revised value.
+	Previous, Current float64
+}
+
+func (e *ForecastRevision) String() string {
+	return fmt.Sprintf("%s forecast for %s revised from %g to %g", e.Field, e.Time.Format(time.RFC3339), e.Previous, e.Current)
+}
+
+// ConditionsChange is sent when the current weatherCode moves to a
+// different category, for example from clear or cloudy (1xxx) to rain
+// (4xxx). Changes within a category, like from "Clear" to "Mostly Clear",
+// don't send an event.
+type ConditionsChange struct {
+	detected
+	// Previous and Current are the previous and current weatherCodes.
+	Previous, Current int
+}
+
+func (e *ConditionsChange) String() string {
+	return fmt.Sprintf("conditions changed from %s to %s", codeLabel(e.Previous), codeLabel(e.Current))
+}
+
+// PrecipitationStart is sent when it isn't precipitating and the nowcast
+// predicts precipitation within the nowcast window.
+type PrecipitationStart struct {
+	detected
+	// At is the start of the first nowcast interval with precipitation.
+	At time.Time
+	// Intensity is the precipitation intensity of that interval.
+	Intensity float64
+}
+
+func (e *PrecipitationStart) String() string {
+	return fmt.Sprintf("precipitation expected at %s (%g)", e.At.Format(time.RFC3339), e.Intensity)
+}
+
+// PrecipitationStop is sent when it is precipitating and the nowcast
+// predicts the precipitation stopping within the nowcast window.
+type PrecipitationStop struct {
+	detected
+	// At is the start of the first nowcast interval without precipitation.
+	At time.Time
+}
+
+func (e *PrecipitationStop) String() string {
+	return fmt.Sprintf("precipitation expected to stop at %s", e.At.Format(time.RFC3339))
+}
+
+// PollError is sent when a poll fails. The Watcher retries after RetryIn,
+// backing off while the errors continue.
+type PollError struct {
+	detected
+	Err     error
+	RetryIn time.Duration
+}
+
+func (e *PollError) String() string {
+	return fmt.Sprintf("poll failed, retrying in %s: %v", e.RetryIn, e.Err)
+}
+
+// Unwrap returns the error the poll failed with.
+func (e *PollError) Unwrap() error { return e.Err }
+
+func codeLabel(code int) string {
+	if label, ok := climacell.WeatherCodeLabels[code]; ok {
+		return label
+	}
+	return fmt.Sprintf("weatherCode %d", code)
+}
@@ -0,0 +1,380 @@
+// Package watch polls the ClimaCell forecast for a location and reports
+// changes as events.
+//
+// A Watcher sends events on a channel when the forecast for a field is
+// revised by more than a threshold, when the current conditions move to a
+// different weatherCode category, and when the nowcast predicts
+// precipitation starting or stopping. Each change is reported once: a
+// revision is measured from the last value reported for the same forecast
+// time, and precipitation starting or stopping is only reported again after
+// the nowcast changes its mind.
+package watch
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// Defaults for a Watcher's settings.
+const (
+	DefaultInterval               = 10 * time.Minute
+	DefaultTimestep               = "1h"
+	DefaultHorizon                = 24 * time.Hour
+	DefaultNowcastWindow          = time.Hour
+	DefaultPrecipitationThreshold = 0.1
+	DefaultMinBackoff             = 30 * time.Second
+	DefaultMaxBackoff             = 30 * time.Minute
+)
+
+// DefaultThresholds are the revision thresholds used for a Watcher's
+// fields, in metric units.
+var DefaultThresholds = map[string]float64{
+	"temperature":              2,
+	"windSpeed":                3,
+	"windGust":                 5,
+	"precipitationIntensity":   1,
+	"precipitationProbability": 20,
+}
+
+// Client is the subset of ClientV4 a Watcher uses.
+type Client interface {
+	GetTimelines(ctx context.Context, options *climacell.TimelineListOptions) (*climacell.TimelineList, error)
+}
+
+// Watcher polls the forecast for a location. The exported fields must not be
+// changed after calling Watch.
+type Watcher struct {
+	// LocationID, if set, is the ID of a saved location to watch instead
+	// of the location passed to New.
+	LocationID string
+	// Interval is the time between polls. It defaults to DefaultInterval.
+	Interval time.Duration
+	// Timestep is the timestep of the forecast watched for revisions. It
+	// defaults to DefaultTimestep.
+	Timestep string
+	// Horizon is how far ahead the forecast is watched for revisions. It
+	// defaults to DefaultHorizon.
+	Horizon time.Duration
+	// Thresholds maps the fields watched for revisions to the change in
+	// value that counts as a revision. It defaults to DefaultThresholds.
+	Thresholds map[string]float64
+	// NowcastWindow is how far ahead the 5 minute nowcast is watched for
+	// precipitation starting or stopping. It defaults to
+	// DefaultNowcastWindow and can be at most 6 hours.
+	NowcastWindow time.Duration
+	// PrecipitationThreshold is the precipitationIntensity, in mm/hr, at
+	// or above which it counts as precipitating. It defaults to
+	// DefaultPrecipitationThreshold.
+	PrecipitationThreshold float64
+	// MinBackoff and MaxBackoff bound the time before retrying a failed
+	// poll, which doubles with each consecutive failure. They default to
+	// DefaultMinBackoff and DefaultMaxBackoff.
+	MinBackoff, MaxBackoff time.Duration
+
+	client   Client
+	location climacell.Geometry
+
+	// state kept between polls
+	reported map[revisionKey]float64
+	code     int
+	precip   precipState
+}
+
+type revisionKey struct {
+	field string
+	time  time.Time
+}
+
+type precipState int
+
+const (
+	precipUnknown precipState = iota
+	precipNone
+	precipStarting
+	precipStopping
+)
+
+// New returns a Watcher polling the forecast for location with c.
+func New(c Client, location climacell.Geometry) *Watcher {
+	return &Watcher{
+		Interval:               DefaultInterval,
+		Timestep:               DefaultTimestep,
+		Horizon:                DefaultHorizon,
+		Thresholds:             copyThresholds(DefaultThresholds),
+		NowcastWindow:          DefaultNowcastWindow,
+		PrecipitationThreshold: DefaultPrecipitationThreshold,
+		MinBackoff:             DefaultMinBackoff,
+		MaxBackoff:             DefaultMaxBackoff,
+		client:                 c,
+		location:               location,
+	}
+}
+
+// Watch starts polling and returns the channel events are sent on. The
+// first poll only records the forecast, and changes are reported from the
+// second poll on. Polling stops and the channel is closed once ctx is
+// canceled; events are only sent while they are being received, so a slow
+// receiver delays the next poll rather than missing events.
+func (w *Watcher) Watch(ctx context.Context) <-chan Event {
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		w.reported = map[revisionKey]float64{}
+		interval := w.Interval
+		if interval <= 0 {
+			interval = DefaultInterval
+		}
+		minBackoff, maxBackoff := w.MinBackoff, w.MaxBackoff
+		if minBackoff <= 0 {
+			minBackoff = DefaultMinBackoff
+		}
+		if maxBackoff <= 0 {
+			maxBackoff = DefaultMaxBackoff
+		}
+		if maxBackoff < minBackoff {
+			maxBackoff = minBackoff
+		}
+		backoff := time.Duration(0)
+		for {
+			now := time.Now()
+			found, err := w.poll(ctx, now)
+			if ctx.Err() != nil {
+				return
+			}
+
+			wait := interval
+			if err != nil {
+				backoff = nextBackoff(backoff, minBackoff, maxBackoff)
+				wait = backoff
+				found = []Event{&PollError{detected: detected(now), Err: err, RetryIn: wait}}
+			} else {
+				backoff = 0
+			}
+			for _, e := range found {
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+	}()
+	return events
+}
+
+func nextBackoff(prev, min, max time.Duration) time.Duration {
+	if prev == 0 {
+		return min
+	}
+	prev *= 2
+	if prev > max {
+		return max
+	}
+	return prev
+}
+
+// copyThresholds returns a copy of thresholds, so that changing a Watcher's
+// Thresholds doesn't change DefaultThresholds.
+func copyThresholds(thresholds map[string]float64) map[string]float64 {
+	c := make(map[string]float64, len(thresholds))
+	for field, t := range thresholds {
+		c[field] = t
+	}
+	return c
+}
+
+func (w *Watcher) timestep() string {
+	if w.Timestep == "" {
+		return DefaultTimestep
+	}
+	return w.Timestep
+}
+
+func (w *Watcher) horizon() time.Duration {
+	if w.Horizon <= 0 {
+		return DefaultHorizon
+	}
+	return w.Horizon
+}
+
+func (w *Watcher) thresholds() map[string]float64 {
+	if len(w.Thresholds) == 0 {
+		return DefaultThresholds
+	}
+	return w.Thresholds
+}
+
+func (w *Watcher) nowcastWindow() time.Duration {
+	if w.NowcastWindow <= 0 {
+		return DefaultNowcastWindow
+	}
+	return w.NowcastWindow
+}
+
+func (w *Watcher) precipitationThreshold() float64 {
+	if w.PrecipitationThreshold <= 0 {
+		return DefaultPrecipitationThreshold
+	}
+	return w.PrecipitationThreshold
+}
+
+// poll fetches the forecast and nowcast and returns the events they cause.
+func (w *Watcher) poll(ctx context.Context, now time.Time) ([]Event, error) {
+	nowcast, err := w.fetch(ctx, []string{"current", "5m"}, []string{"weatherCode", "precipitationIntensity"}, now, w.nowcastWindow())
+	if err != nil {
+		return nil, errors.WithMessage(err, "fetching nowcast")
+	}
+	thresholds := w.thresholds()
+	fields := make([]string, 0, len(thresholds))
+	for field := range thresholds {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	forecast, err := w.fetch(ctx, []string{w.timestep()}, fields, now, w.horizon())
+	if err != nil {
+		return nil, errors.WithMessage(err, "fetching forecast")
+	}
+
+	var events []Event
+	for _, t := range nowcast {
+		switch t.Timestep {
+		case "current":
+			if len(t.Intervals) > 0 {
+				events = append(events, w.conditions(now, t.Intervals[0].Values)...)
+			}
+		case "5m":
+			events = append(events, w.precipitation(now, t.Intervals)...)
+		}
+	}
+	for _, t := range forecast {
+		if t.Timestep == w.timestep() {
+			events = append(events, w.revisions(now, t.Intervals)...)
+		}
+	}
+	return events, nil
+}
+
+func (w *Watcher) fetch(ctx context.Context, timesteps, fields []string, now time.Time, ahead time.Duration) ([]climacell.Timeline, error) {
+	opts := &climacell.TimelineListOptions{
+		Location:   w.location,
+		LocationID: w.LocationID,
+		Fields:     fields,
+		TimeSteps:  timesteps,
+		StartTime:  now.Format(time.RFC3339),
+		EndTime:    now.Add(ahead).Format(time.RFC3339),
+	}
+	list, err := w.client.GetTimelines(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return list.Timelines, nil
+}
+
+func (w *Watcher) conditions(now time.Time, v climacell.Values) []Event {
+	code, ok := v.Int("weatherCode")
+	if !ok {
+		return nil
+	}
+	prev := w.code
+	w.code = code
+	if prev == 0 || prev/1000 == code/1000 {
+		return nil
+	}
+	return []Event{&ConditionsChange{detected: detected(now), Previous: prev, Current: code}}
+}
+
+func (w *Watcher) precipitation(now time.Time, intervals []climacell.Interval) []Event {
+	if len(intervals) == 0 {
+		return nil
+	}
+	threshold := w.precipitationThreshold()
+	wet := func(iv climacell.Interval) (float64, bool) {
+		n, ok := iv.Values.Float("precipitationIntensity")
+		return n, ok && n >= threshold
+	}
+
+	state := precipNone
+	var event Event
+	if _, raining := wet(intervals[0]); raining {
+		for _, iv := range intervals[1:] {
+			if _, ok := wet(iv); !ok {
+				state = precipStopping
+				event = &PrecipitationStop{detected: detected(now), At: iv.StartTime}
+				break
+			}
+		}
+	} else {
+		for _, iv := range intervals[1:] {
+			if n, ok := wet(iv); ok {
+				state = precipStarting
+				event = &PrecipitationStart{detected: detected(now), At: iv.StartTime, Intensity: n}
+				break
+			}
+		}
+	}
+
+	prev := w.precip
+	w.precip = state
+	if event == nil || prev == state || prev == precipUnknown {
+		return nil
+	}
+	return []Event{event}
+}
+
+func (w *Watcher) revisions(now time.Time, intervals []climacell.Interval) []Event {
+	var events []Event
+	seen := map[revisionKey]bool{}
+	for _, iv := range intervals {
+		for field, threshold := range w.thresholds() {
+			v, ok := iv.Values.Float(field)
+			if !ok {
+				continue
+			}
+			key := revisionKey{field: field, time: iv.StartTime}
+			seen[key] = true
+			prev, ok := w.reported[key]
+			if !ok {
+				w.reported[key] = v
+				continue
+			}
+			if math.Abs(v-prev) < threshold {
+				continue
+			}
+			w.reported[key] = v
+			events = append(events, &ForecastRevision{
+				detected: detected(now),
+				Field:    field,
+				Time:     iv.StartTime,
+				Previous: prev,
+				Current:  v,
+			})
+		}
+	}
+
+	// Forget forecast times that have passed out of the forecast.
+	for key := range w.reported {
+		if !seen[key] {
+			delete(w.reported, key)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		a, b := events[i].(*ForecastRevision), events[j].(*ForecastRevision)
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		return a.Field < b.Field
+	})
+	return events
+}
@@ -0,0 +1,148 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+var base = time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC)
+
+// poll is the data a fakeClient returns for one poll.
+type poll struct {
+	code        int
+	precip      []float64
+	temperature float64
+	err         error
+}
+
+type fakeClient struct {
+	polls  []poll
+	n      int
+	cancel context.CancelFunc
+}
+
+func (c *fakeClient) GetTimelines(ctx context.Context, opts *climacell.TimelineListOptions) (*climacell.TimelineList, error) {
+	if opts.TimeSteps[0] == "current" {
+		c.n++
+	}
+	if c.n > len(c.polls) {
+		c.cancel()
+		return nil, ctx.Err()
+	}
+	p := c.polls[c.n-1]
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	if opts.TimeSteps[0] != "current" {
+		return &climacell.TimelineList{Timelines: []climacell.Timeline{{
+			Timestep: "1h",
+			Intervals: []climacell.Interval{
+				{StartTime: base.Add(time.Hour), Values: climacell.Values{"temperature": p.temperature}},
+			},
+		}}}, nil
+	}
+
+	nowcast := climacell.Timeline{Timestep: "5m"}
+	for i, v := range p.precip {
+		nowcast.Intervals = append(nowcast.Intervals, climacell.Interval{
+			StartTime: base.Add(time.Duration(i) * 5 * time.Minute),
+			Values:    climacell.Values{"precipitationIntensity": v},
+		})
+	}
+	return &climacell.TimelineList{Timelines: []climacell.Timeline{
+		{Timestep: "current", Intervals: []climacell.Interval{{StartTime: base, Values: climacell.Values{"weatherCode": float64(p.code)}}}},
+		nowcast,
+	}}, nil
+}
+
+func TestWatcher(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c := &fakeClient{cancel: cancel, polls: []poll{
+		{code: 1000, precip: []float64{0, 0, 0}, temperature: 10},
+		// within category and threshold: nothing to report
+		{code: 1001, precip: []float64{0, 0, 1.2}, temperature: 11},
+		// rain still expected, so no repeat of the start event
+		{code: 4001, precip: []float64{0, 1.5, 2}, temperature: 12.5},
+		{err: errors.New("connection reset")},
+		{err: errors.New("connection reset")},
+		{code: 4001, precip: []float64{0, 1.5, 2}, temperature: 12.5},
+		// raining, and expected to stop
+		{code: 4001, precip: []float64{2, 0.5, 0}, temperature: 12},
+	}}
+
+	w := New(c, climacell.Geometry{Type: "Point", Coordinates: []float64{-78.6, 35.8}})
+	w.Interval = time.Millisecond
+	w.MinBackoff = time.Millisecond
+	w.MaxBackoff = 3 * time.Millisecond
+	w.Thresholds = map[string]float64{"temperature": 2}
+
+	var events []Event
+	for e := range w.Watch(ctx) {
+		events = append(events, e)
+	}
+
+	require.Len(t, events, 6)
+
+	start, ok := events[0].(*PrecipitationStart)
+	require.True(t, ok, "got %v", events[0])
+	assert.Equal(t, base.Add(10*time.Minute), start.At)
+	assert.Equal(t, 1.2, start.Intensity)
+
+	change, ok := events[1].(*ConditionsChange)
+	require.True(t, ok, "got %v", events[1])
+	assert.Equal(t, 1001, change.Previous)
+	assert.Equal(t, 4001, change.Current)
+	assert.Equal(t, "conditions changed from Cloudy to Rain", change.String())
+
+	revision, ok := events[2].(*ForecastRevision)
+	require.True(t, ok, "got %v", events[2])
+	assert.Equal(t, "temperature", revision.Field)
+	assert.Equal(t, base.Add(time.Hour), revision.Time)
+	assert.Equal(t, 10.0, revision.Previous)
+	assert.Equal(t, 12.5, revision.Current)
+
+	pollErr, ok := events[3].(*PollError)
+	require.True(t, ok, "got %v", events[3])
+	assert.Equal(t, time.Millisecond, pollErr.RetryIn)
+	assert.Contains(t, pollErr.Err.Error(), "connection reset")
+	assert.Equal(t, 2*time.Millisecond, events[4].(*PollError).RetryIn)
+
+	stop, ok := events[5].(*PrecipitationStop)
+	require.True(t, ok, "got %v", events[5])
+	assert.Equal(t, base.Add(10*time.Minute), stop.At)
+}
+
+func TestWatcherDefaults(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c := &fakeClient{cancel: cancel, polls: []poll{{err: errors.New("connection reset")}}}
+	w := New(c, climacell.Geometry{Type: "Point", Coordinates: []float64{-78.6, 35.8}})
+	w.Thresholds["temperature"] = 0.5
+	w.MinBackoff, w.MaxBackoff = 0, 0
+	assert.Equal(t, 2.0, DefaultThresholds["temperature"])
+
+	// the failed poll is retried after the default backoff, not right away
+	e := <-w.Watch(ctx)
+	pollErr, ok := e.(*PollError)
+	require.True(t, ok, "got %v", e)
+	assert.Equal(t, DefaultMinBackoff, pollErr.RetryIn)
+	cancel()
+
+	w = &Watcher{}
+	assert.Equal(t, DefaultTimestep, w.timestep())
+	assert.Equal(t, DefaultHorizon, w.horizon())
+	assert.Equal(t, DefaultThresholds, w.thresholds())
+	assert.Equal(t, DefaultNowcastWindow, w.nowcastWindow())
+	assert.Equal(t, DefaultPrecipitationThreshold, w.precipitationThreshold())
+}
@@ -2,10 +2,14 @@ package climacell
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/url"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 type BaseResponseType struct {
@@ -214,83 +218,262 @@ type HistoricalStation struct {
 	WeatherType
 }
 
-// [TODO] If it can be determined that enum values like moon phase and
-// precipitaiton type don't change their deserialization without the version
-// number also being bumped up, it would be nice to have enums for these values
-// instead of using StringValues.
+// SampleInterval converts a weather sample returned from one of ClientV3's
+// endpoints, like a HourlyForecast, RealTime or ForecastDay, to an Interval so
+// that v3 samples can be handled the same way as the intervals of a v4
+// Timeline. The interval starts at the sample's observation time, and its
+// Values are keyed by the names of the v4 fields the sample's fields
+// correspond to, like "temperature" for "temp"; fields that were not
+// requested or had a null value are left out, and fields without a v4
+// equivalent keep their JSON names. The values of enum fields, like
+// "mostly_clear", are converted to their v4 codes. The minimums and maximums
+// of a ForecastDay are keyed with "Min" and "Max" suffixes, like
+// "temperatureMax".
+func SampleInterval(sample interface{}) (Interval, error) {
+	b, err := json.Marshal(sample)
+	if err != nil {
+		return Interval{}, errors.WithMessage(err, "serializing weather sample")
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return Interval{}, errors.WithMessage(err, "deserializing weather sample fields")
+	}
 
-// StringValue is a field on a Weather returned from the ClimaCell API that is
-// of type string.
-type StringValue struct {
-	// Value indicates the string value for this field on a Weather.
-	Value *string `json:"value"`
-}
+	iv := Interval{Values: Values{}}
+	for name, v := range raw {
+		switch name {
+		case "lat", "lon", "location_id":
+			continue
+		case "observation_time":
+			var d DateValue
+			if err := json.Unmarshal(v, &d); err != nil {
+				return Interval{}, errors.WithMessage(err, "deserializing observation time")
+			}
+			iv.StartTime = d.Value
+			continue
+		}
+
+		if v4, ok := v3Fields[name]; ok {
+			name = v4
+		}
+
+		if len(v) > 0 && v[0] == '[' {
+			var minMax ForecastMinAndMax
+			if err := json.Unmarshal(v, &minMax); err != nil {
+				return Interval{}, errors.WithMessagef(err, "deserializing %s", name)
+			}
+			if min, ok := minMax.Min().GetValue(); ok {
+				iv.Values[name+"Min"] = min
+			}
+			if max, ok := minMax.Max().GetValue(); ok {
+				iv.Values[name+"Max"] = max
+			}
+			continue
+		}
 
-// GetValue returns this  struct's value and a true "ok" if present, or returns
-// a blank string and false "ok" if either this StringValue is nil, or its
-// Value is nil.
-func (s *StringValue) GetValue() (val string, ok bool) {
-	if s == nil || s.Value == nil {
-		return "", false
+		var field struct {
+			Value interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(v, &field); err != nil {
+			return Interval{}, errors.WithMessagef(err, "deserializing %s", name)
+		}
+		if str, ok := field.Value.(string); ok {
+			if f, ok := LookupField(name); ok && f.Kind == FieldEnum {
+				if code, ok := enumCode(f, str); ok {
+					field.Value = code
+				}
+			}
+		}
+		if field.Value != nil {
+			iv.Values[name] = field.Value
+		}
 	}
-	return *s.Value, true
+	return iv, nil
 }
 
-// FloatValue is a field on a Weather returned from the ClimaCell API that is a
-// floating-point number.
-type FloatValue struct {
-	// Value indicates the float value for this field on a Weather.
-	Value *float64 `json:"value"`
-	// Units, if present, indicates the unit of measure for this value.
-	Units string `json:"units,omitempty"`
+// v3Fields maps the JSON names of v3 sample fields to the v4 fields they
+// correspond to. Fields whose names are the same in both versions, like
+// "humidity", aren't listed.
+var v3Fields = map[string]string{
+	"temp":                        "temperature",
+	"feels_like":                  "temperatureApparent",
+	"dewpoint":                    "dewPoint",
+	"wind_speed":                  "windSpeed",
+	"wind_direction":              "windDirection",
+	"wind_gust":                   "windGust",
+	"baro_pressure":               "pressureSurfaceLevel",
+	"precipitation":               "precipitationIntensity",
+	"precipitation_type":          "precipitationType",
+	"precipitation_probability":   "precipitationProbability",
+	"precipitation_accumulation":  "precipitationAccumulation",
+	"sunrise":                     "sunriseTime",
+	"sunset":                      "sunsetTime",
+	"cloud_cover":                 "cloudCover",
+	"cloud_base":                  "cloudBase",
+	"cloud_ceiling":               "cloudCeiling",
+	"surface_shortwave_radiation": "solarGHI",
+	"moon_phase":                  "moonPhase",
+	"weather_code":                "weatherCode",
+	"fire_index":                  "fireIndex",
+	"pm25":                        "particulateMatter25",
+	"pm10":                        "particulateMatter10",
+	"o3":                          "pollutantO3",
+	"no2":                         "pollutantNO2",
+	"co":                          "pollutantCO",
+	"so2":                         "pollutantSO2",
+	"epa_aqi":                     "epaIndex",
+	"epa_primary_pollutant":       "epaPrimaryPollutant",
+	"epa_health_concern":          "epaHealthConcern",
+	"china_aqi":                   "mepIndex",
+	"china_primary_pollutant":     "mepPrimaryPollutant",
+	"china_health_concern":        "mepHealthConcern",
 }
 
-// GetValue returns this struct's value and a true "ok" if present, or returns
-// 0.0 and false "ok" if either this FloatValue is nil, or its Value is nil.
-func (f *FloatValue) GetValue() (val float64, ok bool) {
-	if f == nil || f.Value == nil {
-		return 0.0, false
-	}
-	return *f.Value, true
+var v3PollutantCodes = map[string]int{"pm25": 0, "pm10": 1, "o3": 2, "no2": 3, "co": 4, "so2": 5}
+
+// v3EnumCodes maps the values of v3 enum fields to the codes of the v4
+// fields they correspond to. Values that are the same as a v4 field's
+// label, like "Good" for the health concern fields, aren't listed.
+var v3EnumCodes = map[string]map[string]int{
+	"weatherCode": {
+		"clear": 1000, "cloudy": 1001, "mostly_clear": 1100,
+		"partly_cloudy": 1101, "mostly_cloudy": 1102, "fog": 2000,
+		"fog_light": 2100, "drizzle": 4000, "rain": 4001,
+		"rain_light": 4200, "rain_heavy": 4201, "snow": 5000,
+		"flurries": 5001, "snow_light": 5100, "snow_heavy": 5101,
+		"freezing_drizzle": 6000, "freezing_rain": 6001,
+		"freezing_rain_light": 6200, "freezing_rain_heavy": 6201,
+		"ice_pellets": 7000, "ice_pellets_heavy": 7101,
+		"ice_pellets_light": 7102, "tstorm": 8000,
+	},
+	"moonPhase": {
+		"new_moon": 0, "waxing_crescent": 1, "first_quarter": 2,
+		"waxing_gibbous": 3, "full": 4, "waning_gibbous": 5,
+		"third_quarter": 6, "waning_crescent": 7,
+	},
+	"precipitationType": {
+		"none": 0, "rain": 1, "snow": 2, "freezing_rain": 3, "ice_pellets": 4,
+	},
+	"epaPrimaryPollutant": v3PollutantCodes,
+	"mepPrimaryPollutant": v3PollutantCodes,
 }
 
-// IntValue is a field on a Weather returned from the ClimaCell API that is an
-// integer.
-type IntValue struct {
-	// Value indicates the integer value for this field on a Weather.
-	Value *int `json:"value"`
-	// Units, if present, indicates the unit of measure for this value.
-	Units string `json:"units,omitempty"`
+// IntervalSample is the inverse of SampleInterval: it sets the fields of
+// sample, a pointer to a v3 sample like a *HourlyForecast or *ForecastDay,
+// from an interval's start time and values. Each field of the sample is set
+// from the value of its v4 field, or for the minimums and maximums of a
+// ForecastDay from the values with "Min" and "Max" suffixes. Enum codes are
+// converted back to their v3 values.
+func IntervalSample(iv Interval, sample interface{}) error {
+	t := reflect.TypeOf(sample)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("expected a pointer to a weather sample, got %T", sample)
+	}
+
+	raw := map[string]interface{}{
+		"observation_time": map[string]interface{}{"value": iv.StartTime.Format(time.RFC3339)},
+	}
+	for key, minMax := range sampleFields(t.Elem()) {
+		name := key
+		if v4, ok := v3Fields[key]; ok {
+			name = v4
+		}
+		if !minMax {
+			v, ok := iv.Values[name]
+			if !ok {
+				continue
+			}
+			if f, ok := LookupField(name); ok && f.Kind == FieldEnum {
+				v = enumValue(f, v)
+			}
+			raw[key] = map[string]interface{}{"value": v}
+			continue
+		}
+
+		var values []interface{}
+		for _, suffix := range []string{"Min", "Max"} {
+			if v, ok := iv.Values[name+suffix]; ok {
+				values = append(values, map[string]interface{}{
+					"observation_time":      iv.StartTime,
+					strings.ToLower(suffix): map[string]interface{}{"value": v},
+				})
+			}
+		}
+		if values != nil {
+			raw[key] = values
+		}
+	}
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return errors.WithMessage(err, "serializing weather sample fields")
+	}
+	return errors.WithMessage(json.Unmarshal(b, sample), "deserializing weather sample")
 }
 
-// GetValue returns this struct's value and a true "ok" if present, or returns
-// 0 and false "ok" if either this IntValue is nil, or its Value is nil.
-func (i *IntValue) GetValue() (val int, ok bool) {
-	if i == nil || i.Value == nil {
-		return 0, false
+// sampleFields returns the JSON names of the value fields of a v3 sample
+// type, including those of its embedded types, and whether each is a
+// ForecastMinAndMax.
+func sampleFields(t reflect.Type) map[string]bool {
+	fields := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			for key, minMax := range sampleFields(f.Type) {
+				fields[key] = minMax
+			}
+			continue
+		}
+		key := strings.Split(f.Tag.Get("json"), ",")[0]
+		switch key {
+		case "", "-", "lat", "lon", "location_id", "observation_time":
+			continue
+		}
+		fields[key] = f.Type == reflect.TypeOf(&ForecastMinAndMax{})
 	}
-	return *i.Value, true
+	return fields
 }
 
-// TimeValue is a field on a Weather returned from the ClimaCell API that is a
-// timestamp.
-type TimeValue struct {
-	// Value indicates the timestamp value for this field on a Weather.
-	Value *time.Time `json:"value"`
-	// Units, if present, indicates the unit of measure for this value.
-	Units string `json:"units,omitempty"`
+// enumValue returns the v3 value of an enum field's code, or v itself if it
+// isn't a known code.
+func enumValue(f Field, v interface{}) interface{} {
+	code, ok := Values{f.Name: v}.Int(f.Name)
+	if !ok {
+		return v
+	}
+	for value, c := range v3EnumCodes[f.Name] {
+		if c == code {
+			return value
+		}
+	}
+	if label, ok := f.Label(code); ok {
+		return label
+	}
+	return strconv.Itoa(code)
 }
 
-// GetValue returns this struct's value and a true "ok" if present, or returns
-// a blank string and false "ok" if either this TimeValue is nil, or its Value
-// is nil.
-func (t *TimeValue) GetValue() (val time.Time, ok bool) {
-	if t == nil || t.Value == nil {
-		return time.Time{}, false
+// enumCode returns the code of an enum field for a v3 value, which is
+// either a v3 name for the code, the code's label or the code itself.
+func enumCode(f Field, value string) (int, bool) {
+	if code, ok := v3EnumCodes[f.Name][value]; ok {
+		return code, true
+	}
+	if code, err := strconv.Atoi(value); err == nil {
+		return code, true
 	}
-	return *t.Value, true
+	for code, label := range f.Labels {
+		if strings.EqualFold(label, value) {
+			return code, true
+		}
+	}
+	return 0, false
 }
 
+// [TODO] If it can be determined that enum values like moon phase and
+// precipitaiton type don't change their deserialization without the version
+// number also being bumped up, it would be nice to have enums for these values
+// instead of using StringValues.
+
 // DateValue is a timestsamp value that can be either in RFC3339 layout, or in
 // YYYY-MM-DD layout. Unlike TimeValue, its value should always be non-nil and
 // non-zero, as it is used as the timestamps for forecast data samples.
@@ -335,10 +518,11 @@ func (t *timeOrDate) UnmarshalJSON(b []byte) error {
 
 	// Try parsing as a date
 	tm, err := time.Parse("2006-01-02", timeStr)
-	if err == nil {
-		*t = timeOrDate(tm)
+	if err != nil {
+		return fmt.Errorf("%q is neither an RFC3339 timestamp nor a YYYY-MM-DD date", timeStr)
 	}
-	return err
+	*t = timeOrDate(tm)
+	return nil
 }
 
 //
@@ -423,6 +607,12 @@ type LatLon struct {
 	Lon float64 `json:"lon"`
 }
 
+// Key returns a string identifying these coordinates, formatted as
+// "lat,lon".
+func (l LatLon) Key() string {
+	return strconv.FormatFloat(l.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(l.Lon, 'f', -1, 64)
+}
+
 // LocationQueryParams implements the Location interface.
 func (l LatLon) LocationQueryParams() url.Values {
 	return url.Values{
@@ -16,26 +16,27 @@ var everyWeatherField = []byte(`
 {
   "lat":                         91.128,
   "lon":                         -181.250,
-  "temp":                        {"value": 10,       "units": "C"},
-  "feels_like":                  {"value": 10,       "units": "C"},
-  "dewpoint":                    {"value": -0.50,    "units": "C"},
-  "wind_speed":                  {"value": 5,        "units": "beaufort"},
-  "wind_gust":                   {"value": 10,       "units": "beaufort"},
-  "baro_pressure":               {"value": 20.71,    "units": "inHg"},
+  "temperature":                 {"value": 10,       "units": "C"},
+  "temperatureApparent":         {"value": 10,       "units": "C"},
+  "dewPoint":                    {"value": -0.50,    "units": "C"},
+  "windSpeed":                   {"value": 5,        "units": "beaufort"},
+  "windGust":                    {"value": 10,       "units": "beaufort"},
+  "pressureSurfaceLevel":        {"value": 20.71,    "units": "inHg"},
+  "pressureSeaLevel":            {"value": 29.92,    "units": "inHg"},
   "visibility":                  {"value": 6.21371,  "units": "mi"},
   "humidity":                    {"value": 20.71,    "units": "%"},
-  "wind_direction":              {"value": 250.25,   "units": "degrees"},
-  "precipitation":               {"value": 10,       "units": "mm/hr"},
-  "precipitation_type":          {"value": "rain"},
-  "cloud_cover":                 {"value": 12.8,     "units": "%"},
-  "cloud_ceiling":               {"value": 6000,     "units": "ft"},
-  "cloud_base":                  {"value": 5000,     "units": "ft"},
-  "surface_shortwave_radiation": {"value": 250.1123, "units": "w/sqm"},
+  "windDirection":               {"value": 250.25,   "units": "degrees"},
+  "precipitationIntensity":      {"value": 10,       "units": "mm/hr"},
+  "precipitationType":           {"value": "rain"},
+  "cloudCover":                  {"value": 12.8,     "units": "%"},
+  "cloudCeiling":                {"value": 6000,     "units": "ft"},
+  "cloudBase":                   {"value": 5000,     "units": "ft"},
+  "solarGHI":                    {"value": 250.1123, "units": "w/sqm"},
   "fire_index":                  {"value": 3.6},
-  "sunrise":                     {"value": "2020-04-12T12:34:56.789Z"},
-  "sunset":                      {"value": "2020-04-12T23:45:56.789Z"},
-  "moon_phase":                  {"value": "waning_gibbous"},
-  "weather_code":                {"value": "mostly_clear"},
+  "sunriseTime":                 {"value": "2020-04-12T12:34:56.789Z"},
+  "sunsetTime":                  {"value": "2020-04-12T23:45:56.789Z"},
+  "moonPhase":                   {"value": "waning_gibbous"},
+  "weatherCode":                 {"value": "mostly_clear"},
   "road_risk":                   {"value": "low_risk"},
   "road_risk_score":             {"value": "Low Risk"},
   "road_risk_confidence":        {"value": 100 },
@@ -82,9 +83,12 @@ func TestDeserializeWeatherWithAllFields(t *testing.T) {
 	if windGust, ok := w.WindGust.GetValue(); assert.True(t, ok) {
 		assert.EqualValues(t, 10, windGust)
 	}
-	if baroPressure, ok := w.BaroPressure.GetValue(); assert.True(t, ok) {
+	if baroPressure, ok := w.BaroSurfacePressure.GetValue(); assert.True(t, ok) {
 		assert.EqualValues(t, 20.71, baroPressure)
 	}
+	if baroPressure, ok := w.BaroSeaPressure.GetValue(); assert.True(t, ok) {
+		assert.EqualValues(t, 29.92, baroPressure)
+	}
 	if visibility, ok := w.Visibility.GetValue(); assert.True(t, ok) {
 		assert.EqualValues(t, 6.21371, visibility)
 	}
@@ -213,7 +217,8 @@ func TestDeserializeWeatherWithAllFieldsAbsent(t *testing.T) {
 	assert.Nil(t, w.DewPoint)
 	assert.Nil(t, w.WindSpeed)
 	assert.Nil(t, w.WindGust)
-	assert.Nil(t, w.BaroPressure)
+	assert.Nil(t, w.BaroSurfacePressure)
+	assert.Nil(t, w.BaroSeaPressure)
 	assert.Nil(t, w.Visibility)
 	assert.Nil(t, w.Humidity)
 	assert.Nil(t, w.WindDirection)
@@ -253,26 +258,27 @@ var everyWeatherFieldNull = []byte(`
 {
   "lat":                         91.128,
   "lon":                         -181.250,
-  "temp":                        {"value": null, "units": "C"},
-  "feels_like":                  {"value": null, "units": "C"},
-  "dewpoint":                    {"value": null, "units": "C"},
-  "wind_speed":                  {"value": null, "units": "beaufort"},
-  "wind_gust":                   {"value": null, "units": "beaufort"},
-  "baro_pressure":               {"value": null, "units": "inHg"},
+  "temperature":                 {"value": null, "units": "C"},
+  "temperatureApparent":         {"value": null, "units": "C"},
+  "dewPoint":                    {"value": null, "units": "C"},
+  "windSpeed":                   {"value": null, "units": "beaufort"},
+  "windGust":                    {"value": null, "units": "beaufort"},
+  "pressureSurfaceLevel":        {"value": null, "units": "inHg"},
+  "pressureSeaLevel":            {"value": null, "units": "inHg"},
   "visibility":                  {"value": null, "units": "mi"},
   "humidity":                    {"value": null, "units": "%"},
-  "wind_direction":              {"value": null, "units": "degrees"},
-  "precipitation":               {"value": null, "units": "mm/hr"},
-  "precipitation_type":          {"value": null},
-  "cloud_cover":                 {"value": null, "units": "%"},
-  "cloud_ceiling":               {"value": null, "units": "ft"},
-  "cloud_base":                  {"value": null, "units": "ft"},
-  "surface_shortwave_radiation": {"value": null, "units": "w/sqm"},
+  "windDirection":               {"value": null, "units": "degrees"},
+  "precipitationIntensity":      {"value": null, "units": "mm/hr"},
+  "precipitationType":           {"value": null},
+  "cloudCover":                  {"value": null, "units": "%"},
+  "cloudCeiling":                {"value": null, "units": "ft"},
+  "cloudBase":                   {"value": null, "units": "ft"},
+  "solarGHI":                    {"value": null, "units": "w/sqm"},
   "fire_index":                  {"value": null},
-  "sunrise":                     {"value": null},
-  "sunset":                      {"value": null},
-  "moon_phase":                  {"value": null},
-  "weather_code":                {"value": null},
+  "sunriseTime":                 {"value": null},
+  "sunsetTime":                  {"value": null},
+  "moonPhase":                   {"value": null},
+  "weatherCode":                 {"value": null},
   "road_risk":                   {"value": null},
   "road_risk_score":             {"value": null},
   "road_risk_confidence":        {"value": null},
@@ -298,7 +304,7 @@ var everyWeatherFieldNull = []byte(`
 // GetValue method, a false ok value is returned.
 func TestDeserializeWeatherWithAllFieldsNull(t *testing.T) {
 	var w HourlyForecast
-	require.NoError(t, json.Unmarshal(minimalWeatherData, &w))
+	require.NoError(t, json.Unmarshal(everyWeatherFieldNull, &w))
 
 	assert.Equal(t, 91.128, w.Lat)
 	assert.Equal(t, -181.250, w.Lon)
@@ -316,8 +322,10 @@ func TestDeserializeWeatherWithAllFieldsNull(t *testing.T) {
 	assert.False(t, ok, "WindSpeed was present")
 	_, ok = w.WindGust.GetValue()
 	assert.False(t, ok, "WindGust was present")
-	_, ok = w.BaroPressure.GetValue()
-	assert.False(t, ok, "BaroPressure was present")
+	_, ok = w.BaroSurfacePressure.GetValue()
+	assert.False(t, ok, "BaroSurfacePressure was present")
+	_, ok = w.BaroSeaPressure.GetValue()
+	assert.False(t, ok, "BaroSeaPressure was present")
 	_, ok = w.Visibility.GetValue()
 	assert.False(t, ok, "Visibility was present")
 	_, ok = w.Humidity.GetValue()
@@ -0,0 +1,209 @@
+// Package webhook receives the webhooks ClimaCell calls when alerts
+// configured with the Alerts API are triggered.
+//
+// A Handler validates each request, verifies its signature when a secret is
+// configured, parses the payload into a Notification and passes it to a
+// Callback:
+//
+//	h := webhook.NewHandler(webhook.CallbackFunc(func(ctx context.Context, n *webhook.Notification) error {
+//		log.Printf("%s: %s at %s", n.Type, n.Alert.Name, n.Location.Name)
+//		return nil
+//	}))
+//	h.Secret = os.Getenv("CLIMACELL_WEBHOOK_SECRET")
+//	http.Handle("/climacell", h)
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// SignatureHeader is the header requests are signed in, with the hex
+// HMAC-SHA256 of the body prefixed with "sha256=".
+const SignatureHeader = "X-Climacell-Signature"
+
+// DefaultMaxBodySize is the size of the largest request body a Handler
+// accepts.
+const DefaultMaxBodySize = 1 << 20
+
+// EventType is the kind of alert event a notification is for.
+type EventType string
+
+// Alert event types.
+const (
+	// AlertTriggered is sent when an alert's insight starts matching the
+	// forecast at a location.
+	AlertTriggered EventType = "alert.triggered"
+	// AlertUpdated is sent when the forecast for a triggered alert
+	// changes.
+	AlertUpdated EventType = "alert.updated"
+	// AlertEnded is sent when an alert's insight stops matching.
+	AlertEnded EventType = "alert.ended"
+)
+
+// Notification is the payload of an alert webhook.
+type Notification struct {
+	// Type is the kind of event.
+	Type EventType `json:"type"`
+	// Alert is the alert that was triggered.
+	Alert climacell.Alert `json:"alert"`
+	// Location is the location the alert was triggered at.
+	Location climacell.SavedLocation `json:"location"`
+	// Insight is the insight that matched.
+	Insight Insight `json:"insight"`
+	// Intervals are the forecast intervals that matched the insight,
+	// with the values of the insight's fields.
+	Intervals []climacell.Interval `json:"intervals"`
+}
+
+// Insight describes the triggered insight of an alert.
+type Insight struct {
+	// ID is the ID of the insight, or the name of a predefined insight
+	// like "fires" or "floods".
+	ID string `json:"id"`
+	// Name is the insight's name.
+	Name string `json:"name"`
+	// Severity is the severity configured for the insight, like "minor"
+	// or "severe".
+	Severity string `json:"severity"`
+	// StartTime and EndTime bound the period the insight matches.
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+}
+
+// Callback handles the notifications received by a Handler.
+type Callback interface {
+	// HandleNotification handles a notification. If it returns an error,
+	// the Handler responds with a 500 status so that the webhook is
+	// retried.
+	HandleNotification(ctx context.Context, n *Notification) error
+}
+
+// CallbackFunc adapts a function to a Callback.
+type CallbackFunc func(ctx context.Context, n *Notification) error
+
+// HandleNotification implements Callback.
+func (f CallbackFunc) HandleNotification(ctx context.Context, n *Notification) error {
+	return f(ctx, n)
+}
+
+// Handler is an http.Handler receiving alert webhooks.
+type Handler struct {
+	// Secret, if set, is the webhook secret requests must be signed with.
+	// Unsigned requests and requests with the wrong signature are
+	// rejected with a 401 status.
+	Secret string
+	// MaxBodySize is the size of the largest request body accepted. It
+	// defaults to DefaultMaxBodySize.
+	MaxBodySize int64
+
+	callback Callback
+}
+
+// NewHandler returns a Handler passing notifications to cb.
+func NewHandler(cb Callback) *Handler {
+	return &Handler{MaxBodySize: DefaultMaxBodySize, callback: cb}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		httpError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	limit := h.MaxBodySize
+	if limit <= 0 {
+		limit = DefaultMaxBodySize
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		httpError(w, http.StatusBadRequest, "reading body: "+err.Error())
+		return
+	}
+	if int64(len(body)) > limit {
+		httpError(w, http.StatusRequestEntityTooLarge, "body too large")
+		return
+	}
+
+	if h.Secret != "" && !Verify([]byte(h.Secret), body, r.Header.Get(SignatureHeader)) {
+		httpError(w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+
+	n, err := Parse(body)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.callback.HandleNotification(r.Context(), n); err != nil {
+		httpError(w, http.StatusInternalServerError, "handling notification: "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func httpError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{code, msg})
+}
+
+// Parse parses and validates the body of an alert webhook, which has the
+// notification in its "data" field, like the responses of the v4 API.
+func Parse(body []byte) (*Notification, error) {
+	var payload struct {
+		Type EventType    `json:"type"`
+		Data Notification `json:"data"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %v", err)
+	}
+	n := &payload.Data
+	if n.Type == "" {
+		n.Type = payload.Type
+	}
+
+	var missing []string
+	if n.Type == "" {
+		missing = append(missing, "type")
+	}
+	if n.Alert.ID == "" {
+		missing = append(missing, "alert.id")
+	}
+	if n.Location.ID == "" && n.Location.Geometry.Type == "" {
+		missing = append(missing, "location")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("invalid payload: missing %s", strings.Join(missing, ", "))
+	}
+	return n, nil
+}
+
+// Sign returns the signature of a webhook body signed with secret, as sent
+// in the SignatureHeader header.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of body signed with
+// secret.
+func Verify(secret, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
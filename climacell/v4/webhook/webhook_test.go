@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const payload = `{
+	"type": "alert.triggered",
+	"data": {
+		"alert": {"id": "6013f5e0b1ca9c0007ed4c7e", "name": "Wind over 20", "insight": "windy", "isActive": true},
+		"location": {"id": "5fbe7c8a4b1e2c0008a2d6b1", "name": "Raleigh", "geometry": {"type": "Point", "coordinates": [-78.6, 35.8]}},
+		"insight": {"id": "windy", "name": "Windy", "severity": "moderate", "startTime": "2020-12-21T06:00:00Z", "endTime": "2020-12-21T09:00:00Z"},
+		"intervals": [
+			{"startTime": "2020-12-21T06:00:00Z", "values": {"windGust": 22.5}},
+			{"startTime": "2020-12-21T07:00:00Z", "values": {"windGust": 24.1}}
+		]
+	}
+}`
+
+func post(h http.Handler, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/climacell", strings.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler(t *testing.T) {
+	var got *Notification
+	h := NewHandler(CallbackFunc(func(ctx context.Context, n *Notification) error {
+		got = n
+		return nil
+	}))
+	h.Secret = "secret"
+
+	rec := post(h, payload, map[string]string{SignatureHeader: Sign([]byte("secret"), []byte(payload))})
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	require.NotNil(t, got)
+	assert.Equal(t, AlertTriggered, got.Type)
+	assert.Equal(t, "Wind over 20", got.Alert.Name)
+	assert.Equal(t, "Raleigh", got.Location.Name)
+	assert.Equal(t, "Point", got.Location.Geometry.Type)
+	assert.Equal(t, "moderate", got.Insight.Severity)
+	assert.Equal(t, time.Date(2020, 12, 21, 9, 0, 0, 0, time.UTC), got.Insight.EndTime)
+	require.Len(t, got.Intervals, 2)
+	gust, ok := got.Intervals[1].Values.Float("windGust")
+	assert.True(t, ok)
+	assert.Equal(t, 24.1, gust)
+}
+
+func TestHandlerRejects(t *testing.T) {
+	called := false
+	h := NewHandler(CallbackFunc(func(ctx context.Context, n *Notification) error {
+		called = true
+		return nil
+	}))
+	h.Secret = "secret"
+	signed := func(body string) map[string]string {
+		return map[string]string{SignatureHeader: Sign([]byte("secret"), []byte(body))}
+	}
+
+	rec := post(h, payload, nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = post(h, payload, map[string]string{SignatureHeader: Sign([]byte("wrong"), []byte(payload))})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = post(h, `{"data": `, signed(`{"data": `))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	body := `{"type": "alert.ended", "data": {"alert": {"name": "no id"}}}`
+	rec = post(h, body, signed(body))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "missing alert.id, location")
+
+	h.MaxBodySize = 10
+	rec = post(h, payload, signed(payload))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	req := httptest.NewRequest("GET", "/climacell", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	assert.False(t, called)
+}
+
+func TestHandlerCallbackError(t *testing.T) {
+	h := NewHandler(CallbackFunc(func(ctx context.Context, n *Notification) error {
+		return errors.New("queue full")
+	}))
+	rec := post(h, payload, nil)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "queue full")
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// parseTime parses a --start or --end flag, which is either an RFC3339
+// timestamp, "now", or a duration relative to now like "+6h" or "-24h".
+func parseTime(s string, now time.Time) (time.Time, error) {
+	switch {
+	case s == "":
+		return time.Time{}, nil
+	case s == "now":
+		return now, nil
+	case strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-"):
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, usageErrorf("invalid relative time %q", s)
+		}
+		return now.Add(d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, usageErrorf("invalid time %q: expected RFC3339, \"now\", or a duration like +6h", s)
+	}
+	return t, nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// timeRange holds the --start and --end flags of a command.
+type timeRange struct{ start, end string }
+
+func (r *timeRange) register(fs *flag.FlagSet, defStart, defEnd string) {
+	fs.StringVar(&r.start, "start", defStart, "start time: RFC3339, \"now\", or relative like -6h")
+	fs.StringVar(&r.end, "end", defEnd, "end time: RFC3339, \"now\", or relative like +48h")
+}
+
+func (r timeRange) parse(now time.Time) (start, end time.Time, err error) {
+	if start, err = parseTime(r.start, now); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if end, err = parseTime(r.end, now); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, end, nil
+}
+
+func (a *app) timeline(args []string) error {
+	fs := a.flags("timeline")
+	fields := fs.String("fields", "temperature,weatherCode", "comma-separated fields to request")
+	timesteps := fs.String("timesteps", "1h", "comma-separated timesteps: current, 1m, 5m, 15m, 30m, 1h, 1d")
+	units := fs.String("units", "", "unit system: metric or imperial (default from config, else metric)")
+	var tr timeRange
+	tr.register(fs, "", "")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	locs, err := locationArgs(fs)
+	if err != nil {
+		return err
+	}
+	start, end, err := tr.parse(time.Now())
+	if err != nil {
+		return err
+	}
+	c, err := a.clientV4()
+	if err != nil {
+		return err
+	}
+	if *units == "" {
+		*units = a.cfg.Units
+	}
+
+	var rows []row
+	for _, loc := range locs {
+		opts := &climacell.TimelineListOptions{
+			Fields:    splitList(*fields),
+			TimeSteps: splitList(*timesteps),
+			Units:     *units,
+		}
+		if !start.IsZero() {
+			opts.StartTime = start.Format(time.RFC3339)
+		}
+		if !end.IsZero() {
+			opts.EndTime = end.Format(time.RFC3339)
+		}
+		loc.applyTo(opts)
+
+		list, err := c.GetTimelines(context.Background(), opts)
+		if err != nil {
+			return fmt.Errorf("%s: %v", loc.name, err)
+		}
+		rows = append(rows, timelineRows(loc.name, list)...)
+	}
+	return writeRows(a.stdout, a.format, rows)
+}
+
+// v3Flags holds the flags shared by the commands for v3 endpoints.
+type v3Flags struct {
+	fields string
+	units  string
+	tr     timeRange
+}
+
+func (a *app) v3Command(name string, args []string, defFields, defStart, defEnd string, extra func(*flag.FlagSet)) (*v3Flags, []location, error) {
+	fs := a.flags(name)
+	f := &v3Flags{}
+	fs.StringVar(&f.fields, "fields", defFields, "comma-separated fields to request")
+	fs.StringVar(&f.units, "units", "", "unit system: metric or imperial (default from config, else metric)")
+	if defStart != "" || defEnd != "" {
+		f.tr.register(fs, defStart, defEnd)
+	}
+	if extra != nil {
+		extra(fs)
+	}
+	if err := a.parse(fs, args); err != nil {
+		return nil, nil, err
+	}
+	locs, err := locationArgs(fs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if f.units == "" {
+		f.units = a.cfg.Units
+	}
+	return f, locs, nil
+}
+
+// forecastArgs builds the v3 query parameters for a location.
+func (f *v3Flags) forecastArgs(loc location, now time.Time) (climacell.ForecastArgs, error) {
+	l, err := loc.v3()
+	if err != nil {
+		return climacell.ForecastArgs{}, err
+	}
+	start, end, err := f.tr.parse(now)
+	if err != nil {
+		return climacell.ForecastArgs{}, err
+	}
+
+	args := climacell.ForecastArgs{Location: l, Start: start, End: end, Fields: splitList(f.fields)}
+	switch f.units {
+	case "", "metric", "si":
+		args.UnitSystem = "si"
+	case "imperial", "us":
+		args.UnitSystem = "us"
+	default:
+		return climacell.ForecastArgs{}, usageErrorf("invalid unit system %q", f.units)
+	}
+	return args, nil
+}
+
+// sampleRows converts v3 weather samples to output rows.
+func sampleRows(loc, timestep string, samples []interface{}) ([]row, error) {
+	rows := make([]row, 0, len(samples))
+	for _, s := range samples {
+		iv, err := climacell.SampleInterval(s)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row{Location: loc, Timestep: timestep, Time: iv.StartTime, Values: iv.Values})
+	}
+	return rows, nil
+}
+
+// samples converts the result of a v3 endpoint to a slice of samples.
+func samples[T any](w []T, err error) ([]interface{}, error) {
+	s := make([]interface{}, len(w))
+	for i := range w {
+		s[i] = w[i]
+	}
+	return s, err
+}
+
+// runV3 fetches v3 samples for every location and writes them out.
+func (a *app) runV3(f *v3Flags, locs []location, timestep string, fetch func(*climacell.ClientV3, climacell.ForecastArgs) ([]interface{}, error), setArgs func(*climacell.ForecastArgs)) error {
+	c, err := a.clientV3()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var rows []row
+	for _, loc := range locs {
+		args, err := f.forecastArgs(loc, now)
+		if err != nil {
+			return err
+		}
+		if setArgs != nil {
+			setArgs(&args)
+		}
+		fetched, err := fetch(c, args)
+		if err != nil {
+			return fmt.Errorf("%s: %v", loc.name, err)
+		}
+		r, err := sampleRows(loc.name, timestep, fetched)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, r...)
+	}
+	return writeRows(a.stdout, a.format, rows)
+}
+
+func (a *app) realtime(args []string) error {
+	f, locs, err := a.v3Command("realtime", args, "temperature,humidity,windSpeed,weatherCode", "", "", nil)
+	if err != nil {
+		return err
+	}
+	return a.runV3(f, locs, "current", func(c *climacell.ClientV3, args climacell.ForecastArgs) ([]interface{}, error) {
+		rt, err := c.RealTime(args)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{rt}, nil
+	}, nil)
+}
+
+func (a *app) nowcast(args []string) error {
+	var timestep int
+	f, locs, err := a.v3Command("nowcast", args, "temperature,precipitationIntensity,precipitationType", "now", "+6h", func(fs *flag.FlagSet) {
+		fs.IntVar(&timestep, "timestep", 5, "minutes between samples")
+	})
+	if err != nil {
+		return err
+	}
+	return a.runV3(f, locs, strconv.Itoa(timestep)+"m", func(c *climacell.ClientV3, args climacell.ForecastArgs) ([]interface{}, error) {
+		return samples(c.Nowcast(args))
+	}, func(args *climacell.ForecastArgs) { args.Timestep = timestep })
+}
+
+func (a *app) hourly(args []string) error {
+	f, locs, err := a.v3Command("hourly", args, "temperature,precipitationProbability,weatherCode", "now", "+24h", nil)
+	if err != nil {
+		return err
+	}
+	return a.runV3(f, locs, "1h", func(c *climacell.ClientV3, args climacell.ForecastArgs) ([]interface{}, error) {
+		return samples(c.HourlyForecast(args))
+	}, nil)
+}
+
+func (a *app) daily(args []string) error {
+	f, locs, err := a.v3Command("daily", args, "temp,precipitation_probability,weather_code", "now", "+168h", nil)
+	if err != nil {
+		return err
+	}
+	return a.runV3(f, locs, "1d", func(c *climacell.ClientV3, args climacell.ForecastArgs) ([]interface{}, error) {
+		return samples(c.DailyForecast(args))
+	}, nil)
+}
+
+func (a *app) historical(args []string) error {
+	var source string
+	var timestep int
+	f, locs, err := a.v3Command("historical", args, "temperature,precipitationIntensity", "-6h", "now", func(fs *flag.FlagSet) {
+		fs.StringVar(&source, "source", "station", "data source: station or climacell")
+		fs.IntVar(&timestep, "timestep", 0, "minutes between samples (climacell source only)")
+	})
+	if err != nil {
+		return err
+	}
+
+	switch source {
+	case "station":
+		return a.runV3(f, locs, "1h", func(c *climacell.ClientV3, args climacell.ForecastArgs) ([]interface{}, error) {
+			return samples(c.HistoricalStation(args))
+		}, nil)
+	case "climacell":
+		label := "historical"
+		if timestep > 0 {
+			label = strconv.Itoa(timestep) + "m"
+		}
+		return a.runV3(f, locs, label, func(c *climacell.ClientV3, args climacell.ForecastArgs) ([]interface{}, error) {
+			return samples(c.HistoricalClimaCell(args))
+		}, func(args *climacell.ForecastArgs) { args.Timestep = timestep })
+	}
+	return usageErrorf("invalid source %q, expected station or climacell", source)
+}
+
+func (a *app) locations(args []string) error {
+	fs := a.flags("locations")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	c, err := a.clientV4()
+	if err != nil {
+		return err
+	}
+	locs, err := c.ListLocations(context.Background())
+	if err != nil {
+		return err
+	}
+
+	cells := make([][]string, len(locs))
+	values := make([]interface{}, len(locs))
+	for i, l := range locs {
+		cells[i] = []string{l.ID, l.Name, l.Geometry.Key(), strings.Join(l.Tags, ",")}
+		values[i] = l
+	}
+	return writeRecords(a.stdout, a.format, []string{"id", "name", "geometry", "tags"}, cells, values)
+}
+
+func (a *app) alerts(args []string) error {
+	fs := a.flags("alerts")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	c, err := a.clientV4()
+	if err != nil {
+		return err
+	}
+	alerts, err := c.ListAlerts(context.Background())
+	if err != nil {
+		return err
+	}
+
+	cells := make([][]string, len(alerts))
+	values := make([]interface{}, len(alerts))
+	for i, al := range alerts {
+		cells[i] = []string{al.ID, al.Name, al.Insight, strconv.FormatBool(al.IsActive)}
+		values[i] = al
+	}
+	return writeRecords(a.stdout, a.format, []string{"id", "name", "insight", "active"}, cells, values)
+}
+
+// fieldRecord is how a registry field is written in the json and ndjson
+// formats.
+type fieldRecord struct {
+	Name   string            `json:"name"`
+	Kind   string            `json:"kind"`
+	Units  string            `json:"units,omitempty"`
+	Group  string            `json:"group"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+func (a *app) fields(args []string) error {
+	fs := a.flags("fields")
+	group := fs.String("group", "", "only list fields in this group, like weather or pollen")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+
+	var cells [][]string
+	var values []interface{}
+	for _, name := range climacell.FieldNames() {
+		f, _ := climacell.LookupField(name)
+		if *group != "" && f.Group != *group {
+			continue
+		}
+
+		rec := fieldRecord{Name: f.Name, Kind: f.Kind.String(), Units: f.Units, Group: f.Group}
+		var labels []string
+		if len(f.Labels) > 0 {
+			codes := make([]int, 0, len(f.Labels))
+			for code := range f.Labels {
+				codes = append(codes, code)
+			}
+			sort.Ints(codes)
+			rec.Labels = map[string]string{}
+			for _, code := range codes {
+				rec.Labels[strconv.Itoa(code)] = f.Labels[code]
+				labels = append(labels, fmt.Sprintf("%d=%s", code, f.Labels[code]))
+			}
+		}
+		cells = append(cells, []string{f.Name, rec.Kind, f.Units, f.Group, strings.Join(labels, "; ")})
+		values = append(values, rec)
+	}
+	return writeRecords(a.stdout, a.format, []string{"name", "kind", "units", "group", "labels"}, cells, values)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// config is read from the JSON config file, by default
+// $XDG_CONFIG_HOME/climacell/config.json (or the OS equivalent), for example:
+//
+//	{"apiKey": "...", "units": "imperial"}
+type config struct {
+	// APIKey is used if the CLIMACELL_API_KEY environment variable is not
+	// set.
+	APIKey string `json:"apiKey"`
+	// Units is the default unit system, "metric" or "imperial".
+	Units string `json:"units"`
+	// BaseURL overrides the v4 API's base URL, for example to go through
+	// a proxy.
+	BaseURL string `json:"baseURL"`
+	// Sites maps names to the locations polled by the exporter command
+	// when no locations are given on the command line, for example
+	// {"home": "35.8,-78.6", "cabin": "cabin.geojson"}.
+	Sites map[string]string `json:"sites"`
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "climacell", "config.json")
+}
+
+// loadConfig reads the config file at path and applies the environment on
+// top of it. A missing file is not an error unless its path was given
+// explicitly.
+func loadConfig(path string, explicit bool, getenv func(string) string) (config, error) {
+	var cfg config
+	if path != "" {
+		b, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(b, &cfg); err != nil {
+				return config{}, errors.WithMessagef(err, "parsing config file %s", path)
+			}
+		case explicit || !os.IsNotExist(err):
+			return config{}, errors.WithMessage(err, "reading config file")
+		}
+	}
+
+	if key := getenv("CLIMACELL_API_KEY"); key != "" {
+		cfg.APIKey = key
+	}
+	return cfg, nil
+}
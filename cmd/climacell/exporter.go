@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+	"github.com/maskarb/climacell-go/climacell/v4/exporter"
+)
+
+var exporterFields = []string{
+	"temperature", "temperatureApparent", "dewPoint", "humidity", "windSpeed",
+	"windGust", "windDirection", "pressureSurfaceLevel", "precipitationIntensity",
+	"cloudCover", "visibility", "weatherCode",
+}
+
+func (a *app) exporter(args []string) error {
+	fs := a.flags("exporter")
+	listen := fs.String("listen", ":9712", "address to serve metrics on")
+	interval := fs.Duration("interval", exporter.DefaultInterval, "time between polls of every site")
+	fields := fs.String("fields", strings.Join(exporterFields, ","), "comma-separated fields to export")
+	rate := fs.Int("rate", 25, "maximum API requests per hour allowed by your plan")
+	units := fs.String("units", "", "unit system: metric or imperial (default from config, else metric)")
+	once := fs.Bool("once", false, "poll every site once, print the metrics and exit")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	sites, err := a.exporterSites(fs.Args())
+	if err != nil {
+		return err
+	}
+	c, err := a.clientV4()
+	if err != nil {
+		return err
+	}
+	if *units == "" {
+		*units = a.cfg.Units
+	}
+
+	// Each poll sends a request per site, so polling more often than the
+	// plan's rate allows would only get requests rejected.
+	c.RateLimiter = climacell.NewRateLimiter(*rate, time.Hour)
+	if min := time.Duration(len(sites)) * c.RateLimiter.Interval(); *interval < min {
+		fmt.Fprintf(a.stderr, "poll interval raised to %s to stay within %d requests per hour\n", min, *rate)
+		*interval = min
+	}
+
+	e := exporter.New(c, sites, splitList(*fields))
+	e.Units = *units
+	e.Interval = *interval
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(e)
+	if !*once {
+		reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+	handler := promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *once {
+		pollErr := e.Poll(ctx)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		a.stdout.Write(rec.Body.Bytes())
+		return pollErr
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	srv := &http.Server{Addr: *listen, Handler: mux}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	go e.Run(ctx)
+	fmt.Fprintf(a.stderr, "serving metrics for %d sites on %s/metrics\n", len(sites), *listen)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// exporterSites returns the sites to export, from the locations given on the
+// command line or else from the config file's sites.
+func (a *app) exporterSites(args []string) ([]exporter.Site, error) {
+	var sites []exporter.Site
+	add := func(name string, locs []location) {
+		for _, l := range locs {
+			site := exporter.Site{Name: l.name, LocationID: l.id}
+			if name != "" && len(locs) == 1 {
+				site.Name = name
+			}
+			if l.geometry != nil {
+				site.Location = *l.geometry
+			}
+			sites = append(sites, site)
+		}
+	}
+
+	if len(args) > 0 {
+		for _, arg := range args {
+			locs, err := parseLocations(arg)
+			if err != nil {
+				return nil, err
+			}
+			add("", locs)
+		}
+		return sites, nil
+	}
+
+	names := make([]string, 0, len(a.cfg.Sites))
+	for name := range a.cfg.Sites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		locs, err := parseLocations(a.cfg.Sites[name])
+		if err != nil {
+			return nil, errors.WithMessagef(err, "site %s", name)
+		}
+		add(name, locs)
+	}
+	if len(sites) == 0 {
+		return nil, usageErrorf("no sites: give locations or set sites in the config file")
+	}
+	return sites, nil
+}
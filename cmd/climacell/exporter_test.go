@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExporterOnce(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var opts struct {
+			Timesteps []string `json:"timesteps"`
+			Units     string   `json:"units"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&opts))
+		assert.Equal(t, []string{"current"}, opts.Timesteps)
+		assert.Equal(t, "imperial", opts.Units)
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"data": {"timelines": [{"timestep": "current", "intervals": [
+			{"startTime": "2020-12-21T11:00:00Z", "values": {"temperature": 47.3, "humidity": 71}}
+		]}]}}`)
+	}))
+	defer server.Close()
+
+	cfgPath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`{
+		"apiKey": "test_api_key",
+		"baseURL": "`+server.URL+`",
+		"units": "imperial",
+		"sites": {"home": "35.8,-78.6", "office": "35.9,-78.9"}
+	}`), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"exporter", "-config", cfgPath, "-once", "-fields", "temperature,humidity"}, &stdout, &stderr, func(string) string { return "" })
+	require.Equal(t, 0, code, stderr.String())
+
+	out := stdout.String()
+	assert.Contains(t, out, `climacell_temperature{site="home",unit="F"} 47.3`)
+	assert.Contains(t, out, `climacell_temperature{site="office",unit="F"} 47.3`)
+	assert.Contains(t, out, `climacell_humidity{site="home",unit="%"} 71`)
+	assert.Contains(t, out, `climacell_api_requests_total{code="200"} 2`)
+}
+
+func TestExporterNoSites(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`{"apiKey": "test_api_key"}`), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"exporter", "-config", cfgPath, "-once"}, &stdout, &stderr, func(string) string { return "" })
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr.String(), "no sites")
+}
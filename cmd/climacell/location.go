@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// location is a location given on the command line, either as "lat,lon"
+// coordinates, a geometry read from a GeoJSON file, or the ID of a location
+// saved with the Locations API.
+type location struct {
+	// name identifies the location in the output.
+	name     string
+	geometry *climacell.Geometry
+	id       string
+}
+
+// parseLocations parses a location argument. GeoJSON files can contain a
+// single geometry, a Feature, or a FeatureCollection, which results in one
+// location per feature.
+func parseLocations(arg string) ([]location, error) {
+	if lat, lon, ok := parseLatLon(arg); ok {
+		g := climacell.Geometry{Type: "Point", Coordinates: []float64{lon, lat}}
+		return []location{{name: g.Key(), geometry: &g}}, nil
+	}
+
+	if fi, err := os.Stat(arg); err == nil && !fi.IsDir() {
+		b, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, errors.WithMessage(err, "reading GeoJSON file")
+		}
+		locs, err := parseGeoJSON(b)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "parsing GeoJSON file %s", arg)
+		}
+		return locs, nil
+	}
+
+	if strings.ContainsAny(arg, ",/\\") {
+		return nil, fmt.Errorf("invalid location %q: expected lat,lon, a GeoJSON file, or a location ID", arg)
+	}
+	return []location{{name: arg, id: arg}}, nil
+}
+
+func parseLatLon(arg string) (lat, lon float64, ok bool) {
+	parts := strings.Split(arg, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+	lon, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+type geoJSON struct {
+	Type       string                 `json:"type"`
+	Geometry   *climacell.Geometry    `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+	Features   []geoJSON              `json:"features"`
+	// set for bare geometries
+	Coordinates interface{} `json:"coordinates"`
+}
+
+func parseGeoJSON(b []byte) ([]location, error) {
+	var doc geoJSON
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	switch doc.Type {
+	case "FeatureCollection":
+		var locs []location
+		for i, f := range doc.Features {
+			loc, err := featureLocation(f)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "feature %d", i)
+			}
+			locs = append(locs, loc)
+		}
+		if len(locs) == 0 {
+			return nil, errors.New("feature collection has no features")
+		}
+		return locs, nil
+	case "Feature":
+		loc, err := featureLocation(doc)
+		if err != nil {
+			return nil, err
+		}
+		return []location{loc}, nil
+	case "Point", "MultiPoint", "LineString", "MultiLineString", "Polygon", "MultiPolygon":
+		g := climacell.Geometry{Type: doc.Type, Coordinates: doc.Coordinates}
+		return []location{{name: g.Key(), geometry: &g}}, nil
+	}
+	return nil, fmt.Errorf("unsupported GeoJSON type %q", doc.Type)
+}
+
+func featureLocation(f geoJSON) (location, error) {
+	if f.Geometry == nil {
+		return location{}, errors.New("feature has no geometry")
+	}
+	name := f.Geometry.Key()
+	if n, ok := f.Properties["name"].(string); ok && n != "" {
+		name = n
+	}
+	return location{name: name, geometry: f.Geometry}, nil
+}
+
+// applyTo sets this location on a request to the timelines endpoint.
+func (l location) applyTo(opts *climacell.TimelineListOptions) {
+	if l.geometry != nil {
+		opts.Location = *l.geometry
+		return
+	}
+	opts.LocationID = l.id
+}
+
+// v3 returns this location for the v3 API, which only supports points and
+// location IDs.
+func (l location) v3() (climacell.Location, error) {
+	if l.geometry == nil {
+		return climacell.LocationID(l.id), nil
+	}
+	if l.geometry.Type == "Point" {
+		if c, ok := l.geometry.Coordinates.([]float64); ok && len(c) == 2 {
+			return climacell.LatLon{Lat: c[1], Lon: c[0]}, nil
+		}
+		if c, ok := l.geometry.Coordinates.([]interface{}); ok && len(c) == 2 {
+			lon, lonOK := c[0].(float64)
+			lat, latOK := c[1].(float64)
+			if lonOK && latOK {
+				return climacell.LatLon{Lat: lat, Lon: lon}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("location %s: only points and location IDs are supported by this command", l.name)
+}
@@ -0,0 +1,196 @@
+// Command climacell queries the ClimaCell weather API from the command line.
+//
+// Usage:
+//
+//	climacell <command> [flags] [locations...]
+//
+// Locations are given as "lat,lon" coordinates, paths to GeoJSON files, or
+// the IDs of locations saved with the Locations API. The API key is read from
+// the CLIMACELL_API_KEY environment variable, or from the "apiKey" of the
+// config file. Run "climacell help" for the list of commands.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(a *app, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"timeline", "fetch timelines from the v4 timelines endpoint", (*app).timeline},
+		{"realtime", "fetch current observations", (*app).realtime},
+		{"nowcast", "fetch the minute-by-minute forecast for the next 6 hours", (*app).nowcast},
+		{"hourly", "fetch the hourly forecast", (*app).hourly},
+		{"daily", "fetch the daily forecast", (*app).daily},
+		{"historical", "fetch historical station or ClimaCell data", (*app).historical},
+		{"locations", "list locations saved with the Locations API", (*app).locations},
+		{"fields", "list the fields that can be requested", (*app).fields},
+		{"alerts", "list alerts configured with the Alerts API", (*app).alerts},
+		{"watch", "show a live dashboard of conditions for a location", (*app).watch},
+		{"exporter", "serve current conditions as Prometheus metrics", (*app).exporter},
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].name < commands[j].name })
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr, os.Getenv))
+}
+
+// run runs the command line, returning the process's exit code.
+func run(args []string, stdout, stderr io.Writer, getenv func(string) string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stderr)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		a := &app{stdout: stdout, stderr: stderr, getenv: getenv}
+		err := cmd.run(a, args[1:])
+		switch {
+		case err == flag.ErrHelp:
+			return 0
+		case errors.Is(err, errUsage):
+			fmt.Fprintf(stderr, "climacell %s: %v\n", cmd.name, err)
+			return 2
+		case err != nil:
+			fmt.Fprintf(stderr, "climacell %s: %v\n", cmd.name, err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "climacell: unknown command %q\n\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: climacell <command> [flags] [locations...]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Locations are \"lat,lon\" coordinates, GeoJSON files, or saved location IDs.")
+	fmt.Fprintln(w, "The API key is read from CLIMACELL_API_KEY or the config file.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run \"climacell <command> -h\" for a command's flags.")
+}
+
+var errUsage = errors.New("usage error")
+
+func usageErrorf(format string, args ...interface{}) error {
+	return errors.Wrap(errUsage, fmt.Sprintf(format, args...))
+}
+
+// app holds the state shared by every command.
+type app struct {
+	stdout, stderr io.Writer
+	getenv         func(string) string
+
+	configPath string
+	format     string
+	cfg        config
+}
+
+// flags returns a FlagSet for a command with the flags every command shares.
+func (a *app) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("climacell "+name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.StringVar(&a.format, "o", "table", "output format: "+strings.Join(formats, ", "))
+	fs.StringVar(&a.configPath, "config", "", "path to the config file (default "+defaultConfigPath()+")")
+	return fs
+}
+
+// parse parses a command's flags and loads the config.
+func (a *app) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return errors.Wrap(errUsage, err.Error())
+	}
+	if err := validFormat(a.format); err != nil {
+		return errors.Wrap(errUsage, err.Error())
+	}
+
+	path, explicit := a.configPath, a.configPath != ""
+	if !explicit {
+		path = defaultConfigPath()
+	}
+	cfg, err := loadConfig(path, explicit, a.getenv)
+	if err != nil {
+		return err
+	}
+	a.cfg = cfg
+	return nil
+}
+
+func (a *app) apiKey() (string, error) {
+	if a.cfg.APIKey == "" {
+		return "", errors.New("no API key: set CLIMACELL_API_KEY or the apiKey in the config file")
+	}
+	return a.cfg.APIKey, nil
+}
+
+func (a *app) clientV4() (*climacell.ClientV4, error) {
+	key, err := a.apiKey()
+	if err != nil {
+		return nil, err
+	}
+	c := climacell.NewClient(key)
+	if a.cfg.BaseURL != "" {
+		c.BaseURL = a.cfg.BaseURL
+	}
+	return c, nil
+}
+
+func (a *app) clientV3() (*climacell.ClientV3, error) {
+	key, err := a.apiKey()
+	if err != nil {
+		return nil, err
+	}
+	return climacell.New(key), nil
+}
+
+// locationArgs parses every location argument of a command.
+func locationArgs(fs *flag.FlagSet) ([]location, error) {
+	if fs.NArg() == 0 {
+		return nil, usageErrorf("at least one location is required")
+	}
+	var locs []location
+	for _, arg := range fs.Args() {
+		l, err := parseLocations(arg)
+		if err != nil {
+			return nil, err
+		}
+		locs = append(locs, l...)
+	}
+	return locs, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+func TestParseLocations(t *testing.T) {
+	locs, err := parseLocations("35.8,-78.6")
+	require.NoError(t, err)
+	require.Len(t, locs, 1)
+	assert.Equal(t, "35.8,-78.6", locs[0].name)
+	assert.Equal(t, []float64{-78.6, 35.8}, locs[0].geometry.Coordinates)
+
+	locs, err = parseLocations("5fbe7c8a4b1e2c0008a2d6b1")
+	require.NoError(t, err)
+	assert.Equal(t, "5fbe7c8a4b1e2c0008a2d6b1", locs[0].id)
+
+	path := filepath.Join(t.TempDir(), "sites.geojson")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"type": "FeatureCollection",
+		"features": [
+			{"type": "Feature", "properties": {"name": "raleigh"}, "geometry": {"type": "Point", "coordinates": [-78.6, 35.8]}},
+			{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}}
+		]
+	}`), 0o644))
+	locs, err = parseLocations(path)
+	require.NoError(t, err)
+	require.Len(t, locs, 2)
+	assert.Equal(t, "raleigh", locs[0].name)
+	assert.Equal(t, "Polygon", locs[1].geometry.Type)
+
+	_, err = locs[0].v3()
+	assert.NoError(t, err)
+	_, err = locs[1].v3()
+	assert.Error(t, err)
+
+	_, err = parseLocations("not,a,location")
+	assert.Error(t, err)
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC)
+
+	tm, err := parseTime("+6h", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(6*time.Hour), tm)
+
+	tm, err = parseTime("2020-12-22T00:00:00Z", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 12, 22, 0, 0, 0, 0, time.UTC), tm)
+
+	_, err = parseTime("tomorrow", now)
+	assert.Error(t, err)
+}
+
+func TestWriteRows(t *testing.T) {
+	rows := []row{{
+		Location: "35.8,-78.6",
+		Timestep: "1h",
+		Time:     time.Date(2020, 12, 21, 6, 0, 0, 0, time.UTC),
+		Values:   climacell.Values{"temperature": 10.5, "weatherCode": 4001.0},
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeRows(&buf, "csv", rows))
+	assert.Equal(t, "location,timestep,time,temperature,weatherCode\n\"35.8,-78.6\",1h,2020-12-21T06:00:00Z,10.5,4001\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeRows(&buf, "table", rows))
+	assert.Contains(t, buf.String(), "Rain")
+
+	buf.Reset()
+	require.NoError(t, writeRows(&buf, "ndjson", append(rows, rows...)))
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"))
+}
+
+func TestTimelineCommand(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/timelines", r.URL.Path)
+		assert.Equal(t, "test_api_key", r.URL.Query().Get("apikey"))
+		b, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(b, &body))
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"data": {"timelines": [{
+			"timestep": "1h",
+			"startTime": "2020-12-21T06:00:00Z",
+			"endTime": "2020-12-21T07:00:00Z",
+			"intervals": [
+				{"startTime": "2020-12-21T06:00:00Z", "values": {"temperature": 10.5}},
+				{"startTime": "2020-12-21T07:00:00Z", "values": {"temperature": 11}}
+			]
+		}]}}`)
+	}))
+	defer server.Close()
+
+	cfgPath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`{"baseURL": "`+server.URL+`"}`), 0o644))
+	env := func(k string) string {
+		if k == "CLIMACELL_API_KEY" {
+			return "test_api_key"
+		}
+		return ""
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"timeline", "-config", cfgPath, "-o", "csv", "-fields", "temperature", "35.8,-78.6"}, &stdout, &stderr, env)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "location,timestep,time,temperature\n"+
+		"\"35.8,-78.6\",1h,2020-12-21T06:00:00Z,10.5\n"+
+		"\"35.8,-78.6\",1h,2020-12-21T07:00:00Z,11\n", stdout.String())
+	assert.Equal(t, []interface{}{"temperature"}, body["fields"])
+	assert.Equal(t, map[string]interface{}{"type": "Point", "coordinates": []interface{}{-78.6, 35.8}}, body["location"])
+
+	stderr.Reset()
+	code = run([]string{"timeline", "-config", cfgPath, "35.8,-78.6"}, &stdout, &stderr, func(string) string { return "" })
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "no API key")
+
+	code = run([]string{"bogus"}, &stdout, &stderr, env)
+	assert.Equal(t, 2, code)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+var formats = []string{"table", "json", "csv", "ndjson"}
+
+func validFormat(format string) error {
+	for _, f := range formats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q, expected one of %s", format, strings.Join(formats, ", "))
+}
+
+// row is a single interval of weather data for a location.
+type row struct {
+	Location string           `json:"location"`
+	Timestep string           `json:"timestep"`
+	Time     time.Time        `json:"time"`
+	Values   climacell.Values `json:"values"`
+}
+
+func timelineRows(loc string, list *climacell.TimelineList) []row {
+	var rows []row
+	for _, t := range list.Timelines {
+		for _, iv := range t.Intervals {
+			rows = append(rows, row{Location: loc, Timestep: t.Timestep, Time: iv.StartTime, Values: iv.Values})
+		}
+	}
+	return rows
+}
+
+func fieldColumns(rows []row) []string {
+	seen := map[string]bool{}
+	var cols []string
+	for _, r := range rows {
+		for f := range r.Values {
+			if !seen[f] {
+				seen[f] = true
+				cols = append(cols, f)
+			}
+		}
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// writeRows writes weather data rows in the given format. The table format
+// shows enum values with their labels; the other formats keep raw values.
+func writeRows(w io.Writer, format string, rows []row) error {
+	switch format {
+	case "json", "ndjson":
+		values := make([]interface{}, len(rows))
+		for i, r := range rows {
+			values[i] = r
+		}
+		return writeJSON(w, format, values)
+	}
+
+	cols := fieldColumns(rows)
+	header := append([]string{"location", "timestep", "time"}, cols...)
+	cells := make([][]string, len(rows))
+	for i, r := range rows {
+		line := []string{r.Location, r.Timestep, r.Time.Format(time.RFC3339)}
+		for _, f := range cols {
+			line = append(line, formatValue(f, r.Values, format == "table"))
+		}
+		cells[i] = line
+	}
+	return writeCells(w, format, header, cells)
+}
+
+func formatValue(field string, v climacell.Values, labels bool) string {
+	if _, ok := v[field]; !ok {
+		return ""
+	}
+	if n, ok := v.Float(field); ok {
+		if labels {
+			if f, ok := climacell.LookupField(field); ok && f.Kind == climacell.FieldEnum {
+				if label, ok := f.Label(int(n)); ok {
+					return label
+				}
+			}
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	s, _ := v.String(field)
+	return s
+}
+
+// writeRecords writes records other than weather data, like saved locations,
+// with cells used for the table and csv formats and values for json and
+// ndjson.
+func writeRecords(w io.Writer, format string, header []string, cells [][]string, values []interface{}) error {
+	if format == "json" || format == "ndjson" {
+		return writeJSON(w, format, values)
+	}
+	return writeCells(w, format, header, cells)
+}
+
+func writeJSON(w io.Writer, format string, values []interface{}) error {
+	enc := json.NewEncoder(w)
+	if format == "ndjson" {
+		for _, v := range values {
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if values == nil {
+		values = []interface{}{}
+	}
+	enc.SetIndent("", "  ")
+	return enc.Encode(values)
+}
+
+func writeCells(w io.Writer, format string, header []string, cells [][]string) error {
+	if format == "csv" {
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(cells); err != nil {
+			return err
+		}
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+	for _, line := range cells {
+		fmt.Fprintln(tw, strings.Join(line, "\t"))
+	}
+	return tw.Flush()
+}
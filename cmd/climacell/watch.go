@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+// requestsPerRefresh is the number of API requests each dashboard refresh
+// sends: one for the current conditions and nowcast, and one for the daily
+// outlook.
+const requestsPerRefresh = 2
+
+var currentFields = []string{
+	"temperature", "temperatureApparent", "humidity", "windSpeed",
+	"windDirection", "precipitationIntensity", "weatherCode",
+}
+
+var dailyFields = []string{
+	"temperatureMax", "temperatureMin", "precipitationProbability", "weatherCode",
+}
+
+// weatherIcons maps the first digit of a weatherCode to an icon; codes with
+// their own icon are listed in weatherCodeIcons.
+var weatherIcons = map[int]string{
+	1: "☁",
+	2: "🌫",
+	3: "💨",
+	4: "🌧",
+	5: "❄",
+	6: "🌧",
+	7: "🧊",
+	8: "⛈",
+}
+
+var weatherCodeIcons = map[int]string{
+	1000: "☀",
+	1100: "🌤",
+	1101: "⛅",
+	1102: "🌥",
+	4000: "🌦",
+	4200: "🌦",
+}
+
+// weatherIcon returns the icon for a weatherCode, or "?" for unknown codes.
+func weatherIcon(code int) string {
+	if icon, ok := weatherCodeIcons[code]; ok {
+		return icon
+	}
+	if icon, ok := weatherIcons[code/1000]; ok {
+		return icon
+	}
+	return "?"
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws values as a line of block characters scaled between the
+// smallest and largest value, with missing values drawn as spaces.
+func sparkline(values []float64, ok []bool) string {
+	min, max := math.Inf(1), math.Inf(-1)
+	for i, v := range values {
+		if ok[i] {
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+	}
+
+	var b strings.Builder
+	for i, v := range values {
+		switch {
+		case !ok[i]:
+			b.WriteRune(' ')
+		case max == min:
+			b.WriteRune(sparkBlocks[0])
+		default:
+			b.WriteRune(sparkBlocks[int((v-min)/(max-min)*float64(len(sparkBlocks)-1)+0.5)])
+		}
+	}
+	return b.String()
+}
+
+// dashboard is the data shown by the watch command.
+type dashboard struct {
+	location string
+	units    string
+	current  *climacell.Interval
+	nowcast  []climacell.Interval
+	daily    []climacell.Interval
+	updated  time.Time
+	next     time.Time
+	err      error
+}
+
+func (a *app) watch(args []string) error {
+	fs := a.flags("watch")
+	interval := fs.Duration("interval", 5*time.Minute, "time between refreshes")
+	rate := fs.Int("rate", 25, "maximum API requests per hour allowed by your plan")
+	hours := fs.Int("hours", 6, "hours of nowcast to show (at most 6)")
+	days := fs.Int("days", 5, "days of daily outlook to show")
+	units := fs.String("units", "", "unit system: metric or imperial (default from config, else metric)")
+	once := fs.Bool("once", false, "draw the dashboard once and exit")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	locs, err := locationArgs(fs)
+	if err != nil {
+		return err
+	}
+	if len(locs) != 1 {
+		return usageErrorf("watch takes a single location")
+	}
+	c, err := a.clientV4()
+	if err != nil {
+		return err
+	}
+	if *units == "" {
+		*units = a.cfg.Units
+	}
+	if *hours > 6 {
+		*hours = 6
+	}
+
+	// Each refresh sends requestsPerRefresh requests, so refreshing more
+	// often than the plan's rate allows would only get requests rejected.
+	c.RateLimiter = climacell.NewRateLimiter(*rate, time.Hour)
+	if min := requestsPerRefresh * c.RateLimiter.Interval(); *interval < min {
+		fmt.Fprintf(a.stderr, "refresh interval raised to %s to stay within %d requests per hour\n", min, *rate)
+		*interval = min
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	d := dashboard{location: locs[0].name, units: *units}
+	wait := *interval
+	for {
+		err := d.refresh(ctx, c, locs[0], *hours, *days)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// Back off on errors, especially when the API says we've sent
+		// too many requests, and go back to the regular interval once a
+		// refresh succeeds.
+		switch {
+		case err == nil:
+			wait = *interval
+		case isRateLimited(err):
+			wait *= 2
+		default:
+			wait = *interval
+		}
+		if wait > time.Hour {
+			wait = time.Hour
+		}
+		d.err = err
+		d.next = time.Now().Add(wait)
+
+		if *once {
+			d.render(a.stdout, false)
+			return err
+		}
+		d.render(a.stdout, true)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+func isRateLimited(err error) bool {
+	var errRes *climacell.ErrorResponse
+	return errors.As(err, &errRes) && errRes.StatusCode == 429
+}
+
+// refresh fetches the dashboard's data. Data from the last successful
+// refresh is kept if a request fails.
+func (d *dashboard) refresh(ctx context.Context, c *climacell.ClientV4, loc location, hours, days int) error {
+	now := time.Now()
+
+	opts := &climacell.TimelineListOptions{
+		Fields:    currentFields,
+		TimeSteps: []string{"current", "5m"},
+		StartTime: now.Format(time.RFC3339),
+		EndTime:   now.Add(time.Duration(hours) * time.Hour).Format(time.RFC3339),
+		Units:     d.units,
+	}
+	loc.applyTo(opts)
+	list, err := c.GetTimelines(ctx, opts)
+	if err != nil {
+		return err
+	}
+	for _, t := range list.Timelines {
+		switch t.Timestep {
+		case "current":
+			if len(t.Intervals) > 0 {
+				d.current = &t.Intervals[0]
+			}
+		case "5m":
+			d.nowcast = t.Intervals
+		}
+	}
+
+	opts = &climacell.TimelineListOptions{
+		Fields:    dailyFields,
+		TimeSteps: []string{"1d"},
+		StartTime: now.Format(time.RFC3339),
+		EndTime:   now.Add(time.Duration(days) * 24 * time.Hour).Format(time.RFC3339),
+		Units:     d.units,
+	}
+	loc.applyTo(opts)
+	if list, err = c.GetTimelines(ctx, opts); err != nil {
+		return err
+	}
+	for _, t := range list.Timelines {
+		if t.Timestep == "1d" {
+			d.daily = t.Intervals
+		}
+	}
+
+	d.updated = now
+	return nil
+}
+
+// unitsFor returns the units of a field in the dashboard's unit system.
+func (d *dashboard) unitsFor(field string) string {
+	f, ok := climacell.LookupField(field)
+	if !ok {
+		return ""
+	}
+	return f.UnitsIn(d.units)
+}
+
+func (d *dashboard) value(v climacell.Values, field string) string {
+	n, ok := v.Float(field)
+	if !ok {
+		return "-"
+	}
+	switch units := d.unitsFor(field); units {
+	case "%":
+		return fmt.Sprintf("%.0f%%", n)
+	case "degrees":
+		return fmt.Sprintf("%.0f°", n)
+	default:
+		return fmt.Sprintf("%.1f %s", n, units)
+	}
+}
+
+func conditions(v climacell.Values) string {
+	code, ok := v.Int("weatherCode")
+	if !ok {
+		return "? Unknown"
+	}
+	label, ok := climacell.WeatherCodeLabels[code]
+	if !ok {
+		label = "Unknown"
+	}
+	return weatherIcon(code) + " " + label
+}
+
+// render draws the dashboard. If clear is set, the terminal is cleared first
+// so that each refresh redraws the screen in place.
+func (d *dashboard) render(w io.Writer, clear bool) {
+	var b strings.Builder
+	if clear {
+		b.WriteString("\x1b[H\x1b[2J")
+	}
+
+	fmt.Fprintf(&b, "ClimaCell — %s\n", d.location)
+	if !d.updated.IsZero() {
+		fmt.Fprintf(&b, "updated %s", d.updated.Format("15:04:05"))
+	}
+	if !d.next.IsZero() {
+		fmt.Fprintf(&b, "  next refresh %s", d.next.Format("15:04:05"))
+	}
+	b.WriteString("\n")
+	if d.err != nil {
+		fmt.Fprintf(&b, "error: %v\n", d.err)
+	}
+	b.WriteString("\n")
+
+	if d.current != nil {
+		v := d.current.Values
+		fmt.Fprintf(&b, "Now        %s  %s (feels like %s)\n", conditions(v), d.value(v, "temperature"), d.value(v, "temperatureApparent"))
+		fmt.Fprintf(&b, "           wind %s from %s  humidity %s  precipitation %s\n",
+			d.value(v, "windSpeed"), d.value(v, "windDirection"), d.value(v, "humidity"), d.value(v, "precipitationIntensity"))
+		b.WriteString("\n")
+	}
+
+	if len(d.nowcast) > 0 {
+		first, last := d.nowcast[0].StartTime, d.nowcast[len(d.nowcast)-1].StartTime
+		fmt.Fprintf(&b, "Nowcast    %s – %s\n", first.Local().Format("15:04"), last.Local().Format("15:04"))
+		for _, field := range []string{"precipitationIntensity", "temperature"} {
+			values := make([]float64, len(d.nowcast))
+			ok := make([]bool, len(d.nowcast))
+			max, found := math.Inf(-1), false
+			for i, iv := range d.nowcast {
+				values[i], ok[i] = iv.Values.Float(field)
+				if ok[i] {
+					max, found = math.Max(max, values[i]), true
+				}
+			}
+			label := "temp"
+			if field == "precipitationIntensity" {
+				label = "precip"
+			}
+			if !found {
+				fmt.Fprintf(&b, "  %-8s n/a\n", label)
+				continue
+			}
+			fmt.Fprintf(&b, "  %-8s %s  max %.1f %s\n", label, sparkline(values, ok), max, d.unitsFor(field))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(d.daily) > 0 {
+		b.WriteString("Outlook\n")
+		for _, iv := range d.daily {
+			fmt.Fprintf(&b, "  %s  %-22s high %-9s low %-9s precip %s\n",
+				iv.StartTime.Local().Format("Mon 02"), conditions(iv.Values),
+				d.value(iv.Values, "temperatureMax"), d.value(iv.Values, "temperatureMin"),
+				d.value(iv.Values, "precipitationProbability"))
+		}
+	}
+
+	io.WriteString(w, b.String())
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	climacell "github.com/maskarb/climacell-go/climacell/v4"
+)
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "▁▅█ ▁", sparkline([]float64{0, 1, 2, 0, 0}, []bool{true, true, true, false, true}))
+	assert.Equal(t, "▁▁", sparkline([]float64{3, 3}, []bool{true, true}))
+}
+
+func TestDashboardMissingNowcast(t *testing.T) {
+	d := &dashboard{location: "home", nowcast: []climacell.Interval{
+		{StartTime: time.Date(2020, 12, 21, 11, 0, 0, 0, time.UTC), Values: climacell.Values{"temperature": 8.5}},
+	}}
+	var buf bytes.Buffer
+	d.render(&buf, false)
+	assert.Contains(t, buf.String(), "precip   n/a\n")
+	assert.NotContains(t, buf.String(), "Inf")
+	assert.Contains(t, buf.String(), "max 8.5")
+}
+
+func TestWeatherIcon(t *testing.T) {
+	assert.Equal(t, "☀", weatherIcon(1000))
+	assert.Equal(t, "🌧", weatherIcon(4201))
+	assert.Equal(t, "⛈", weatherIcon(8000))
+	assert.Equal(t, "?", weatherIcon(0))
+}
+
+func TestWatchOnce(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var opts struct {
+			Timesteps []string `json:"timesteps"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&opts))
+
+		w.Header().Set("Content-Type", "application/json")
+		if opts.Timesteps[0] == "1d" {
+			io.WriteString(w, `{"data": {"timelines": [{"timestep": "1d", "intervals": [
+				{"startTime": "2020-12-21T11:00:00Z", "values": {"temperatureMax": 12, "temperatureMin": 4, "precipitationProbability": 80, "weatherCode": 4001}}
+			]}]}}`)
+			return
+		}
+		io.WriteString(w, `{"data": {"timelines": [
+			{"timestep": "current", "intervals": [
+				{"startTime": "2020-12-21T11:00:00Z", "values": {"temperature": 8.5, "humidity": 71, "weatherCode": 1000}}
+			]},
+			{"timestep": "5m", "intervals": [
+				{"startTime": "2020-12-21T11:00:00Z", "values": {"precipitationIntensity": 0, "temperature": 8.5}},
+				{"startTime": "2020-12-21T11:05:00Z", "values": {"precipitationIntensity": 1.5, "temperature": 8.4}}
+			]}
+		]}}`)
+	}))
+	defer server.Close()
+
+	cfgPath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`{"apiKey": "test_api_key", "baseURL": "`+server.URL+`"}`), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"watch", "-config", cfgPath, "-once", "-interval", "1m", "35.8,-78.6"}, &stdout, &stderr, func(string) string { return "" })
+	require.Equal(t, 0, code, stderr.String())
+
+	out := stdout.String()
+	assert.Contains(t, out, "☀ Clear  8.5 C")
+	assert.Contains(t, out, "humidity 71%")
+	assert.Contains(t, out, "▁█  max 1.5 mm/hr")
+	assert.Contains(t, out, "🌧 Rain")
+	assert.Contains(t, out, "precip 80%")
+	// 25 requests per hour at 2 requests per refresh
+	assert.Contains(t, stderr.String(), "refresh interval raised to 4m48s")
+}